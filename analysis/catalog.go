@@ -0,0 +1,293 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// CreatureKind tells players, monsters and NPCs apart.
+type CreatureKind int
+
+const (
+	CreaturePlayer CreatureKind = iota
+	CreatureMonster
+	CreatureNPC
+)
+
+var creatureKindName = map[CreatureKind]string{
+	CreaturePlayer:  "player",
+	CreatureMonster: "monster",
+	CreatureNPC:     "NPC",
+}
+
+func (k CreatureKind) String() string {
+	if n, ok := creatureKindName[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("CreatureKind(%d)", int(k))
+}
+
+// CreatureKindOf classifies a creature by its ID and name.
+// Monsters and NPCs share an ID range, so it's a heuristic: creatures with a capitalized name are taken for NPCs,
+// which holds for servers following the original naming, but not e.g. for named bosses.
+func CreatureKindOf(c data.Creature) CreatureKind {
+	if c.IsPlayer() {
+		return CreaturePlayer
+	}
+	if r, _ := utf8.DecodeRuneInString(c.Name); unicode.IsUpper(r) {
+		return CreatureNPC
+	}
+	return CreatureMonster
+}
+
+// CatalogOpts controls the behavior of CreatureCatalog.
+type CatalogOpts struct {
+	// Resolve picks the .dat file used to reconstruct tile stacks of the recording at path, e.g. a
+	// batch.DatResolver. Recommended.
+	Resolve func(path string) (*dat.File, error)
+}
+
+// CatalogLight is a creature light.
+type CatalogLight struct {
+	Level, Color byte
+}
+
+// CatalogSighting is the first time a creature came into view in a recording.
+type CatalogSighting struct {
+	Recording string
+	Time      time.Duration
+}
+
+// CatalogMark is a skull or party shield a creature was seen with.
+type CatalogMark struct {
+	Recording string
+	Time      time.Duration
+	Skull     data.Skull
+	Shield    data.Shield
+}
+
+// CatalogEntry describes a creature by name, as seen on a single server.
+type CatalogEntry struct {
+	Server string // From CamMetadata; empty if unknown.
+	Name   string
+	Kind   CreatureKind
+
+	Outfits []data.Outfit
+	Speeds  []uint16
+	Lights  []CatalogLight
+
+	// Marks holds skull and shield changes, starting with the first non-default ones.
+	Marks []CatalogMark
+
+	Sightings []CatalogSighting // By recording.
+	Locations []data.Location   // Every tile the creature was seen on.
+}
+
+// CatalogReport lists creatures seen across recordings, ordered by server, kind and name.
+// Lists within entries are sorted.
+type CatalogReport struct {
+	Entries []*CatalogEntry
+}
+
+// CreatureCatalog collects creatures seen across many recordings:
+// their outfits, speeds, lights, skull and shield history, and locations.
+//
+// Unlike other analyzers, a single CreatureCatalog is fed with every recording. Add may be called concurrently
+// for different recordings, so that it can be used as a batch.ParseAll callback, but operations of a single
+// recording must be added in order from one goroutine. Report must not be called concurrently with Add.
+type CreatureCatalog struct {
+	opts CatalogOpts
+	recs recordings[*catalogRecording]
+}
+
+// catalogRecording holds entries from a single recording until its server is known.
+type catalogRecording struct {
+	state   *world.State
+	server  string
+	entries map[string]*CatalogEntry // By name.
+	marks   map[uint32]CatalogMark   // Last skull and shield by creature ID.
+
+	// Locations by name, kept apart from entries as creatures walk over many tiles.
+	locs map[string]map[data.Location]bool
+}
+
+// NewCreatureCatalog initializes a CreatureCatalog. opts may be nil.
+func NewCreatureCatalog(opts *CatalogOpts) *CreatureCatalog {
+	c := &CreatureCatalog{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	return c
+}
+
+// Add processes the next operation of the recording at path. All operation types are needed to track creatures.
+// It returns an error only if resolving the .dat file fails.
+func (c *CreatureCatalog) Add(path string, op data.Operation) error {
+	rec, err := c.recs.get(path, func() (*catalogRecording, error) { return c.newRecording(path) })
+	if err != nil {
+		return err
+	}
+	rec.add(path, op)
+	return nil
+}
+
+func (c *CreatureCatalog) newRecording(path string) (*catalogRecording, error) {
+	rec := &catalogRecording{
+		entries: map[string]*CatalogEntry{},
+		marks:   map[uint32]CatalogMark{},
+		locs:    map[string]map[data.Location]bool{},
+	}
+	if c.opts.Resolve == nil {
+		rec.state = world.New(nil)
+		return rec, nil
+	}
+	datFile, err := c.opts.Resolve(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving .dat: %w", err)
+	}
+	rec.state = world.New(datFile)
+	return rec, nil
+}
+
+func (r *catalogRecording) add(path string, op data.Operation) {
+	if m, ok := op.(data.CamMetadata); ok {
+		r.server = m.ServerName
+		return
+	}
+
+	var ids []uint32
+	for _, cr := range creaturesOf(op) {
+		ids = append(ids, cr.ID)
+	}
+	switch op := op.(type) {
+	case data.CreatureMove:
+		if things := r.state.Tile(op.OldLocation); int(op.OldStack) < len(things) && things[op.OldStack].HasCreature {
+			ids = append(ids, things[op.OldStack].Creature.ID)
+		}
+	case data.CreatureLight:
+		ids = append(ids, op.CreatureID)
+	case data.CreatureOutfit:
+		ids = append(ids, op.CreatureID)
+	case data.CreatureSpeed:
+		ids = append(ids, op.CreatureID)
+	case data.CreatureSkull:
+		ids = append(ids, op.CreatureID)
+	case data.CreatureParty:
+		ids = append(ids, op.CreatureID)
+	}
+	r.state.Apply(op)
+
+	for _, id := range ids {
+		if cr, ok := r.state.Creature(id); ok && cr.Name != "" {
+			r.observe(path, cr)
+		}
+	}
+}
+
+func (r *catalogRecording) observe(path string, cr world.Creature) {
+	e, ok := r.entries[cr.Name]
+	if !ok {
+		e = &CatalogEntry{
+			Name:      cr.Name,
+			Kind:      CreatureKindOf(cr.Creature),
+			Sightings: []CatalogSighting{{Recording: path, Time: cr.FirstSeen}},
+		}
+		r.entries[cr.Name] = e
+		r.locs[cr.Name] = map[data.Location]bool{}
+	}
+
+	if cr.Outfit != (data.Outfit{}) {
+		e.Outfits = appendNew(e.Outfits, cr.Outfit)
+	}
+	if cr.Speed != 0 {
+		e.Speeds = appendNew(e.Speeds, cr.Speed)
+	}
+	if cr.LightLevel != 0 {
+		e.Lights = appendNew(e.Lights, CatalogLight{Level: cr.LightLevel, Color: cr.LightColor})
+	}
+	if cr.Visible {
+		r.locs[cr.Name][cr.Location] = true
+	}
+
+	mark := CatalogMark{Recording: path, Time: r.state.Now(), Skull: cr.Skull, Shield: cr.Shield}
+	last, seen := r.marks[cr.ID]
+	if seen && (last.Skull != mark.Skull || last.Shield != mark.Shield) || !seen && (mark.Skull != 0 || mark.Shield != 0) {
+		e.Marks = append(e.Marks, mark)
+	}
+	r.marks[cr.ID] = mark
+}
+
+// appendNew appends v to s unless it's already there.
+func appendNew[T comparable](s []T, v T) []T {
+	if slices.Contains(s, v) {
+		return s
+	}
+	return append(s, v)
+}
+
+// Report merges entries from all recordings.
+func (c *CreatureCatalog) Report() CatalogReport {
+	type key struct{ server, name string }
+	merged := map[key]*CatalogEntry{}
+	locs := map[*CatalogEntry]map[data.Location]bool{}
+	for _, rec := range c.recs.all() {
+		for _, e := range rec.entries {
+			k := key{rec.server, e.Name}
+			m, ok := merged[k]
+			if !ok {
+				m = &CatalogEntry{Server: rec.server, Name: e.Name, Kind: e.Kind}
+				merged[k] = m
+				locs[m] = map[data.Location]bool{}
+			}
+			for _, o := range e.Outfits {
+				m.Outfits = appendNew(m.Outfits, o)
+			}
+			for _, s := range e.Speeds {
+				m.Speeds = appendNew(m.Speeds, s)
+			}
+			for _, l := range e.Lights {
+				m.Lights = appendNew(m.Lights, l)
+			}
+			for l := range rec.locs[e.Name] {
+				locs[m][l] = true
+			}
+			m.Marks = append(m.Marks, e.Marks...)
+			m.Sightings = append(m.Sightings, e.Sightings...)
+		}
+	}
+
+	var r CatalogReport
+	for _, e := range merged {
+		slices.SortFunc(e.Outfits, func(a, b data.Outfit) int {
+			return cmp.Or(cmp.Compare(a.LookType, b.LookType), cmp.Compare(a.LookItem, b.LookItem),
+				cmp.Compare(a.Head, b.Head), cmp.Compare(a.Body, b.Body), cmp.Compare(a.Legs, b.Legs), cmp.Compare(a.Feet, b.Feet))
+		})
+		for l := range locs[e] {
+			e.Locations = append(e.Locations, l)
+		}
+		slices.Sort(e.Speeds)
+		slices.SortFunc(e.Lights, func(a, b CatalogLight) int {
+			return cmp.Or(cmp.Compare(a.Level, b.Level), cmp.Compare(a.Color, b.Color))
+		})
+		slices.SortFunc(e.Locations, func(a, b data.Location) int {
+			return cmp.Or(cmp.Compare(a.Z, b.Z), cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+		})
+		slices.SortStableFunc(e.Marks, func(a, b CatalogMark) int {
+			return cmp.Or(cmp.Compare(a.Recording, b.Recording), cmp.Compare(a.Time, b.Time))
+		})
+		slices.SortFunc(e.Sightings, func(a, b CatalogSighting) int { return cmp.Compare(a.Recording, b.Recording) })
+		r.Entries = append(r.Entries, e)
+	}
+	slices.SortFunc(r.Entries, func(a, b *CatalogEntry) int {
+		return cmp.Or(cmp.Compare(a.Server, b.Server), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name))
+	})
+	return r
+}
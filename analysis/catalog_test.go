@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+func TestCreatureKindOf(t *testing.T) {
+	for _, tt := range []struct {
+		c    data.Creature
+		want CreatureKind
+	}{
+		{data.Creature{ID: 0x10000001, Name: "Hunter"}, CreaturePlayer},
+		{data.Creature{ID: 0x40000001, Name: "rat"}, CreatureMonster},
+		{data.Creature{ID: 0x40000002, Name: "Sigurd"}, CreatureNPC},
+	} {
+		if got := CreatureKindOf(tt.c); got != tt.want {
+			t.Errorf("CreatureKindOf(%q) = %v, want %v", tt.c.Name, got, tt.want)
+		}
+	}
+}
+
+func TestCreatureCatalog(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	loc := func(x int) data.Location { return data.Location{X: x, Y: 100, Z: 7} }
+	outfit := data.Outfit{LookType: 21}
+	rat := func(id uint32) data.Thing {
+		return data.Thing{HasCreature: true, Creature: data.Creature{ID: id, Name: "rat", Health: 100, Outfit: outfit, Speed: 150}}
+	}
+	hunter := data.Thing{HasCreature: true, Creature: data.Creature{ID: 5, Name: "Hunter", Health: 100, Outfit: data.Outfit{LookType: 128}, Speed: 220}}
+
+	c := NewCreatureCatalog(nil)
+	for _, rec := range []struct {
+		path   string
+		server string
+		ops    []data.Operation
+	}{
+		{"a.cam", "Tibiantis", []data.Operation{
+			data.Map{PlayerPos: loc(100), Tiles: []data.Tile{
+				{Location: loc(101), Things: []data.Thing{rat(0x40000001)}},
+				{Location: loc(102), Things: []data.Thing{hunter}},
+			}},
+			data.CreatureMove{TimeOffset: ms(1000), OldLocation: loc(101), NewLocation: loc(103)},
+			data.CreatureSkull{TimeOffset: ms(2000), CreatureID: 5, Skull: data.SkullWhite},
+			data.CreatureLight{TimeOffset: ms(3000), CreatureID: 5, Level: 6, Color: 215},
+		}},
+		{"b.cam", "Tibiantis", []data.Operation{
+			data.Map{TimeOffset: ms(500), PlayerPos: loc(100), Tiles: []data.Tile{
+				{Location: loc(101), Things: []data.Thing{rat(0x40000002)}},
+			}},
+			data.CreatureSpeed{TimeOffset: ms(600), CreatureID: 0x40000002, Speed: 300},
+		}},
+	} {
+		for _, op := range append(rec.ops, data.CamMetadata{ServerName: rec.server}) {
+			if err := c.Add(rec.path, op); err != nil {
+				t.Fatalf("Add() error: %v", err)
+			}
+		}
+	}
+
+	want := CatalogReport{Entries: []*CatalogEntry{
+		{
+			Server:    "Tibiantis",
+			Name:      "Hunter",
+			Kind:      CreaturePlayer,
+			Outfits:   []data.Outfit{{LookType: 128}},
+			Speeds:    []uint16{220},
+			Lights:    []CatalogLight{{Level: 6, Color: 215}},
+			Marks:     []CatalogMark{{Recording: "a.cam", Time: ms(2000), Skull: data.SkullWhite}},
+			Sightings: []CatalogSighting{{Recording: "a.cam"}},
+			Locations: []data.Location{loc(102)},
+		},
+		{
+			Server:    "Tibiantis",
+			Name:      "rat",
+			Kind:      CreatureMonster,
+			Outfits:   []data.Outfit{outfit},
+			Speeds:    []uint16{150, 300},
+			Sightings: []CatalogSighting{{Recording: "a.cam"}, {Recording: "b.cam", Time: ms(500)}},
+			Locations: []data.Location{loc(101), loc(103)},
+		},
+	}}
+	if diff := cmp.Diff(want, c.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestCreatureCatalog_Recordings(t *testing.T) {
+	reg := dat.NewRegistry()
+	for name, path := range map[string]string{"tibiantis": "Tibiantis.dat", "relic": "TibiaRelic.dat"} {
+		if err := reg.AddFile(name, filepath.Join("../cam/testdata", path)); err != nil {
+			t.Fatalf("AddFile(%q) error: %v", path, err)
+		}
+	}
+	c := NewCreatureCatalog(&CatalogOpts{Resolve: batch.RegistryDat(reg)})
+	paths := []string{"../cam/testdata/tibiantis.cam", "../cam/testdata/relic.cam"}
+	res, err := batch.ParseAll(context.Background(), paths, batch.RegistryDat(reg), 2, c.Add)
+	if err != nil || len(res.Errors) != 0 {
+		t.Fatalf("ParseAll() = %v, %v", res, err)
+	}
+
+	kinds := map[string]CreatureKind{}
+	for _, e := range c.Report().Entries {
+		kinds[e.Name] = e.Kind
+		if len(e.Locations) == 0 || len(e.Sightings) == 0 {
+			t.Errorf("Report() has %q seen at %v in %v, want some locations and sightings", e.Name, e.Locations, e.Sightings)
+		}
+	}
+	for name, want := range map[string]CreatureKind{
+		"Kasmir": CreatureNPC,
+		"Muzir":  CreatureNPC,
+		"hyaena": CreatureMonster,
+		"dwarf":  CreatureMonster,
+	} {
+		if got, ok := kinds[name]; !ok || got != want {
+			t.Errorf("Report() has %q as %v (found: %v), want %v", name, got, ok, want)
+		}
+	}
+}
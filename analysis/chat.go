@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// ChatOpts controls the behavior of ChatLog.
+type ChatOpts struct {
+	// Start is the wall-clock time at which the recording begins, e.g. TimelineReport.Start.
+	// If zero, lines have no wall-clock time. CamMetadata.LastVisit isn't used: it is the previous login,
+	// possibly days earlier.
+	Start time.Time
+}
+
+// Transcript names.
+const (
+	DefaultChannel        = "Default"
+	RuleViolationsChannel = "Rule Violations"
+	PrivateChannel        = "Private" // Private messages without a sender name.
+)
+
+// ChatLine is a single CreatureMessage.
+type ChatLine struct {
+	Time    time.Duration
+	Wall    time.Time // Zero if the start of the recording is unknown.
+	Speaker string
+	Type    data.SpeakType
+	Text    string
+}
+
+// Transcript holds messages of a single channel, in recording order.
+// Private messages are kept in a transcript named after the other party, like in the client,
+// or in PrivateChannel if the sender has no name.
+type Transcript struct {
+	Channel string
+	Lines   []ChatLine
+}
+
+// ChatReport holds all transcripts of a recording, ordered by their first message.
+type ChatReport struct {
+	Start       time.Time // Zero if unknown.
+	Transcripts []Transcript
+}
+
+// ChatLog groups creature messages into per-channel transcripts.
+type ChatLog struct {
+	opts ChatOpts
+
+	channels map[uint16]string // Names of channels known so far, by ID.
+	lines    []chatLine
+}
+
+type chatLine struct {
+	line ChatLine
+
+	// Channel messages are resolved using names known at the time, falling back to names learned later.
+	channel   string
+	channelID *uint16
+}
+
+// NewChatLog initializes a ChatLog. opts may be nil.
+func NewChatLog(opts *ChatOpts) *ChatLog {
+	l := &ChatLog{channels: map[uint16]string{}}
+	if opts != nil {
+		l.opts = *opts
+	}
+	return l
+}
+
+// Add processes the next operation. Only CreatureMessage and channel operations are used.
+func (l *ChatLog) Add(op data.Operation) {
+	switch op := op.(type) {
+	case data.ChannelList:
+		for _, c := range op.Channels {
+			l.channels[c.ID] = c.Name
+		}
+	case data.ChannelOpen:
+		l.channels[op.ID] = op.Name
+	case data.PrivateChannelCreate:
+		l.channels[op.ID] = op.Name
+	case data.CreatureMessage:
+		l.addMessage(op)
+	}
+}
+
+func (l *ChatLog) addMessage(op data.CreatureMessage) {
+	cl := chatLine{line: ChatLine{Time: op.TimeOffset, Speaker: op.Name, Type: op.Type, Text: op.Text}}
+	switch {
+	case op.ChannelID != nil:
+		cl.channel = l.channels[*op.ChannelID]
+		cl.channelID = op.ChannelID
+	case op.Type == data.SpeakPrivate || op.Type == data.SpeakPrivateRed:
+		cl.channel = cmp.Or(op.Name, PrivateChannel)
+	case op.Type == data.SpeakRuleViolation || op.Type == data.SpeakRuleViolationAnswer || op.Type == data.SpeakRuleViolationContinue:
+		cl.channel = RuleViolationsChannel
+	default:
+		cl.channel = DefaultChannel
+	}
+	l.lines = append(l.lines, cl)
+}
+
+// Report groups all messages added so far into transcripts.
+func (l *ChatLog) Report() ChatReport {
+	r := ChatReport{Start: l.opts.Start}
+
+	index := map[string]int{}
+	for _, cl := range l.lines {
+		name := cl.channel
+		if name == "" && cl.channelID != nil {
+			name = cmp.Or(l.channels[*cl.channelID], "Channel "+strconv.Itoa(int(*cl.channelID)))
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(r.Transcripts)
+			index[name] = i
+			r.Transcripts = append(r.Transcripts, Transcript{Channel: name})
+		}
+		line := cl.line
+		if !r.Start.IsZero() {
+			line.Wall = r.Start.Add(line.Time)
+		}
+		r.Transcripts[i].Lines = append(r.Transcripts[i].Lines, line)
+	}
+	return r
+}
+
+// Timestamp formats the time of a line as wall-clock time if known, or as an offset into the recording.
+func (c ChatLine) Timestamp() string {
+	if !c.Wall.IsZero() {
+		return c.Wall.Format(time.TimeOnly)
+	}
+	t := c.Time.Truncate(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(t.Hours()), int(t.Minutes())%60, int(t.Seconds())%60)
+}
+
+// WriteText writes all transcripts as plain text, one section per channel.
+func (r ChatReport) WriteText(w io.Writer) error {
+	for i, t := range r.Transcripts {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "== %s ==\n", t.Channel); err != nil {
+			return err
+		}
+		for _, c := range t.Lines {
+			if _, err := fmt.Fprintf(w, "[%s] %s (%s): %s\n", c.Timestamp(), c.Speaker, c.Type, c.Text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteIRC writes all transcripts in the style of an IRC client log, one log per channel.
+// Plain speech is written as "<Speaker> text", other types as notices, e.g. "-Speaker:yell- text".
+func (r ChatReport) WriteIRC(w io.Writer) error {
+	for _, t := range r.Transcripts {
+		opened := "#" + t.Channel
+		if !r.Start.IsZero() {
+			opened = r.Start.Format(time.ANSIC) + " " + opened
+		}
+		if _, err := fmt.Fprintf(w, "--- Log opened %s\n", opened); err != nil {
+			return err
+		}
+		for _, c := range t.Lines {
+			var err error
+			switch c.Type {
+			case data.SpeakSay, data.SpeakPrivate, data.SpeakChannel:
+				_, err = fmt.Fprintf(w, "[%s] <%s> %s\n", c.Timestamp(), c.Speaker, c.Text)
+			default:
+				_, err = fmt.Fprintf(w, "[%s] -%s:%s- %s\n", c.Timestamp(), c.Speaker, c.Type, c.Text)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "--- Log closed #%s\n", t.Channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var chatHTML = template.Must(template.New("chat").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Chat log</title>
+<style>
+body { font-family: monospace; }
+.time { color: #888; }
+.type { color: #888; font-style: italic; }
+</style>
+</head>
+<body>
+{{- range .Transcripts}}
+<h2>{{.Channel}}</h2>
+<ul>
+{{- range .Lines}}
+<li><span class="time">{{.Timestamp}}</span> <b>{{.Speaker}}</b> <span class="type">{{.Type}}</span>: {{.Text}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes all transcripts as a single HTML document, one section per channel.
+func (r ChatReport) WriteHTML(w io.Writer) error {
+	return chatHTML.Execute(w, r)
+}
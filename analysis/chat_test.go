@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func TestChatLog(t *testing.T) {
+	sec := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	channel := func(id uint16) *uint16 { return &id }
+	start := time.Date(2025, 11, 28, 14, 37, 22, 0, time.UTC)
+
+	log := NewChatLog(&ChatOpts{Start: start})
+	for _, op := range []data.Operation{
+		data.ChannelOpen{TimeOffset: sec(1), ID: 5, Name: "Trade"},
+		data.CreatureMessage{TimeOffset: sec(2), Name: "Vinzenz", Type: data.SpeakChannel, ChannelID: channel(5), Text: "SELL 5 BPS HMMS"},
+		data.CreatureMessage{TimeOffset: sec(3), Name: "Shy Teddy", Type: data.SpeakSay, Text: "hi"},
+		// Opened after its first message.
+		data.CreatureMessage{TimeOffset: sec(4), Name: "Fernos", Type: data.SpeakChannel, ChannelID: channel(4), Text: "3k"},
+		data.ChannelOpen{TimeOffset: sec(5), ID: 4, Name: "Game-Chat"},
+		data.CreatureMessage{TimeOffset: sec(65), Name: "Xandias", Type: data.SpeakPrivate, Text: "<3"},
+		data.CreatureMessage{TimeOffset: sec(66), Type: data.SpeakPrivateRed, Text: "no name"},
+		data.CreatureMessage{TimeOffset: sec(3700), Name: "a lion", Type: data.SpeakMonsterSay, Text: "Groarrr!"},
+		data.CreatureMessage{TimeOffset: sec(3701), Name: "Someone", Type: data.SpeakChannel, ChannelID: channel(9), Text: "?"},
+		data.CamMetadata{Duration: sec(3800), LastVisit: start.Add(-48 * time.Hour)},
+	} {
+		log.Add(op)
+	}
+
+	r := log.Report()
+	want := ChatReport{
+		Start: start,
+		Transcripts: []Transcript{
+			{Channel: "Trade", Lines: []ChatLine{
+				{Time: sec(2), Wall: start.Add(sec(2)), Speaker: "Vinzenz", Type: data.SpeakChannel, Text: "SELL 5 BPS HMMS"},
+			}},
+			{Channel: DefaultChannel, Lines: []ChatLine{
+				{Time: sec(3), Wall: start.Add(sec(3)), Speaker: "Shy Teddy", Type: data.SpeakSay, Text: "hi"},
+				{Time: sec(3700), Wall: start.Add(sec(3700)), Speaker: "a lion", Type: data.SpeakMonsterSay, Text: "Groarrr!"},
+			}},
+			{Channel: "Game-Chat", Lines: []ChatLine{
+				{Time: sec(4), Wall: start.Add(sec(4)), Speaker: "Fernos", Type: data.SpeakChannel, Text: "3k"},
+			}},
+			{Channel: "Xandias", Lines: []ChatLine{
+				{Time: sec(65), Wall: start.Add(sec(65)), Speaker: "Xandias", Type: data.SpeakPrivate, Text: "<3"},
+			}},
+			{Channel: PrivateChannel, Lines: []ChatLine{
+				{Time: sec(66), Wall: start.Add(sec(66)), Type: data.SpeakPrivateRed, Text: "no name"},
+			}},
+			{Channel: "Channel 9", Lines: []ChatLine{
+				{Time: sec(3701), Wall: start.Add(sec(3701)), Speaker: "Someone", Type: data.SpeakChannel, Text: "?"},
+			}},
+		},
+	}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+
+	var text strings.Builder
+	if err := r.WriteText(&text); err != nil {
+		t.Fatalf("WriteText() err = %v", err)
+	}
+	wantText := `== Trade ==
+[14:37:24] Vinzenz (channel): SELL 5 BPS HMMS
+
+== Default ==
+[14:37:25] Shy Teddy (say): hi
+[15:39:02] a lion (monster say): Groarrr!
+
+== Game-Chat ==
+[14:37:26] Fernos (channel): 3k
+
+== Xandias ==
+[14:38:27] Xandias (private): <3
+
+== Private ==
+[14:38:28]  (private red): no name
+
+== Channel 9 ==
+[15:39:03] Someone (channel): ?
+`
+	if diff := cmp.Diff(wantText, text.String()); diff != "" {
+		t.Errorf("WriteText() diff; -want +got:\n%v", diff)
+	}
+
+	var html strings.Builder
+	if err := r.WriteHTML(&html); err != nil {
+		t.Fatalf("WriteHTML() err = %v", err)
+	}
+	if got, want := html.String(), "<b>Xandias</b> <span class=\"type\">private</span>: &lt;3</li>"; !strings.Contains(got, want) {
+		t.Errorf("WriteHTML() = %q; want it to contain %q", got, want)
+	}
+}
+
+func TestChatReport_WriteIRC(t *testing.T) {
+	r := ChatReport{Transcripts: []Transcript{
+		{Channel: DefaultChannel, Lines: []ChatLine{
+			{Time: 3*time.Second + 500*time.Millisecond, Speaker: "Shy Teddy", Type: data.SpeakSay, Text: "hi"},
+			{Time: time.Hour + 2*time.Minute, Speaker: "a lion", Type: data.SpeakMonsterYell, Text: "GROARRR!"},
+		}},
+	}}
+	var got strings.Builder
+	if err := r.WriteIRC(&got); err != nil {
+		t.Fatalf("WriteIRC() err = %v", err)
+	}
+	want := `--- Log opened #Default
+[0:00:03] <Shy Teddy> hi
+[1:02:00] -a lion:monster yell- GROARRR!
+--- Log closed #Default
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("WriteIRC() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestChatLog_NoStart(t *testing.T) {
+	// The last visit is the previous login, not the start of the recording.
+	log := NewChatLog(nil)
+	log.Add(data.CreatureMessage{TimeOffset: time.Second, Name: "Shy Teddy", Type: data.SpeakSay, Text: "hi"})
+	log.Add(data.CamMetadata{Duration: time.Minute, LastVisit: time.Date(2025, 11, 26, 9, 0, 0, 0, time.UTC)})
+	r := log.Report()
+	if !r.Start.IsZero() || !r.Transcripts[0].Lines[0].Wall.IsZero() {
+		t.Errorf("Report() Start = %v, first line at %v; want zero times", r.Start, r.Transcripts[0].Lines[0].Wall)
+	}
+}
@@ -0,0 +1,344 @@
+package analysis
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// CombatKind classifies a CombatEvent.
+type CombatKind int
+
+const (
+	CombatUnknown CombatKind = iota
+	CombatPhysical
+	CombatPoison
+	CombatEnergy
+	CombatFire
+	CombatMana // Damage absorbed by mana, e.g. with a magic shield.
+	CombatHealing
+)
+
+var combatKindName = map[CombatKind]string{
+	CombatUnknown:  "unknown",
+	CombatPhysical: "physical",
+	CombatPoison:   "poison",
+	CombatEnergy:   "energy",
+	CombatFire:     "fire",
+	CombatMana:     "mana",
+	CombatHealing:  "healing",
+}
+
+func (k CombatKind) String() string {
+	if n, ok := combatKindName[k]; ok {
+		return n
+	}
+	return "CombatKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Animated text colors. Physical damage takes the color of the target's blood,
+// so hits on slime-blooded creatures show up as poison.
+var textColorKind = map[byte]CombatKind{
+	5:   CombatMana,
+	30:  CombatPoison,
+	35:  CombatEnergy,
+	129: CombatPhysical, // Undead.
+	180: CombatPhysical,
+	198: CombatFire,
+}
+
+// expTextColor marks experience gains, which share EffectText with damage.
+const expTextColor = 215
+
+var loseRe = regexp.MustCompile(`^You lose (\d+) (hitpoints?|mana)(?: due to an attack by (.+))?\.$`)
+
+// CombatEvent is a single amount of damage or healing.
+// Source and Target are creature names; either may be empty if it couldn't be determined.
+type CombatEvent struct {
+	Time   time.Duration
+	Source string
+	Target string
+	Amount int
+	Kind   CombatKind
+}
+
+// CombatOpts controls the behavior of CombatLog.
+type CombatOpts struct {
+	// DATFile is used to reconstruct tile stacks. Recommended.
+	DATFile *dat.File
+
+	// Timeline bucket width. Defaults to 1 minute.
+	Bucket time.Duration
+}
+
+// CombatLog attributes damage numbers to creatures using reconstructed world state.
+//
+// Numbers shown over a creature are attributed to the recording player if they follow
+// a missile shot by the player, or if the player stands next to the target.
+// Damage taken by the player is attributed using "You lose N hitpoints due to an attack by X." messages.
+// Healing is only known for the player, from hit point increases (including regeneration).
+type CombatLog struct {
+	opts  CombatOpts
+	state *world.State
+
+	end    time.Duration
+	events []CombatEvent
+
+	// Operations sharing a TimeOffset are resolved together,
+	// as texts, missiles and messages for a single hit arrive in one packet.
+	at       time.Duration
+	texts    []combatText
+	missiles []combatMissile
+	messages []combatMessage
+
+	seenStats bool
+	last      data.PlayerStats
+}
+
+type combatText struct {
+	event CombatEvent
+	loc   data.Location
+}
+
+type combatMissile struct {
+	source string
+	to     data.Location
+}
+
+type combatMessage struct {
+	amount   int
+	mana     bool
+	attacker string
+}
+
+// CombatSample is a single timeline bucket.
+type CombatSample struct {
+	Start  time.Duration
+	Dealt  int
+	Taken  int
+	Healed int
+
+	DPS  float64 // Dealt per second.
+	DTPS float64 // Taken per second.
+}
+
+// CombatReport summarizes combat over a recording.
+type CombatReport struct {
+	Player string
+	Events []CombatEvent
+
+	Dealt  int // Damage dealt by the player.
+	Taken  int // Damage taken by the player.
+	Healed int // Healing received by the player.
+
+	DealtByKind   map[CombatKind]int
+	TakenByKind   map[CombatKind]int
+	DealtByTarget map[string]int
+	TakenBySource map[string]int
+
+	Duration time.Duration
+	DPS      float64
+	DTPS     float64
+
+	Timeline []CombatSample
+}
+
+// NewCombatLog initializes a CombatLog. opts may be nil.
+func NewCombatLog(opts *CombatOpts) *CombatLog {
+	l := &CombatLog{}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.Bucket <= 0 {
+		l.opts.Bucket = time.Minute
+	}
+	l.state = world.New(l.opts.DATFile)
+	return l
+}
+
+// Add processes the next operation. All operation types are needed to track creatures.
+func (l *CombatLog) Add(op data.Operation) {
+	offset := data.TimeOffsetOf(op)
+	l.end = max(l.end, offset)
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	if offset != l.at {
+		l.events = append(l.events, l.resolve()...)
+		l.texts, l.missiles, l.messages = nil, nil, nil
+		l.at = offset
+	}
+
+	switch op := op.(type) {
+	case data.EffectText:
+		l.addText(op)
+	case data.EffectMissile:
+		source, _ := l.state.CreatureAt(op.From)
+		l.missiles = append(l.missiles, combatMissile{source: source.Name, to: op.To})
+	case data.Message:
+		l.addMessage(op)
+	case data.PlayerStats:
+		l.addStats(op)
+	}
+	l.state.Apply(op)
+}
+
+func (l *CombatLog) addText(op data.EffectText) {
+	amount, err := strconv.Atoi(op.Text)
+	if err != nil || op.Color == expTextColor {
+		return
+	}
+	target, _ := l.state.CreatureAt(op.Location)
+	l.texts = append(l.texts, combatText{
+		event: CombatEvent{Time: op.TimeOffset, Target: target.Name, Amount: amount, Kind: textColorKind[op.Color]},
+		loc:   op.Location,
+	})
+}
+
+func (l *CombatLog) addMessage(op data.Message) {
+	m := loseRe.FindStringSubmatch(op.Text)
+	if m == nil {
+		return
+	}
+	amount, err := strconv.Atoi(m[1])
+	if err != nil {
+		return
+	}
+	l.messages = append(l.messages, combatMessage{amount: amount, mana: m[2] == "mana", attacker: trimArticle(m[3])})
+}
+
+func (l *CombatLog) addStats(op data.PlayerStats) {
+	if l.seenStats && op.MaxHP == l.last.MaxHP && op.HP > l.last.HP {
+		player := l.playerName()
+		l.events = append(l.events, CombatEvent{
+			Time:   op.TimeOffset,
+			Source: player,
+			Target: player,
+			Amount: int(op.HP - l.last.HP),
+			Kind:   CombatHealing,
+		})
+	}
+	l.seenStats = true
+	l.last = op
+}
+
+func (l *CombatLog) playerName() string {
+	p, _ := l.state.Player()
+	return p.Name
+}
+
+// resolve turns the pending texts and messages into events, without modifying them.
+func (l *CombatLog) resolve() []CombatEvent {
+	player := l.playerName()
+	pos := l.state.PlayerPos()
+
+	var ret []CombatEvent
+	matched := make([]bool, len(l.texts))
+	for _, t := range l.texts {
+		ret = append(ret, t.event)
+	}
+
+	for _, m := range l.messages {
+		found := false
+		for i, t := range l.texts {
+			if matched[i] || t.event.Target != player || t.event.Amount != m.amount {
+				continue
+			}
+			matched[i], found = true, true
+			ret[i].Source = m.attacker
+			break
+		}
+		if !found {
+			kind := CombatUnknown
+			if m.mana {
+				kind = CombatMana
+			}
+			ret = append(ret, CombatEvent{Time: l.at, Source: m.attacker, Target: player, Amount: m.amount, Kind: kind})
+		}
+	}
+
+	for i, t := range l.texts {
+		if matched[i] {
+			continue
+		}
+		for _, m := range l.missiles {
+			if m.to == t.loc {
+				ret[i].Source = m.source
+				break
+			}
+		}
+		if ret[i].Source == "" && ret[i].Target != player && adjacent(pos, t.loc) {
+			ret[i].Source = player
+		}
+	}
+	return ret
+}
+
+func adjacent(a, b data.Location) bool {
+	return a.Z == b.Z && max(a.X-b.X, b.X-a.X) <= 1 && max(a.Y-b.Y, b.Y-a.Y) <= 1
+}
+
+// Report summarizes all operations added so far.
+func (l *CombatLog) Report() CombatReport {
+	r := CombatReport{
+		Player:        l.playerName(),
+		Events:        append(append([]CombatEvent(nil), l.events...), l.resolve()...),
+		DealtByKind:   map[CombatKind]int{},
+		TakenByKind:   map[CombatKind]int{},
+		DealtByTarget: map[string]int{},
+		TakenBySource: map[string]int{},
+		Duration:      l.end,
+	}
+
+	buckets := 1
+	if l.end > 0 {
+		buckets = int((l.end-1)/l.opts.Bucket) + 1
+	}
+	r.Timeline = make([]CombatSample, buckets)
+	for i := range r.Timeline {
+		r.Timeline[i].Start = time.Duration(i) * l.opts.Bucket
+	}
+
+	for _, e := range r.Events {
+		// Tick regressions in edited recordings give negative offsets; count them in the first bucket.
+		s := &r.Timeline[max(0, min(int(e.Time/l.opts.Bucket), buckets-1))]
+		switch {
+		case e.Kind == CombatHealing:
+			if e.Target == r.Player {
+				r.Healed += e.Amount
+				s.Healed += e.Amount
+			}
+		case e.Target == r.Player:
+			r.Taken += e.Amount
+			r.TakenByKind[e.Kind] += e.Amount
+			r.TakenBySource[e.Source] += e.Amount
+			s.Taken += e.Amount
+		case e.Source == r.Player:
+			r.Dealt += e.Amount
+			r.DealtByKind[e.Kind] += e.Amount
+			r.DealtByTarget[e.Target] += e.Amount
+			s.Dealt += e.Amount
+		}
+	}
+
+	r.DPS = perSecond(r.Dealt, r.Duration)
+	r.DTPS = perSecond(r.Taken, r.Duration)
+	for i := range r.Timeline {
+		s := &r.Timeline[i]
+		width := min(l.end, s.Start+l.opts.Bucket) - s.Start
+		s.DPS = perSecond(s.Dealt, width)
+		s.DTPS = perSecond(s.Taken, width)
+	}
+	return r
+}
+
+func perSecond(amount int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(amount) / d.Seconds()
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func TestCombatLog(t *testing.T) {
+	sec := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	pos := data.Location{X: 100, Y: 100, Z: 7}
+	east := data.Location{X: 101, Y: 100, Z: 7}
+	far := data.Location{X: 104, Y: 102, Z: 7}
+	creature := func(id uint32, name string) data.Thing {
+		return data.Thing{HasCreature: true, Creature: data.Creature{ID: id, Name: name, Health: 100}}
+	}
+	stats := func(s int, hp uint16) data.PlayerStats {
+		return data.PlayerStats{TimeOffset: sec(s), HP: hp, MaxHP: 200}
+	}
+
+	log := NewCombatLog(&CombatOpts{Bucket: 30 * time.Second})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{
+			{Location: pos, Things: []data.Thing{creature(1, "Player")}},
+			{Location: east, Things: []data.Thing{creature(2, "rat")}},
+			{Location: far, Things: []data.Thing{creature(3, "cobra")}},
+		}},
+		stats(0, 200),
+		// Melee hit on an adjacent creature.
+		data.EffectText{TimeOffset: sec(1), Location: east, Color: 180, Text: "12"},
+		// Hit taken, attributed by the message.
+		data.Message{TimeOffset: sec(2), Text: "You lose 7 hitpoints due to an attack by a rat."},
+		data.EffectText{TimeOffset: sec(2), Location: pos, Color: 180, Text: "7"},
+		stats(2, 193),
+		// Ranged hit, attributed by the missile.
+		data.EffectMissile{TimeOffset: sec(3), From: pos, To: far, Effect: 4},
+		data.EffectText{TimeOffset: sec(3), Location: far, Color: 198, Text: "30"},
+		// Condition damage without an attacker.
+		data.EffectText{TimeOffset: sec(4), Location: pos, Color: 30, Text: "1"},
+		data.Message{TimeOffset: sec(4), Text: "You lose 1 hitpoint."},
+		stats(4, 192),
+		// Experience isn't damage.
+		data.EffectText{TimeOffset: sec(5), Location: pos, Color: expTextColor, Text: "20"},
+		stats(40, 197),
+		data.Message{TimeOffset: sec(45), Text: "You lose 15 mana due to an attack by a cobra."},
+		data.CamMetadata{Duration: sec(60)},
+	} {
+		log.Add(op)
+	}
+
+	want := CombatReport{
+		Player: "Player",
+		Events: []CombatEvent{
+			{Time: sec(1), Source: "Player", Target: "rat", Amount: 12, Kind: CombatPhysical},
+			{Time: sec(2), Source: "rat", Target: "Player", Amount: 7, Kind: CombatPhysical},
+			{Time: sec(3), Source: "Player", Target: "cobra", Amount: 30, Kind: CombatFire},
+			{Time: sec(4), Target: "Player", Amount: 1, Kind: CombatPoison},
+			{Time: sec(40), Source: "Player", Target: "Player", Amount: 5, Kind: CombatHealing},
+			{Time: sec(45), Source: "cobra", Target: "Player", Amount: 15, Kind: CombatMana},
+		},
+		Dealt:         42,
+		Taken:         23,
+		Healed:        5,
+		DealtByKind:   map[CombatKind]int{CombatPhysical: 12, CombatFire: 30},
+		TakenByKind:   map[CombatKind]int{CombatPhysical: 7, CombatPoison: 1, CombatMana: 15},
+		DealtByTarget: map[string]int{"rat": 12, "cobra": 30},
+		TakenBySource: map[string]int{"rat": 7, "": 1, "cobra": 15},
+		Duration:      sec(60),
+		DPS:           0.7,
+		DTPS:          23.0 / 60,
+		Timeline: []CombatSample{
+			{Start: 0, Dealt: 42, Taken: 8, DPS: 1.4, DTPS: 8.0 / 30},
+			{Start: sec(30), Taken: 15, Healed: 5, DTPS: 0.5},
+		},
+	}
+	if diff := cmp.Diff(want, log.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestCombatLog_NegativeOffset(t *testing.T) {
+	pos := data.Location{X: 100, Y: 100, Z: 7}
+	log := NewCombatLog(&CombatOpts{Bucket: time.Minute})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{{Location: pos, Things: []data.Thing{
+			{HasCreature: true, Creature: data.Creature{ID: 1, Name: "Player", Health: 100}},
+		}}}},
+		// A tick regression before the first packet, as in edited recordings.
+		data.Message{TimeOffset: -5 * time.Minute, Text: "You lose 7 hitpoints due to an attack by a rat."},
+		data.CamMetadata{Duration: 2 * time.Minute},
+	} {
+		log.Add(op)
+	}
+	if r := log.Report(); r.Timeline[0].Taken != 7 {
+		t.Errorf("Report() Timeline = %+v, want 7 taken in the first bucket", r.Timeline)
+	}
+}
+
+func TestCombatKind_String(t *testing.T) {
+	for k, want := range map[CombatKind]string{
+		CombatFire:     "fire",
+		CombatKind(99): "CombatKind(99)",
+	} {
+		if got := k.String(); got != want {
+			t.Errorf("CombatKind(%d).String() = %q; want %q", int(k), got, want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// DialogueOpts controls the behavior of DialogueExtractor.
+type DialogueOpts struct {
+	// NPC lines up to Window after a player's message answer it. Defaults to 3 seconds.
+	Window time.Duration
+
+	// Conversations idle for longer than Timeout start over. Defaults to 1 minute.
+	Timeout time.Duration
+}
+
+// DialogueExchange is a player keyword with the NPC's response.
+type DialogueExchange struct {
+	Recording string
+	Time      time.Duration
+	Player    string
+
+	// Keyword is the lowercased player message. It's empty for lines not answering the player,
+	// e.g. a farewell when they walk away.
+	Keyword string
+
+	// Response holds the NPC lines, with the player's name replaced by "%N" as in NPC scripts.
+	// Lines ending with "..." are continued by the next one.
+	Response string
+}
+
+// DialogueEdge is a keyword leading from one response to another.
+type DialogueEdge struct {
+	Keyword string
+	To      int // Index into NPCDialogue.Nodes.
+	Count   int // How many times the exchange was seen.
+}
+
+// DialogueNode is a distinct NPC response with the keywords seen after it.
+type DialogueNode struct {
+	Text  string
+	Edges []DialogueEdge
+}
+
+// NPCDialogue holds everything an NPC was heard saying.
+type NPCDialogue struct {
+	Name      string
+	Locations []data.Location // Where the NPC spoke from.
+	Exchanges []DialogueExchange
+
+	// Nodes is the keyword graph merged across recordings. Nodes[0] is the start of a conversation and has no text.
+	Nodes []DialogueNode
+}
+
+// DialogueReport lists NPC dialogues, ordered by NPC name.
+type DialogueReport struct {
+	NPCs []*NPCDialogue
+}
+
+// DialogueExtractor pairs player keywords with NPC responses across many recordings.
+//
+// NPCs are told apart from players and monsters with CreatureKindOf, using creatures seen on the map.
+// A line said by an NPC answers the last player message said next to it within opts.Window, unless the
+// NPC's previous line ended with "..." and this one continues it.
+//
+// Like CreatureCatalog, a single DialogueExtractor is fed with every recording.
+type DialogueExtractor struct {
+	opts DialogueOpts
+	recs recordings[*dialogueRecording]
+}
+
+type dialogueRecording struct {
+	opts DialogueOpts
+
+	kinds map[string]CreatureKind // By creature name.
+	said  []dialogueMessage       // Recent player messages.
+
+	exchanges map[string][]DialogueExchange // By NPC name.
+	last      map[string]dialogueMessage    // The last line of every NPC.
+	locs      map[string]map[data.Location]bool
+}
+
+type dialogueMessage struct {
+	time time.Duration
+	name string
+	loc  data.Location
+	text string
+}
+
+// NewDialogueExtractor initializes a DialogueExtractor. opts may be nil.
+func NewDialogueExtractor(opts *DialogueOpts) *DialogueExtractor {
+	x := &DialogueExtractor{}
+	if opts != nil {
+		x.opts = *opts
+	}
+	if x.opts.Window <= 0 {
+		x.opts.Window = 3 * time.Second
+	}
+	if x.opts.Timeout <= 0 {
+		x.opts.Timeout = time.Minute
+	}
+	return x
+}
+
+// Add processes the next operation of the recording at path.
+// CreatureMessage and operations carrying creatures (map and tile updates) are needed.
+// It never fails; the error is there to match batch.ParseAll.
+func (x *DialogueExtractor) Add(path string, op data.Operation) error {
+	rec, _ := x.recs.get(path, func() (*dialogueRecording, error) {
+		return &dialogueRecording{
+			opts:      x.opts,
+			kinds:     map[string]CreatureKind{},
+			exchanges: map[string][]DialogueExchange{},
+			last:      map[string]dialogueMessage{},
+			locs:      map[string]map[data.Location]bool{},
+		}, nil
+	})
+
+	for _, c := range creaturesOf(op) {
+		if c.Name != "" {
+			rec.kinds[c.Name] = CreatureKindOf(c)
+		}
+	}
+	if m, ok := op.(data.CreatureMessage); ok && m.Type == data.SpeakSay && m.Location != nil {
+		rec.addMessage(path, dialogueMessage{time: m.TimeOffset, name: m.Name, loc: *m.Location, text: m.Text})
+	}
+	return nil
+}
+
+// dialogueRange is how far from an NPC players can talk to it.
+const dialogueRange = 4
+
+func (r *dialogueRecording) addMessage(path string, m dialogueMessage) {
+	for len(r.said) > 0 && m.time-r.said[0].time > r.opts.Window {
+		r.said = r.said[1:]
+	}
+	kind, ok := r.kinds[m.name]
+	switch {
+	case !ok:
+		return
+	case kind == CreaturePlayer:
+		r.said = append(r.said, m)
+		return
+	case kind != CreatureNPC:
+		return
+	}
+
+	if r.locs[m.name] == nil {
+		r.locs[m.name] = map[data.Location]bool{}
+	}
+	r.locs[m.name][m.loc] = true
+
+	last, talked := r.last[m.name]
+	r.last[m.name] = m
+	exchanges := r.exchanges[m.name]
+	if talked && strings.HasSuffix(last.text, "...") && m.time-last.time <= r.opts.Timeout {
+		e := &exchanges[len(exchanges)-1]
+		e.Response += " " + withPlaceholder(m.text, e.Player)
+		return
+	}
+
+	// Only player messages since the NPC's last line are unanswered.
+	e := DialogueExchange{Recording: path, Time: m.time}
+	for _, s := range slices.Backward(r.said) {
+		if talked && s.time < last.time {
+			break
+		}
+		if s.loc.Z == m.loc.Z && max(abs(s.loc.X-m.loc.X), abs(s.loc.Y-m.loc.Y)) <= dialogueRange {
+			e.Player, e.Keyword = s.name, strings.ToLower(strings.TrimSpace(s.text))
+			break
+		}
+	}
+	if e.Player == "" && len(exchanges) > 0 {
+		e.Player = exchanges[len(exchanges)-1].Player
+	}
+	e.Response = withPlaceholder(m.text, e.Player)
+	r.exchanges[m.name] = append(exchanges, e)
+}
+
+// withPlaceholder replaces the player's name in an NPC line with "%N".
+func withPlaceholder(text, player string) string {
+	if player == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, player, "%N")
+}
+
+// Report merges dialogues from all recordings.
+func (x *DialogueExtractor) Report() DialogueReport {
+	npcs := map[string]*NPCDialogue{}
+	locs := map[string]map[data.Location]bool{}
+	for _, rec := range x.recs.all() {
+		for name, exchanges := range rec.exchanges {
+			n, ok := npcs[name]
+			if !ok {
+				n = &NPCDialogue{Name: name}
+				npcs[name] = n
+				locs[name] = map[data.Location]bool{}
+			}
+			n.Exchanges = append(n.Exchanges, exchanges...)
+			for l := range rec.locs[name] {
+				locs[name][l] = true
+			}
+		}
+	}
+
+	var r DialogueReport
+	for name, n := range npcs {
+		for l := range locs[name] {
+			n.Locations = append(n.Locations, l)
+		}
+		slices.SortFunc(n.Locations, func(a, b data.Location) int {
+			return cmp.Or(cmp.Compare(a.Z, b.Z), cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+		})
+		slices.SortStableFunc(n.Exchanges, func(a, b DialogueExchange) int {
+			return cmp.Or(cmp.Compare(a.Recording, b.Recording), cmp.Compare(a.Time, b.Time))
+		})
+		n.Nodes = x.graph(n.Exchanges)
+		r.NPCs = append(r.NPCs, n)
+	}
+	slices.SortFunc(r.NPCs, func(a, b *NPCDialogue) int { return cmp.Compare(a.Name, b.Name) })
+	return r
+}
+
+// graph merges exchanges, sorted by recording and time, into a keyword graph.
+func (x *DialogueExtractor) graph(exchanges []DialogueExchange) []DialogueNode {
+	nodes := []DialogueNode{{}}
+	byText := map[string]int{}
+	cur := 0
+	var prev DialogueExchange
+	for i, e := range exchanges {
+		if i == 0 || e.Recording != prev.Recording || e.Player != prev.Player || e.Time-prev.Time > x.opts.Timeout {
+			cur = 0
+		}
+		to, ok := byText[e.Response]
+		if !ok {
+			to = len(nodes)
+			byText[e.Response] = to
+			nodes = append(nodes, DialogueNode{Text: e.Response})
+		}
+
+		edges := nodes[cur].Edges
+		if j := slices.IndexFunc(edges, func(d DialogueEdge) bool { return d.Keyword == e.Keyword && d.To == to }); j >= 0 {
+			edges[j].Count++
+		} else {
+			nodes[cur].Edges = append(edges, DialogueEdge{Keyword: e.Keyword, To: to, Count: 1})
+		}
+
+		cur, prev = to, e
+		if e.Keyword == "" {
+			// A line without a keyword ends the conversation, or isn't part of one.
+			cur = 0
+		}
+	}
+	return nodes
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r DialogueReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the keyword graph of every NPC as Markdown, for documenting NPC scripts.
+// Responses are numbered as in NPCDialogue.Nodes.
+func (r DialogueReport) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# NPC dialogues")
+	for _, n := range r.NPCs {
+		fmt.Fprintf(bw, "\n## %s\n\n", n.Name)
+		var locs []string
+		for _, l := range n.Locations {
+			locs = append(locs, l.String())
+		}
+		fmt.Fprintf(bw, "Seen at %s.\n\n", strings.Join(locs, ", "))
+
+		for i, node := range n.Nodes {
+			if i == 0 {
+				fmt.Fprintln(bw, "- **0** *(start)*")
+			} else {
+				fmt.Fprintf(bw, "- **%d** %s\n", i, node.Text)
+			}
+			for _, e := range node.Edges {
+				keyword := fmt.Sprintf("%q", e.Keyword)
+				if e.Keyword == "" {
+					keyword = "*(no keyword)*"
+				}
+				count := ""
+				if e.Count > 1 {
+					count = fmt.Sprintf(" (%d×)", e.Count)
+				}
+				fmt.Fprintf(bw, "  - %s → **%d**%s\n", keyword, e.To, count)
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/data"
+)
+
+func TestDialogueExtractor(t *testing.T) {
+	b, err := os.ReadFile("../cam/testdata/tibiantis.cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := NewDialogueExtractor(nil)
+	for op, err := range cam.Parse(bytes.NewReader(b), &cam.ParseOpts{DATFile: readDAT(t, "Tibiantis.dat")}) {
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if err := x.Add("tibiantis.cam", op); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	r := x.Report()
+
+	var muzir *NPCDialogue
+	for _, n := range r.NPCs {
+		if n.Name == "Muzir" {
+			muzir = n
+		}
+		if n.Name == "Shy Teddy" || n.Name == "hyaena" {
+			t.Errorf("Report() has dialogue of %q, want NPCs only", n.Name)
+		}
+	}
+	if muzir == nil {
+		t.Fatalf("Report() has no dialogue of Muzir")
+	}
+
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	exchange := func(at time.Duration, keyword, response string) DialogueExchange {
+		return DialogueExchange{Recording: "tibiantis.cam", Time: at, Player: "Shy Teddy", Keyword: keyword, Response: response}
+	}
+	want := &NPCDialogue{
+		Name:      "Muzir",
+		Locations: []data.Location{{X: 33221, Y: 32389, Z: 7}},
+		Exchanges: []DialogueExchange{
+			exchange(2*time.Minute+ms(6625), "hi", "Welcome %N! Daraman's blessings."),
+			exchange(2*time.Minute+ms(8312), "change gold", "How many platinum coins do you want to get?"),
+			exchange(2*time.Minute+ms(16953), "yes", "Here you are."),
+			exchange(2*time.Minute+ms(18922), "", "Daraman's blessings."),
+		},
+		Nodes: []DialogueNode{
+			{Edges: []DialogueEdge{{Keyword: "hi", To: 1, Count: 1}}},
+			{Text: "Welcome %N! Daraman's blessings.", Edges: []DialogueEdge{{Keyword: "change gold", To: 2, Count: 1}}},
+			{Text: "How many platinum coins do you want to get?", Edges: []DialogueEdge{{Keyword: "yes", To: 3, Count: 1}}},
+			{Text: "Here you are.", Edges: []DialogueEdge{{Keyword: "", To: 4, Count: 1}}},
+			{Text: "Daraman's blessings."},
+		},
+	}
+	if diff := cmp.Diff(want, muzir); diff != "" {
+		t.Errorf("Report() Muzir diff; -want +got:\n%v", diff)
+	}
+
+	// Continued lines are joined.
+	for _, n := range r.NPCs {
+		for _, e := range n.Exchanges {
+			if strings.HasSuffix(e.Response, "...") {
+				t.Errorf("Report() %s exchange %q -> %q isn't continued", n.Name, e.Keyword, e.Response)
+			}
+		}
+	}
+}
+
+func TestDialogueReport_Write(t *testing.T) {
+	x := NewDialogueExtractor(nil)
+	loc := data.Location{X: 100, Y: 100, Z: 7}
+	say := func(t int, name, text string) data.CreatureMessage {
+		return data.CreatureMessage{TimeOffset: time.Duration(t) * time.Second, Name: name, Type: data.SpeakSay, Location: &loc, Text: text}
+	}
+	for _, rec := range []string{"a.cam", "b.cam"} {
+		for _, op := range []data.Operation{
+			data.Map{PlayerPos: loc, Tiles: []data.Tile{{Location: loc, Things: []data.Thing{
+				{HasCreature: true, Creature: data.Creature{ID: 1, Name: "Hunter"}},
+				{HasCreature: true, Creature: data.Creature{ID: 0x40000001, Name: "Sam"}},
+			}}}},
+			say(1, "Hunter", "Hi"),
+			say(2, "Sam", "Welcome, Hunter! ..."),
+			say(4, "Sam", "What do you need?"),
+			say(5, "Hunter", "bye"),
+			say(6, "Sam", "Good bye."),
+		} {
+			x.Add(rec, op)
+		}
+	}
+
+	var md bytes.Buffer
+	if err := x.Report().WriteMarkdown(&md); err != nil {
+		t.Fatalf("WriteMarkdown() error: %v", err)
+	}
+	want := `# NPC dialogues
+
+## Sam
+
+Seen at (100,100,7).
+
+- **0** *(start)*
+  - "hi" → **1** (2×)
+- **1** Welcome, %N! ... What do you need?
+  - "bye" → **2** (2×)
+- **2** Good bye.
+`
+	if diff := cmp.Diff(want, md.String()); diff != "" {
+		t.Errorf("WriteMarkdown() diff; -want +got:\n%v", diff)
+	}
+
+	var js bytes.Buffer
+	if err := x.Report().WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON() error: %v", err)
+	}
+	if !strings.Contains(js.String(), `"Keyword": "bye"`) {
+		t.Errorf("WriteJSON() = %s, want the keywords", js.String())
+	}
+}
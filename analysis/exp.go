@@ -0,0 +1,206 @@
+// Package analysis derives higher-level reports from parsed recordings.
+//
+// Analyzers consume data.Operations one by one through Add, in recording order,
+// and summarize them with Report.
+package analysis
+
+import (
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// ExpOpts controls the behavior of ExpTracker.
+type ExpOpts struct {
+	// Gaps between movements longer than IdleAfter count as idle time.
+	// Defaults to 2 minutes.
+	IdleAfter time.Duration
+
+	// Timeline bucket width. Defaults to 5 minutes.
+	Bucket time.Duration
+}
+
+// ExpTracker follows PlayerStats to measure experience and level progress.
+type ExpTracker struct {
+	opts ExpOpts
+
+	seenStats bool
+	last      data.PlayerStats
+	report    ExpReport
+
+	end   time.Duration
+	moves []time.Duration
+	gains []expGain
+}
+
+type expGain struct {
+	at     time.Duration
+	amount int64
+}
+
+// LevelChange records a level (or magic level) change.
+type LevelChange struct {
+	TimeOffset time.Duration
+	From, To   byte
+}
+
+// ExpSample is a single timeline bucket.
+type ExpSample struct {
+	Start   time.Duration
+	Gained  int64
+	Active  time.Duration
+	PerHour float64 // Gained per hour of Active time.
+}
+
+// ExpReport summarizes experience progress over a recording.
+type ExpReport struct {
+	StartExp, EndExp     uint32
+	StartLevel, EndLevel byte
+
+	Gained int64 // Sum of experience increases.
+	Lost   int64 // Sum of experience decreases, e.g. on death.
+
+	Duration time.Duration
+	Active   time.Duration
+	Idle     time.Duration
+
+	// Gained per hour of Active time.
+	PerHour float64
+
+	Timeline        []ExpSample
+	LevelUps        []LevelChange
+	MagicLevelUps   []LevelChange
+	LevelDowns      []LevelChange
+	MagicLevelDowns []LevelChange
+}
+
+// NewExpTracker initializes an ExpTracker. opts may be nil.
+func NewExpTracker(opts *ExpOpts) *ExpTracker {
+	t := &ExpTracker{}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.IdleAfter <= 0 {
+		t.opts.IdleAfter = 2 * time.Minute
+	}
+	if t.opts.Bucket <= 0 {
+		t.opts.Bucket = 5 * time.Minute
+	}
+	return t
+}
+
+// Add processes the next operation.
+func (t *ExpTracker) Add(op data.Operation) {
+	offset := data.TimeOffsetOf(op)
+	t.end = max(t.end, offset)
+
+	switch op := op.(type) {
+	case data.MoveNorth, data.MoveEast, data.MoveSouth, data.MoveWest, data.MoveFloorUp, data.MoveFloorDown:
+		t.moves = append(t.moves, offset)
+	case data.PlayerStats:
+		t.addStats(op)
+	}
+}
+
+func (t *ExpTracker) addStats(op data.PlayerStats) {
+	r := &t.report
+	if !t.seenStats {
+		t.seenStats = true
+		t.last = op
+		r.StartExp, r.StartLevel = op.Exp, op.Level
+		r.EndExp, r.EndLevel = op.Exp, op.Level
+		return
+	}
+
+	if delta := int64(op.Exp) - int64(t.last.Exp); delta > 0 {
+		r.Gained += delta
+		t.gains = append(t.gains, expGain{at: op.TimeOffset, amount: delta})
+	} else if delta < 0 {
+		r.Lost -= delta
+	}
+
+	if op.Level > t.last.Level {
+		r.LevelUps = append(r.LevelUps, LevelChange{TimeOffset: op.TimeOffset, From: t.last.Level, To: op.Level})
+	} else if op.Level < t.last.Level {
+		r.LevelDowns = append(r.LevelDowns, LevelChange{TimeOffset: op.TimeOffset, From: t.last.Level, To: op.Level})
+	}
+	if op.MagicLvl > t.last.MagicLvl {
+		r.MagicLevelUps = append(r.MagicLevelUps, LevelChange{TimeOffset: op.TimeOffset, From: t.last.MagicLvl, To: op.MagicLvl})
+	} else if op.MagicLvl < t.last.MagicLvl {
+		r.MagicLevelDowns = append(r.MagicLevelDowns, LevelChange{TimeOffset: op.TimeOffset, From: t.last.MagicLvl, To: op.MagicLvl})
+	}
+
+	r.EndExp, r.EndLevel = op.Exp, op.Level
+	t.last = op
+}
+
+// Report summarizes all operations added so far.
+func (t *ExpTracker) Report() ExpReport {
+	r := t.report
+	r.Duration = t.end
+
+	active := activeIntervals(t.moves, t.end, t.opts.IdleAfter)
+	for _, iv := range active {
+		r.Active += iv.end - iv.start
+	}
+	r.Idle = r.Duration - r.Active
+	r.PerHour = perHour(r.Gained, r.Active)
+
+	buckets := 1
+	if t.end > 0 {
+		buckets = int((t.end-1)/t.opts.Bucket) + 1
+	}
+	r.Timeline = make([]ExpSample, buckets)
+	for i := range r.Timeline {
+		start := time.Duration(i) * t.opts.Bucket
+		end := start + t.opts.Bucket
+		r.Timeline[i].Start = start
+		for _, iv := range active {
+			if s, e := max(iv.start, start), min(iv.end, end); e > s {
+				r.Timeline[i].Active += e - s
+			}
+		}
+	}
+	for _, g := range t.gains {
+		// Tick regressions in edited recordings give negative offsets; count them in the first bucket.
+		r.Timeline[max(0, min(int(g.at/t.opts.Bucket), buckets-1))].Gained += g.amount
+	}
+	for i := range r.Timeline {
+		r.Timeline[i].PerHour = perHour(r.Timeline[i].Gained, r.Timeline[i].Active)
+	}
+
+	return r
+}
+
+type interval struct {
+	start, end time.Duration
+}
+
+// activeIntervals merges activity timestamps into intervals, treating gaps longer than idleAfter as idle.
+// The recording start and end count as activity boundaries, subject to the same rule.
+func activeIntervals(points []time.Duration, end, idleAfter time.Duration) []interval {
+	var ret []interval
+	prev := time.Duration(0)
+	for i := 0; i <= len(points); i++ {
+		p := end
+		if i < len(points) {
+			p = points[i]
+		}
+		if p-prev <= idleAfter && p > prev {
+			if n := len(ret); n > 0 && ret[n-1].end == prev {
+				ret[n-1].end = p
+			} else {
+				ret = append(ret, interval{start: prev, end: p})
+			}
+		}
+		prev = max(prev, p)
+	}
+	return ret
+}
+
+func perHour(amount int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(amount) / d.Hours()
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func TestExpTracker(t *testing.T) {
+	stats := func(m int, exp uint32, level, mlvl byte) data.PlayerStats {
+		return data.PlayerStats{TimeOffset: time.Duration(m) * time.Minute, Exp: exp, Level: level, MagicLvl: mlvl}
+	}
+	move := func(m int) data.MoveNorth {
+		return data.MoveNorth{TimeOffset: time.Duration(m) * time.Minute}
+	}
+
+	tracker := NewExpTracker(&ExpOpts{IdleAfter: 2 * time.Minute, Bucket: 10 * time.Minute})
+	for _, op := range []data.Operation{
+		stats(0, 1000, 8, 1),
+		move(1),
+		move(2),
+		stats(3, 1500, 9, 1),
+		move(4),
+		// Idle between 4m and 14m.
+		move(14),
+		stats(15, 1400, 9, 2),
+		move(16),
+		stats(16, 2400, 10, 2),
+		data.CamMetadata{Duration: 20 * time.Minute},
+	} {
+		tracker.Add(op)
+	}
+
+	want := ExpReport{
+		StartExp:   1000,
+		EndExp:     2400,
+		StartLevel: 8,
+		EndLevel:   10,
+		Gained:     1500,
+		Lost:       100,
+		Duration:   20 * time.Minute,
+		Active:     6 * time.Minute,
+		Idle:       14 * time.Minute,
+		PerHour:    15000,
+		Timeline: []ExpSample{
+			{Start: 0, Gained: 500, Active: 4 * time.Minute, PerHour: 7500},
+			{Start: 10 * time.Minute, Gained: 1000, Active: 2 * time.Minute, PerHour: 30000},
+		},
+		LevelUps: []LevelChange{
+			{TimeOffset: 3 * time.Minute, From: 8, To: 9},
+			{TimeOffset: 16 * time.Minute, From: 9, To: 10},
+		},
+		MagicLevelUps: []LevelChange{
+			{TimeOffset: 15 * time.Minute, From: 1, To: 2},
+		},
+	}
+	if diff := cmp.Diff(want, tracker.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestExpTracker_NegativeOffset(t *testing.T) {
+	// A tick regression before the first packet, as in edited recordings.
+	tracker := NewExpTracker(&ExpOpts{Bucket: time.Minute})
+	for _, op := range []data.Operation{
+		data.PlayerStats{Exp: 1000, Level: 8},
+		data.PlayerStats{TimeOffset: -5 * time.Minute, Exp: 1100, Level: 8},
+		data.CamMetadata{Duration: 2 * time.Minute},
+	} {
+		tracker.Add(op)
+	}
+	if r := tracker.Report(); r.Timeline[0].Gained != 100 {
+		t.Errorf("Report() Timeline = %+v, want 100 gained in the first bucket", r.Timeline)
+	}
+}
+
+func TestActiveIntervals(t *testing.T) {
+	m := func(n int) time.Duration { return time.Duration(n) * time.Minute }
+
+	got := activeIntervals([]time.Duration{m(1), m(2), m(10), m(11)}, m(20), m(2))
+	want := []interval{{start: m(0), end: m(2)}, {start: m(10), end: m(11)}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(interval{})); diff != "" {
+		t.Errorf("activeIntervals() diff; -want +got:\n%v", diff)
+	}
+}
@@ -0,0 +1,281 @@
+package analysis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// ItemFlowOpts controls the behavior of ItemFlowTracker.
+type ItemFlowOpts struct {
+	// DATFile is used to reconstruct tile stacks, to tell stackable items from others and containers lying
+	// on the ground, e.g. corpses, from the player's. Recommended; without it, every container is the player's.
+	DATFile *dat.File
+}
+
+// ItemFlowKind classifies an ItemFlow.
+type ItemFlowKind int
+
+const (
+	FlowPickedUp ItemFlowKind = iota // From the ground or a container lying on it to the player.
+	FlowDropped                      // From the player to the ground or a container lying on it.
+	FlowMoved                        // Between the player's containers and inventory slots.
+	FlowUsed                         // Part of a stack or charges used up in place, or a fluid container emptied.
+	FlowConsumed                     // An item of the player gone or turned into another one without going anywhere.
+	FlowReceived                     // An item of the player coming from nowhere seen, e.g. conjured or bought.
+)
+
+var itemFlowKindName = map[ItemFlowKind]string{
+	FlowPickedUp: "picked up",
+	FlowDropped:  "dropped",
+	FlowMoved:    "moved",
+	FlowUsed:     "used",
+	FlowConsumed: "consumed",
+	FlowReceived: "received",
+}
+
+func (k ItemFlowKind) String() string {
+	if n, ok := itemFlowKindName[k]; ok {
+		return n
+	}
+	return "ItemFlowKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// PlaceKind classifies an ItemPlace.
+type PlaceKind int
+
+const (
+	PlaceTile PlaceKind = iota
+	PlaceContainer
+	PlaceInventory
+)
+
+// ItemPlace is where an item was or went.
+type ItemPlace struct {
+	Kind PlaceKind
+
+	Location  data.Location      // For PlaceTile.
+	Container byte               // For PlaceContainer.
+	Name      string             // For PlaceContainer.
+	Slot      data.InventorySlot // For PlaceInventory.
+
+	// Ground is set for tiles and containers lying on the ground rather than carried by the player.
+	Ground bool
+}
+
+// ItemFlow is an amount of a single item changing places.
+type ItemFlow struct {
+	Time time.Duration
+	Kind ItemFlowKind
+
+	Item  data.Item // As it was before the change.
+	Count int       // Items in a stack, or charges; 1 for other items.
+
+	// From is nil for FlowReceived, To for FlowUsed and FlowConsumed.
+	From, To *ItemPlace
+}
+
+// ItemFlowReport lists item flows over a recording.
+type ItemFlowReport struct {
+	Events []ItemFlow
+
+	// Spent sums used and consumed amounts, e.g. runes cast or potions drunk, by item with Count cleared.
+	// Fluid containers keep their fluid, so that different potions are told apart.
+	Spent map[data.Item]int
+}
+
+// ItemFlowTracker follows the player's containers and inventory, and turns their changes into item flows.
+//
+// A single move removes an item from one place and adds it to another within one packet, so changes are
+// paired per TimeOffset by item ID. Changes on the ground alone, e.g. other creatures moving items around,
+// aren't item flows of the player and are left out.
+type ItemFlowTracker struct {
+	opts ItemFlowOpts
+
+	world  *world.State
+	ground map[byte]bool // By container ID.
+
+	at      time.Duration
+	login   bool // The current TimeOffset sends the initial state after logging in.
+	removed []flowChange
+	added   []flowChange
+
+	events []ItemFlow
+}
+
+type flowChange struct {
+	place   ItemPlace
+	item    data.Item
+	count   int
+	inPlace bool // A stack or charges changing rather than an item leaving or arriving.
+}
+
+// NewItemFlowTracker initializes an ItemFlowTracker. opts may be nil.
+func NewItemFlowTracker(opts *ItemFlowOpts) *ItemFlowTracker {
+	t := &ItemFlowTracker{ground: map[byte]bool{}}
+	if opts != nil {
+		t.opts = *opts
+	}
+	t.world = world.New(t.opts.DATFile)
+	return t
+}
+
+// Add processes the next operation. Operations must not be filtered by type.
+func (t *ItemFlowTracker) Add(op data.Operation) {
+	if _, ok := op.(data.CamMetadata); !ok {
+		if offset := data.TimeOffsetOf(op); offset != t.at {
+			t.resolve()
+			t.at, t.login = offset, false
+		}
+	}
+
+	switch op := op.(type) {
+	case data.LoginPlayerState:
+		t.login = true
+	case data.ContainerOpen:
+		// A container opened from within another one replaces it, and lies where its parent does.
+		ground := t.opts.DATFile != nil && !t.opts.DATFile.IsPickupable(int(op.ItemID))
+		t.ground[op.ContainerID] = ground || (op.HasParent != 0 && t.ground[op.ContainerID])
+	case data.ContainerItemAdd:
+		if c, ok := t.world.Container(op.ContainerID); ok && op.Thing.HasItem {
+			t.add(t.containerPlace(c), op.Thing.Item)
+		}
+	case data.ContainerItemUpdate:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) && op.Thing.HasItem {
+			t.replace(t.containerPlace(c), c.Items[op.Slot], op.Thing.Item)
+		}
+	case data.ContainerItemRemove:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) {
+			t.remove(t.containerPlace(c), c.Items[op.Slot])
+		}
+	case data.InventoryItemSet:
+		place := ItemPlace{Kind: PlaceInventory, Slot: op.Slot}
+		if old, ok := t.world.Inventory(op.Slot); ok {
+			t.replace(place, old, op.Item)
+		} else {
+			t.add(place, op.Item)
+		}
+	case data.InventoryItemClear:
+		if old, ok := t.world.Inventory(op.Slot); ok {
+			t.remove(ItemPlace{Kind: PlaceInventory, Slot: op.Slot}, old)
+		}
+	case data.TileItemAdd:
+		if op.Thing.HasItem {
+			t.add(ItemPlace{Kind: PlaceTile, Location: op.Location, Ground: true}, op.Thing.Item)
+		}
+	case data.TileItemUpdate:
+		if things := t.world.Tile(op.Location); int(op.StackIndex) < len(things) && things[op.StackIndex].HasItem && op.Thing.HasItem {
+			t.replace(ItemPlace{Kind: PlaceTile, Location: op.Location, Ground: true}, things[op.StackIndex].Item, op.Thing.Item)
+		}
+	case data.TileItemRemove:
+		if things := t.world.Tile(op.Location); int(op.StackIndex) < len(things) && things[op.StackIndex].HasItem {
+			t.remove(ItemPlace{Kind: PlaceTile, Location: op.Location, Ground: true}, things[op.StackIndex].Item)
+		}
+	}
+	t.world.Apply(op)
+}
+
+func (t *ItemFlowTracker) containerPlace(c world.Container) ItemPlace {
+	return ItemPlace{Kind: PlaceContainer, Container: c.ID, Name: c.Name, Ground: t.ground[c.ID]}
+}
+
+// count returns the number of items in a stack.
+func (t *ItemFlowTracker) count(it data.Item) int {
+	if t.opts.DATFile != nil && !t.opts.DATFile.IsStackable(int(it.ID)) {
+		return 1
+	}
+	return max(1, int(it.Count))
+}
+
+func (t *ItemFlowTracker) add(place ItemPlace, it data.Item) {
+	t.added = append(t.added, flowChange{place: place, item: it, count: t.count(it)})
+}
+
+func (t *ItemFlowTracker) remove(place ItemPlace, it data.Item) {
+	t.removed = append(t.removed, flowChange{place: place, item: it, count: t.count(it)})
+}
+
+// replace records an item changing in place: a stack or charges changing, a fluid container emptied,
+// or an item turning into another one.
+func (t *ItemFlowTracker) replace(place ItemPlace, old, new data.Item) {
+	if old.ID != new.ID {
+		t.remove(place, old)
+		t.add(place, new)
+		return
+	}
+	switch d := int(new.Count) - int(old.Count); {
+	case d < 0:
+		t.removed = append(t.removed, flowChange{place: place, item: old, count: -d, inPlace: true})
+	case d > 0:
+		t.added = append(t.added, flowChange{place: place, item: old, count: d, inPlace: true})
+	case old.SubType != data.FluidEmpty && new.SubType == data.FluidEmpty:
+		t.removed = append(t.removed, flowChange{place: place, item: old, count: 1, inPlace: true})
+	}
+}
+
+// resolve records flows of the current TimeOffset.
+func (t *ItemFlowTracker) resolve() {
+	t.events = append(t.events, t.pending()...)
+	t.removed, t.added = nil, nil
+}
+
+// pending pairs changes of the current TimeOffset into flows, without modifying the tracker.
+func (t *ItemFlowTracker) pending() []ItemFlow {
+	if t.login {
+		return nil
+	}
+	var ret []ItemFlow
+	left := make([]int, len(t.added))
+	for i, a := range t.added {
+		left[i] = a.count
+	}
+	for _, r := range t.removed {
+		n := r.count
+		for i, a := range t.added {
+			if n == 0 {
+				break
+			}
+			if left[i] == 0 || a.item.ID != r.item.ID || (a.place.Ground && r.place.Ground) {
+				continue
+			}
+			m := min(n, left[i])
+			n, left[i] = n-m, left[i]-m
+			kind := FlowMoved
+			switch {
+			case r.place.Ground:
+				kind = FlowPickedUp
+			case a.place.Ground:
+				kind = FlowDropped
+			}
+			ret = append(ret, ItemFlow{Time: t.at, Kind: kind, Item: r.item, Count: m, From: &r.place, To: &a.place})
+		}
+		if n == 0 || r.place.Ground {
+			continue
+		}
+		kind := FlowConsumed
+		if r.inPlace {
+			kind = FlowUsed
+		}
+		ret = append(ret, ItemFlow{Time: t.at, Kind: kind, Item: r.item, Count: n, From: &r.place})
+	}
+	for i, a := range t.added {
+		if left[i] > 0 && !a.place.Ground {
+			ret = append(ret, ItemFlow{Time: t.at, Kind: FlowReceived, Item: a.item, Count: left[i], To: &a.place})
+		}
+	}
+	return ret
+}
+
+// Report returns the flows so far.
+func (t *ItemFlowTracker) Report() ItemFlowReport {
+	r := ItemFlowReport{Events: append(t.events[:len(t.events):len(t.events)], t.pending()...), Spent: map[data.Item]int{}}
+	for _, e := range r.Events {
+		if e.Kind == FlowUsed || e.Kind == FlowConsumed {
+			r.Spent[data.Item{ID: e.Item.ID, SubType: e.Item.SubType}] += e.Count
+		}
+	}
+	return r
+}
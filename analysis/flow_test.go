@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/data"
+)
+
+func TestItemFlowTracker(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	thing := func(it data.Item) data.Thing { return data.Thing{HasItem: true, Item: it} }
+
+	// Item IDs from Tibiantis.dat.
+	var (
+		ground  = data.Item{ID: 102}
+		gold    = data.Item{ID: 3031, Count: 10}
+		vial    = data.Item{ID: 2874, SubType: data.FluidBlue}
+		rune    = data.Item{ID: 3198, Count: 3}
+		blank   = data.Item{ID: 3147}
+		coins   = data.Item{ID: 3035, Count: 2}
+		corpse  = uint16(4240)
+		bag     = uint16(2854)
+		pos     = data.Location{X: 100, Y: 100, Z: 7}
+		east    = data.Location{X: 101, Y: 100, Z: 7}
+		changed = func(it data.Item, count byte) data.Item { it.Count = count; return it }
+	)
+
+	tr := NewItemFlowTracker(&ItemFlowOpts{DATFile: readDAT(t, "Tibiantis.dat")})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{
+			{Location: pos, Things: []data.Thing{thing(ground)}},
+			{Location: east, Things: []data.Thing{thing(ground), thing(gold)}},
+		}},
+		data.InventoryItemSet{Slot: data.SlotBackpack, Item: data.Item{ID: bag}},
+		data.ContainerOpen{ContainerID: 0, ItemID: bag, Name: "backpack", Volume: 20, Items: []data.Thing{thing(vial), thing(rune)}},
+
+		data.TileItemRemove{TimeOffset: s(1), Location: east, StackIndex: 1},
+		data.ContainerItemAdd{TimeOffset: s(1), ContainerID: 0, Thing: thing(gold)},
+
+		data.ContainerItemUpdate{TimeOffset: s(2), ContainerID: 0, Slot: 1, Thing: thing(data.Item{ID: vial.ID})},
+		data.ContainerItemUpdate{TimeOffset: s(3), ContainerID: 0, Slot: 2, Thing: thing(changed(rune, 2))},
+
+		data.ContainerItemRemove{TimeOffset: s(4), ContainerID: 0, Slot: 2},
+		data.InventoryItemSet{TimeOffset: s(4), Slot: data.SlotLeft, Item: changed(rune, 2)},
+
+		data.ContainerItemUpdate{TimeOffset: s(5), ContainerID: 0, Slot: 0, Thing: thing(changed(gold, 6))},
+		data.TileItemAdd{TimeOffset: s(5), Location: east, Thing: thing(changed(gold, 4))},
+
+		data.InventoryItemClear{TimeOffset: s(6), Slot: data.SlotLeft},
+		data.InventoryItemSet{TimeOffset: s(7), Slot: data.SlotRight, Item: blank},
+		data.InventoryItemSet{TimeOffset: s(8), Slot: data.SlotRight, Item: changed(rune, 3)},
+
+		data.ContainerOpen{TimeOffset: s(9), ContainerID: 1, ItemID: corpse, Name: "dead rat", Volume: 5, Items: []data.Thing{thing(coins)}},
+		data.ContainerItemRemove{TimeOffset: s(10), ContainerID: 1, Slot: 0},
+		data.ContainerItemAdd{TimeOffset: s(10), ContainerID: 0, Thing: thing(coins)},
+
+		// Items moved around by others aren't the player's.
+		data.TileItemRemove{TimeOffset: s(11), Location: east, StackIndex: 1},
+	} {
+		tr.Add(op)
+	}
+
+	tile := &ItemPlace{Kind: PlaceTile, Location: east, Ground: true}
+	backpack := &ItemPlace{Kind: PlaceContainer, Container: 0, Name: "backpack"}
+	left := &ItemPlace{Kind: PlaceInventory, Slot: data.SlotLeft}
+	right := &ItemPlace{Kind: PlaceInventory, Slot: data.SlotRight}
+	want := ItemFlowReport{
+		Events: []ItemFlow{
+			{Time: s(1), Kind: FlowPickedUp, Item: gold, Count: 10, From: tile, To: backpack},
+			{Time: s(2), Kind: FlowUsed, Item: vial, Count: 1, From: backpack},
+			{Time: s(3), Kind: FlowUsed, Item: rune, Count: 1, From: backpack},
+			{Time: s(4), Kind: FlowMoved, Item: changed(rune, 2), Count: 1, From: backpack, To: left},
+			{Time: s(5), Kind: FlowDropped, Item: gold, Count: 4, From: backpack, To: tile},
+			{Time: s(6), Kind: FlowConsumed, Item: changed(rune, 2), Count: 1, From: left},
+			{Time: s(7), Kind: FlowReceived, Item: blank, Count: 1, To: right},
+			{Time: s(8), Kind: FlowConsumed, Item: blank, Count: 1, From: right},
+			{Time: s(8), Kind: FlowReceived, Item: changed(rune, 3), Count: 1, To: right},
+			{Time: s(10), Kind: FlowPickedUp, Item: coins, Count: 2, From: &ItemPlace{Kind: PlaceContainer, Container: 1, Name: "dead rat", Ground: true}, To: backpack},
+		},
+		Spent: map[data.Item]int{
+			{ID: vial.ID, SubType: data.FluidBlue}: 1,
+			{ID: rune.ID}:                          2,
+			{ID: blank.ID}:                         1,
+		},
+	}
+	if diff := cmp.Diff(want, tr.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestItemFlowTracker_Recording(t *testing.T) {
+	b, err := os.ReadFile("../cam/testdata/relic.cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	datFile := readDAT(t, "TibiaRelic.dat")
+	tr := NewItemFlowTracker(&ItemFlowOpts{DATFile: datFile})
+	for op, err := range cam.Parse(bytes.NewReader(b), &cam.ParseOpts{DATFile: datFile}) {
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		tr.Add(op)
+	}
+
+	// The player conjures runes from blank ones held in the left hand.
+	r := tr.Report()
+	var conjured bool
+	for _, e := range r.Events {
+		if e.Kind == FlowConsumed && e.Item.ID == 3147 && e.From.Slot == data.SlotLeft {
+			conjured = true
+		}
+		if e.Count <= 0 {
+			t.Errorf("Report() has %+v, want a positive count", e)
+		}
+	}
+	if !conjured {
+		t.Errorf("Report() has no blank rune consumed in the left hand")
+	}
+	if r.Spent[data.Item{ID: 3147}] == 0 {
+		t.Errorf("Report() Spent = %v, want blank runes", r.Spent)
+	}
+}
@@ -0,0 +1,334 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// ForensicsOpts controls the behavior of Forensics.
+type ForensicsOpts struct {
+	// DATFile is used to reconstruct tile stacks and to look up ground speeds. Required for the speed check.
+	DATFile *dat.File
+
+	// Creatures covering SpeedWindow steps in less than SpeedTolerance times the time their speed allows
+	// are flagged. Default to 4 steps and 0.8.
+	SpeedWindow    int
+	SpeedTolerance float64
+}
+
+// FindingKind classifies a Finding.
+type FindingKind int
+
+const (
+	FindingTicks   FindingKind = iota // A packet timestamp earlier than the previous one.
+	FindingHeader                     // A suspicious .cam header.
+	FindingSpeed                      // A creature walking faster than its speed allows.
+	FindingJump                       // The player changing position without a step, floor change or teleport.
+	FindingStats                      // Player stats inconsistent with the level.
+	FindingSpeaker                    // A creature speaking nearby without ever being seen.
+)
+
+var findingKindName = map[FindingKind]string{
+	FindingTicks:   "ticks",
+	FindingHeader:  "header",
+	FindingSpeed:   "speed",
+	FindingJump:    "jump",
+	FindingStats:   "stats",
+	FindingSpeaker: "speaker",
+}
+
+func (k FindingKind) String() string {
+	if n, ok := findingKindName[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(k))
+}
+
+// findingScore is how much a single finding of each kind adds to ForensicsReport.Score.
+// Kinds a legitimate recording can plausibly trigger, e.g. through lag, weigh less.
+var findingScore = map[FindingKind]int{
+	FindingTicks:   50,
+	FindingHeader:  20,
+	FindingSpeed:   10,
+	FindingJump:    40,
+	FindingStats:   40,
+	FindingSpeaker: 15,
+}
+
+// Finding is a sequence of operations that can't happen in an unedited recording.
+type Finding struct {
+	Kind FindingKind
+	Time time.Duration
+	Text string
+}
+
+// ForensicsReport lists findings over a recording.
+type ForensicsReport struct {
+	Findings []Finding
+	ByKind   map[FindingKind]int
+
+	// Score is the sum of per-finding scores, capped at 100. 0 means nothing suspicious was found.
+	Score int
+}
+
+// Forensics flags impossible sequences of operations, which hint at an edited or forged recording:
+//   - creatures walking faster than their speed and the ground allow,
+//   - the player changing position without a step, a floor change or a teleport effect,
+//   - experience outside of the range of the player's level, or hit points above the maximum,
+//   - creatures saying or whispering something without ever having been seen.
+//
+// Timestamps going backwards are reported by cam.ParseOpts.OnTickRegression, which AddTickRegression takes,
+// and the .cam header is checked separately with AddHeader.
+type Forensics struct {
+	opts  ForensicsOpts
+	state *world.State
+
+	findings []Finding
+
+	steps map[uint32][]forensicStep
+
+	login   bool // The next Map is expected, e.g. at login.
+	walked  *data.Location
+	pending *forensicJump
+
+	seen map[string]bool
+}
+
+type forensicStep struct {
+	time     time.Duration
+	expected time.Duration // How long the step should take at least.
+}
+
+// forensicJump is a Map moving the player, which is explained if a teleport effect follows at the same time.
+type forensicJump struct {
+	time     time.Duration
+	from, to data.Location
+}
+
+// teleportEffect is the EffectGraphical shown at the destination of a teleport.
+const teleportEffect = 11
+
+// NewForensics initializes Forensics. opts may be nil.
+func NewForensics(opts *ForensicsOpts) *Forensics {
+	f := &Forensics{login: true, steps: map[uint32][]forensicStep{}, seen: map[string]bool{}}
+	if opts != nil {
+		f.opts = *opts
+	}
+	if f.opts.SpeedWindow <= 0 {
+		f.opts.SpeedWindow = 4
+	}
+	if f.opts.SpeedTolerance <= 0 {
+		f.opts.SpeedTolerance = 0.8
+	}
+	f.state = world.New(f.opts.DATFile)
+	return f
+}
+
+// AddHeader checks the header of a .cam recording, as returned by cam.ReadHeader.
+//
+// The checksum algorithm is unknown, so checksums aren't verified; only zeroed ones, as written when
+// re-encoding a recording, and unusual header sizes are flagged.
+func (f *Forensics) AddHeader(hdr []byte) {
+	switch {
+	case len(hdr) != 8:
+		f.flag(FindingHeader, 0, "header of %d bytes, want 8", len(hdr))
+	case bytes.Equal(hdr, make([]byte, 8)):
+		f.flag(FindingHeader, 0, "zeroed checksum; the recording was re-encoded")
+	}
+}
+
+// AddTickRegression flags a packet timestamped earlier than the one before it.
+// It has the signature of cam.ParseOpts.OnTickRegression.
+func (f *Forensics) AddTickRegression(p data.RawPacket, prev time.Duration) {
+	f.flag(FindingTicks, p.TimeOffset, "time goes back from %v to %v at file offset %d", prev, p.TimeOffset, p.FileOffset)
+}
+
+// Add processes the next operation. All operation types are needed to track creatures.
+func (f *Forensics) Add(op data.Operation) {
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	offset := data.TimeOffsetOf(op)
+
+	if p := f.pending; p != nil && offset != p.time {
+		f.flag(FindingJump, p.time, "player jumped from %v to %v", p.from, p.to)
+		f.pending = nil
+	}
+
+	pos := f.state.PlayerPos()
+	switch op := op.(type) {
+	case data.LoginPlayerState:
+		f.login = true
+	case data.CreatureMove:
+		f.addMove(op)
+	case data.Map:
+		f.addMap(op, pos)
+	case data.EffectGraphical:
+		if p := f.pending; p != nil && op.Effect == teleportEffect && op.Location == p.to {
+			f.pending = nil
+		}
+	case data.PlayerStats:
+		f.addStats(op)
+	case data.CreatureMessage:
+		f.addMessage(op)
+	}
+	f.state.Apply(op)
+
+	for _, c := range creaturesOf(op) {
+		if c.Name != "" {
+			f.seen[c.Name] = true
+		}
+	}
+}
+
+func (f *Forensics) addMove(op data.CreatureMove) {
+	things := f.state.Tile(op.OldLocation)
+	if int(op.OldStack) >= len(things) || !things[op.OldStack].HasCreature {
+		return
+	}
+	c := things[op.OldStack].Creature
+	if op.OldLocation == f.state.PlayerPos() {
+		f.walked = &op.NewLocation
+	}
+
+	dx, dy := abs(op.NewLocation.X-op.OldLocation.X), abs(op.NewLocation.Y-op.OldLocation.Y)
+	ground := f.groundSpeed(things)
+	if dx+dy != 1 || op.NewLocation.Z != op.OldLocation.Z || ground == 0 || c.Speed == 0 {
+		// Diagonal steps, floor changes and pushes are timed differently; start over.
+		delete(f.steps, c.ID)
+		return
+	}
+
+	n := f.opts.SpeedWindow
+	steps := append(f.steps[c.ID], forensicStep{
+		time:     op.TimeOffset,
+		expected: time.Duration(1000*int(ground)/int(c.Speed)) * time.Millisecond,
+	})
+	if len(steps) > n+1 {
+		steps = steps[1:]
+	}
+	f.steps[c.ID] = steps
+	if len(steps) < n+1 {
+		return
+	}
+
+	var expected time.Duration
+	for _, s := range steps[1:] {
+		expected += s.expected
+	}
+	observed := steps[n].time - steps[0].time
+	if float64(observed) < f.opts.SpeedTolerance*float64(expected) {
+		f.flag(FindingSpeed, op.TimeOffset, "%q walked %d steps in %v, want at least %v at speed %d", c.Name, n, observed, expected, c.Speed)
+		delete(f.steps, c.ID)
+	}
+}
+
+// groundSpeed returns the speed of the ground item in things, or 0 if unknown.
+func (f *Forensics) groundSpeed(things []data.Thing) uint16 {
+	if f.opts.DATFile == nil {
+		return 0
+	}
+	for _, t := range things {
+		if !t.HasItem {
+			continue
+		}
+		if p, ok := f.opts.DATFile.Properties(int(t.Item.ID)); ok && p.Ground {
+			return p.GroundSpeed
+		}
+	}
+	return 0
+}
+
+func (f *Forensics) addMap(op data.Map, pos data.Location) {
+	walked := f.walked
+	f.walked = nil
+	switch {
+	case f.login:
+		f.login = false
+	case op.PlayerPos == pos:
+	case walked != nil && *walked == op.PlayerPos:
+		// Stepping onto stairs or a hole sends the whole map.
+	default:
+		f.pending = &forensicJump{time: op.TimeOffset, from: pos, to: op.PlayerPos}
+	}
+}
+
+// expForLevel returns the experience needed for a level, using the formula of 7.x servers.
+func expForLevel(level int) int64 {
+	l := int64(level)
+	return (50*l*l*l - 300*l*l + 850*l - 600) / 3
+}
+
+func (f *Forensics) addStats(op data.PlayerStats) {
+	if op.HP > op.MaxHP {
+		f.flag(FindingStats, op.TimeOffset, "%d hit points, above the maximum of %d", op.HP, op.MaxHP)
+	}
+	if op.Level == 0 {
+		return
+	}
+	lo, hi := expForLevel(int(op.Level)), expForLevel(int(op.Level)+1)
+	if exp := int64(op.Exp); exp < lo || exp >= hi {
+		f.flag(FindingStats, op.TimeOffset, "%d experience at level %d, want %d to %d", exp, op.Level, lo, hi-1)
+	}
+}
+
+func (f *Forensics) addMessage(op data.CreatureMessage) {
+	switch op.Type {
+	case data.SpeakSay, data.SpeakWhisper, data.SpeakMonsterSay:
+		// Yells are heard from beyond the view.
+	default:
+		return
+	}
+	if op.Name != "" && !f.seen[op.Name] && !f.seen[withoutArticle(op.Name)] {
+		f.flag(FindingSpeaker, op.TimeOffset, "%q spoke without being seen: %q", op.Name, op.Text)
+	}
+}
+
+// withoutArticle strips the article monster names get in speech, e.g. "a dwarf".
+func withoutArticle(name string) string {
+	for _, a := range []string{"a ", "an ", "the "} {
+		if rest, ok := strings.CutPrefix(name, a); ok {
+			return rest
+		}
+	}
+	return name
+}
+
+func (f *Forensics) flag(kind FindingKind, t time.Duration, format string, args ...any) {
+	f.findings = append(f.findings, Finding{Kind: kind, Time: t, Text: fmt.Sprintf(format, args...)})
+}
+
+// Report returns the findings so far, in the order they were made.
+func (f *Forensics) Report() ForensicsReport {
+	findings := f.findings
+	if p := f.pending; p != nil {
+		findings = append(findings[:len(findings):len(findings)], Finding{Kind: FindingJump, Time: p.time, Text: fmt.Sprintf("player jumped from %v to %v", p.from, p.to)})
+	}
+
+	r := ForensicsReport{Findings: findings, ByKind: map[FindingKind]int{}}
+	for _, fd := range findings {
+		r.ByKind[fd.Kind]++
+		r.Score += findingScore[fd.Kind]
+	}
+	r.Score = min(r.Score, 100)
+	return r
+}
+
+// creaturesOf returns the creatures carried by tiles of op.
+func creaturesOf(op data.Operation) []data.Creature {
+	var ret []data.Creature
+	for _, tile := range data.TilesOf(op) {
+		for _, t := range tile.Things {
+			if t.HasCreature {
+				ret = append(ret, t.Creature)
+			}
+		}
+	}
+	return ret
+}
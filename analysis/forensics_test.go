@@ -0,0 +1,140 @@
+package analysis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+func readDAT(t *testing.T, name string) *dat.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("../cam/testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	datFile, err := dat.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return datFile
+}
+
+func TestForensics(t *testing.T) {
+	datFile := readDAT(t, "Tibiantis.dat")
+
+	// Item 102 is ground with speed 150, so a creature with speed 300 takes 500ms per step.
+	const ground = 102
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	loc := func(x int) data.Location { return data.Location{X: x, Y: 100, Z: 7} }
+	tile := func(x int, things ...data.Thing) data.Tile {
+		return data.Tile{Location: loc(x), Things: append([]data.Thing{{HasItem: true, Item: data.Item{ID: ground}}}, things...)}
+	}
+	creature := func(id uint32, name string) data.Thing {
+		return data.Thing{HasCreature: true, Creature: data.Creature{ID: id, Name: name, Health: 100, Speed: 300}}
+	}
+	var tiles []data.Tile
+	for x := 100; x < 120; x++ {
+		switch x {
+		case 100:
+			tiles = append(tiles, tile(x, creature(1, "Player")))
+		case 110:
+			tiles = append(tiles, tile(x, creature(0x40000001, "Rat")))
+		default:
+			tiles = append(tiles, tile(x))
+		}
+	}
+	step := func(t, x int) data.CreatureMove {
+		return data.CreatureMove{TimeOffset: ms(t), OldLocation: loc(x), OldStack: 1, NewLocation: loc(x + 1)}
+	}
+	say := func(t int, name string) data.CreatureMessage {
+		l := loc(105)
+		return data.CreatureMessage{TimeOffset: ms(t), Name: name, Type: data.SpeakSay, Location: &l, Text: "hi"}
+	}
+
+	f := NewForensics(&ForensicsOpts{DATFile: datFile})
+	f.AddHeader(make([]byte, 8))
+	f.AddTickRegression(data.RawPacket{FileOffset: 120, TimeOffset: ms(4500)}, ms(5000))
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: loc(100), Tiles: tiles},
+		// The rat walks at its speed, then too fast.
+		step(1000, 110), step(1500, 111), step(2000, 112), step(2500, 113),
+		step(2600, 114), step(2700, 115), step(2800, 116), step(2900, 117),
+		// Seen and unseen speakers.
+		say(3000, "Rat"),
+		say(3000, "Ghost"),
+		// A teleport, then a jump.
+		data.Map{TimeOffset: ms(4000), PlayerPos: loc(105), Tiles: tiles},
+		data.EffectGraphical{TimeOffset: ms(4000), Location: loc(105), Effect: 11},
+		data.Map{TimeOffset: ms(5000), PlayerPos: loc(100), Tiles: tiles},
+		// Experience doesn't match the level.
+		data.PlayerStats{TimeOffset: ms(5500), HP: 100, MaxHP: 100, Level: 8, Exp: 4200},
+		data.PlayerStats{TimeOffset: ms(5600), HP: 100, MaxHP: 100, Level: 8, Exp: 4199},
+		data.PlayerStats{TimeOffset: ms(5700), HP: 101, MaxHP: 100, Level: 9, Exp: 6400},
+	} {
+		f.Add(op)
+	}
+
+	want := ForensicsReport{
+		Findings: []Finding{
+			{Kind: FindingHeader, Time: 0, Text: "zeroed checksum; the recording was re-encoded"},
+			{Kind: FindingTicks, Time: ms(4500), Text: "time goes back from 5s to 4.5s at file offset 120"},
+			{Kind: FindingSpeed, Time: ms(2700), Text: `"Rat" walked 4 steps in 1.2s, want at least 2s at speed 300`},
+			{Kind: FindingSpeaker, Time: ms(3000), Text: `"Ghost" spoke without being seen: "hi"`},
+			{Kind: FindingJump, Time: ms(5000), Text: "player jumped from (105,100,7) to (100,100,7)"},
+			{Kind: FindingStats, Time: ms(5600), Text: "4199 experience at level 8, want 4200 to 6399"},
+			{Kind: FindingStats, Time: ms(5700), Text: "101 hit points, above the maximum of 100"},
+		},
+		ByKind: map[FindingKind]int{
+			FindingHeader:  1,
+			FindingSpeed:   1,
+			FindingSpeaker: 1,
+			FindingTicks:   1,
+			FindingJump:    1,
+			FindingStats:   2,
+		},
+		Score: 100,
+	}
+	if diff := cmp.Diff(want, f.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestForensics_Recordings(t *testing.T) {
+	for _, tt := range []struct{ cam, dat string }{
+		{"tibiantis.cam", "Tibiantis.dat"},
+		{"relic.cam", "TibiaRelic.dat"},
+	} {
+		t.Run(tt.cam, func(t *testing.T) {
+			datFile := readDAT(t, tt.dat)
+			b, err := os.ReadFile(filepath.Join("../cam/testdata", tt.cam))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewForensics(&ForensicsOpts{DATFile: datFile})
+			hdr, err := cam.ReadHeader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("ReadHeader() error: %v", err)
+			}
+			f.AddHeader(hdr)
+			for op, err := range cam.Parse(bytes.NewReader(b), &cam.ParseOpts{DATFile: datFile, OnTickRegression: f.AddTickRegression}) {
+				if err != nil {
+					t.Fatalf("Parse() error: %v", err)
+				}
+				f.Add(op)
+			}
+			if r := f.Report(); r.Score != 0 {
+				t.Errorf("Report() of an unedited recording = %+v, want no findings", r)
+			}
+		})
+	}
+}
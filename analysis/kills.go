@@ -0,0 +1,260 @@
+package analysis
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// KillOpts controls the behavior of KillDetector.
+type KillOpts struct {
+	// DATFile is used to reconstruct tile stacks and to tell corpses apart from splashes. Recommended.
+	DATFile *dat.File
+
+	// How long after a creature's health hits 0 its removal and corpse are awaited. Defaults to 2 seconds.
+	Window time.Duration
+
+	// Attackers damaging the player within KillerWindow before death count as killers. Defaults to 1 minute.
+	KillerWindow time.Duration
+}
+
+// Kill is a creature dying within the player's view.
+type Kill struct {
+	Creature data.Creature
+	Location data.Location
+	Time     time.Duration
+	Corpse   data.Item // Zero if no corpse appeared.
+}
+
+// PlayerDeath is a death of the recording player.
+type PlayerDeath struct {
+	Time time.Duration
+	// Killers are creatures that damaged the player shortly before death, most damage first.
+	Killers []string
+}
+
+// KillReport lists kills and deaths over a recording.
+type KillReport struct {
+	Kills      []Kill
+	Deaths     []PlayerDeath
+	ByCreature map[string]int // Kill counts by creature name.
+}
+
+// KillDetector correlates creature health, tile changes and messages into kills and deaths.
+//
+// A creature is killed when its health drops to 0 and it is then removed from its tile or replaced by a corpse.
+// The player dies when their hit points drop to 0 or "You are dead." is shown.
+type KillDetector struct {
+	opts  KillOpts
+	state *world.State
+
+	pending []*pendingKill
+	kills   []Kill
+	deaths  []PlayerDeath
+
+	dead    bool
+	damages []killerDamage
+}
+
+type pendingKill struct {
+	kill    Kill
+	removed bool
+}
+
+type killerDamage struct {
+	at       time.Duration
+	attacker string
+	amount   int
+}
+
+// NewKillDetector initializes a KillDetector. opts may be nil.
+func NewKillDetector(opts *KillOpts) *KillDetector {
+	d := &KillDetector{}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.Window <= 0 {
+		d.opts.Window = 2 * time.Second
+	}
+	if d.opts.KillerWindow <= 0 {
+		d.opts.KillerWindow = time.Minute
+	}
+	d.state = world.New(d.opts.DATFile)
+	return d
+}
+
+// Add processes the next operation. All operation types are needed to track creatures.
+func (d *KillDetector) Add(op data.Operation) {
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	offset := data.TimeOffsetOf(op)
+	d.expire(offset)
+
+	switch op := op.(type) {
+	case data.CreatureHealth:
+		d.addHealth(op)
+	case data.TileItemRemove:
+		things := d.state.Tile(op.Location)
+		if int(op.StackIndex) < len(things) && things[op.StackIndex].HasCreature {
+			if p := d.find(things[op.StackIndex].Creature.ID); p != nil {
+				p.removed = true
+			}
+		}
+	case data.TileItemAdd:
+		d.addItem(op)
+	case data.PlayerStats:
+		if op.HP == 0 {
+			d.die(op.TimeOffset)
+		} else {
+			d.dead = false
+		}
+	case data.Message:
+		if strings.TrimSpace(op.Text) == "You are dead." {
+			d.die(op.TimeOffset)
+		} else if m := loseRe.FindStringSubmatch(op.Text); m != nil && m[3] != "" {
+			n, _ := strconv.Atoi(m[1])
+			d.addDamage(killerDamage{at: op.TimeOffset, attacker: trimArticle(m[3]), amount: n})
+		}
+	}
+	d.state.Apply(op)
+
+	d.finish(func(p *pendingKill) bool { return p.removed && p.kill.Corpse != (data.Item{}) })
+}
+
+func (d *KillDetector) addHealth(op data.CreatureHealth) {
+	if op.CreatureID == d.state.PlayerID() {
+		return
+	}
+	p := d.find(op.CreatureID)
+	switch {
+	case op.Health > 0 && p != nil:
+		// Not dead after all.
+		d.pending = slices.DeleteFunc(d.pending, func(q *pendingKill) bool { return q == p })
+	case op.Health == 0 && p == nil:
+		c, ok := d.state.Creature(op.CreatureID)
+		if !ok || !c.Visible {
+			return
+		}
+		cr := c.Creature
+		cr.Health = 0
+		d.pending = append(d.pending, &pendingKill{kill: Kill{Creature: cr, Location: c.Location, Time: op.TimeOffset}})
+	}
+}
+
+func (d *KillDetector) addItem(op data.TileItemAdd) {
+	if !op.Thing.HasItem {
+		return
+	}
+	if d.opts.DATFile != nil && d.opts.DATFile.IsFluid(int(op.Thing.Item.ID)) {
+		// Blood splashes.
+		return
+	}
+	for _, p := range d.pending {
+		if p.kill.Location == op.Location && p.kill.Corpse == (data.Item{}) {
+			p.kill.Corpse = op.Thing.Item
+			return
+		}
+	}
+}
+
+func (d *KillDetector) find(id uint32) *pendingKill {
+	for _, p := range d.pending {
+		if p.kill.Creature.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// expire settles pending kills whose window has passed by now.
+func (d *KillDetector) expire(now time.Duration) {
+	d.finish(func(p *pendingKill) bool { return now > p.kill.Time+d.opts.Window })
+}
+
+// finish moves pending kills matching done to kills, dropping ones that were neither removed nor left a corpse.
+func (d *KillDetector) finish(done func(p *pendingKill) bool) {
+	d.pending = slices.DeleteFunc(d.pending, func(p *pendingKill) bool {
+		if !done(p) {
+			return false
+		}
+		if p.removed || p.kill.Corpse != (data.Item{}) {
+			d.kills = append(d.kills, p.kill)
+		}
+		return true
+	})
+}
+
+// addDamage records damage taken by the player, dropping damage too old to count towards a death.
+func (d *KillDetector) addDamage(dmg killerDamage) {
+	i := 0
+	for i < len(d.damages) && dmg.at-d.damages[i].at > d.opts.KillerWindow {
+		i++
+	}
+	d.damages = append(slices.Delete(d.damages, 0, i), dmg)
+}
+
+func (d *KillDetector) die(at time.Duration) {
+	if d.dead {
+		return
+	}
+	d.dead = true
+
+	total := map[string]int{}
+	for _, dmg := range d.damages {
+		if at-dmg.at <= d.opts.KillerWindow {
+			total[dmg.attacker] += dmg.amount
+		}
+	}
+	var killers []string
+	for name := range total {
+		killers = append(killers, name)
+	}
+	slices.SortFunc(killers, func(a, b string) int {
+		return cmp.Or(cmp.Compare(total[b], total[a]), strings.Compare(a, b))
+	})
+	d.deaths = append(d.deaths, PlayerDeath{Time: at, Killers: killers})
+	d.damages = nil
+}
+
+// IsCorpse reports whether a creature killed so far, including one still awaiting its removal, left an item
+// of itemID, e.g. to tell corpses from other containers.
+func (d *KillDetector) IsCorpse(itemID uint16) bool {
+	for _, k := range d.kills {
+		if k.Corpse.ID == itemID {
+			return true
+		}
+	}
+	for _, p := range d.pending {
+		if p.kill.Corpse.ID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// Report lists all kills and deaths detected so far.
+// Kills still awaiting their removal or corpse are included if either was seen.
+func (d *KillDetector) Report() KillReport {
+	r := KillReport{
+		Kills:      slices.Clone(d.kills),
+		Deaths:     slices.Clone(d.deaths),
+		ByCreature: map[string]int{},
+	}
+	for _, p := range d.pending {
+		if p.removed || p.kill.Corpse != (data.Item{}) {
+			r.Kills = append(r.Kills, p.kill)
+		}
+	}
+	slices.SortStableFunc(r.Kills, func(a, b Kill) int { return cmp.Compare(a.Time, b.Time) })
+	for _, k := range r.Kills {
+		r.ByCreature[k.Creature.Name]++
+	}
+	return r
+}
@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+func TestKillDetector(t *testing.T) {
+	f, err := os.Open("../cam/testdata/Tibiantis.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	datFile, err := dat.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Item IDs from Tibiantis.dat.
+	const (
+		ground = 100
+		splash = 2886
+		corpse = 4240
+	)
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	loc := func(x int) data.Location { return data.Location{X: x, Y: 100, Z: 7} }
+	item := func(id uint16) data.Thing { return data.Thing{HasItem: true, Item: data.Item{ID: id}} }
+	creature := func(id uint32, name string) data.Thing {
+		return data.Thing{HasCreature: true, Creature: data.Creature{ID: id, Name: name, Health: 100}}
+	}
+	tile := func(x int, things ...data.Thing) data.Tile {
+		return data.Tile{Location: loc(x), Things: append([]data.Thing{item(ground)}, things...)}
+	}
+	health := func(t, id int, hp byte) data.CreatureHealth {
+		return data.CreatureHealth{TimeOffset: ms(t), CreatureID: uint32(id), Health: hp}
+	}
+	lose := func(t int, text string) data.Message {
+		return data.Message{TimeOffset: ms(t), Type: data.MessageStatusDefault, Text: text}
+	}
+
+	detector := NewKillDetector(&KillOpts{DATFile: datFile})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: loc(100), Tiles: []data.Tile{
+			tile(100, creature(1, "Player")),
+			tile(101, creature(2, "rat")),
+			tile(102, creature(3, "troll")),
+			tile(103, creature(4, "Hunter")),
+			tile(104, creature(5, "orc")),
+		}},
+		// A rat dies: splash, corpse, then removal.
+		health(1000, 2, 0),
+		health(1000, 2, 0),
+		data.TileItemAdd{TimeOffset: ms(1000), Location: loc(101), Thing: item(splash)},
+		data.TileItemAdd{TimeOffset: ms(1000), Location: loc(101), Thing: item(corpse)},
+		data.TileItemRemove{TimeOffset: ms(1300), Location: loc(101), StackIndex: 1},
+		// A troll gets healed back from 0.
+		health(2000, 3, 0),
+		health(2500, 3, 10),
+		// A player is removed without leaving a corpse.
+		health(3000, 4, 0),
+		data.TileItemRemove{TimeOffset: ms(3000), Location: loc(103), StackIndex: 1},
+		// An orc hits 0 but neither disappears nor leaves a corpse.
+		health(4000, 5, 0),
+		// The player dies; older damage doesn't count.
+		lose(5000, "You lose 10 hitpoints due to an attack by a rat."),
+		lose(70000, "You lose 30 hitpoints due to an attack by a troll."),
+		lose(71000, "You lose 50 hitpoints due to an attack by Hunter."),
+		lose(72000, "You lose 5 hitpoints."),
+		lose(72000, "You lose 20 hitpoints due to an attack by a troll."),
+		data.PlayerStats{TimeOffset: ms(72000), HP: 0},
+		data.Message{TimeOffset: ms(72000), Type: data.MessageEventAdvance, Text: "You are dead.\n"},
+		data.PlayerStats{TimeOffset: ms(72000), HP: 0},
+	} {
+		detector.Add(op)
+	}
+
+	want := KillReport{
+		Kills: []Kill{
+			{
+				Creature: data.Creature{ID: 2, Name: "rat"},
+				Location: loc(101),
+				Time:     ms(1000),
+				Corpse:   data.Item{ID: corpse},
+			},
+			{
+				Creature: data.Creature{ID: 4, Name: "Hunter"},
+				Location: loc(103),
+				Time:     ms(3000),
+			},
+		},
+		Deaths: []PlayerDeath{
+			{Time: ms(72000), Killers: []string{"Hunter", "troll"}},
+		},
+		ByCreature: map[string]int{"rat": 1, "Hunter": 1},
+	}
+	if diff := cmp.Diff(want, detector.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestKillDetector_DamagesTrimmed(t *testing.T) {
+	detector := NewKillDetector(nil)
+	for i := range 1000 {
+		detector.Add(data.Message{
+			TimeOffset: time.Duration(i) * time.Second,
+			Type:       data.MessageStatusDefault,
+			Text:       "You lose 1 hitpoint due to an attack by a rat.",
+		})
+	}
+	// Only damage within the last minute counts towards a death.
+	if got, want := len(detector.damages), 61; got != want {
+		t.Errorf("len(damages) = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,347 @@
+package analysis
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// LootOpts controls the behavior of LootTracker.
+type LootOpts struct {
+	// DATFile is used to reconstruct tile stacks, on which corpses are found, and to tell stackable items
+	// from others. Recommended.
+	DATFile *dat.File
+}
+
+// LootItem is an amount of a single item.
+// Items from loot messages are identified by Name, items from containers by ItemID.
+type LootItem struct {
+	Name   string // Singular, e.g. "gold coin" for "57 gold coins".
+	ItemID uint16
+	Count  int
+}
+
+// LootDrop is a single "Loot of ..." message.
+type LootDrop struct {
+	Time    time.Duration
+	Monster string
+	Items   []LootItem
+
+	// Corpse holds the contents of the first matching corpse opened after the message, if any.
+	// Verified is set if its total item count equals the message's.
+	Corpse   []LootItem
+	Verified bool
+}
+
+// MonsterLoot aggregates loot messages of a single monster.
+type MonsterLoot struct {
+	Drops int
+	Items map[string]int
+}
+
+// LootReport summarizes loot over a recording.
+type LootReport struct {
+	Duration time.Duration
+
+	// Based on loot messages.
+	Drops        []LootDrop
+	Monsters     map[string]*MonsterLoot
+	Items        map[string]int
+	ItemsPerHour map[string]float64
+
+	// Based on container deltas: items moved out of corpses into the player's containers, by item ID.
+	Looted         map[uint16]int
+	LootedByCorpse map[string]map[uint16]int
+	LootedPerHour  map[uint16]float64
+}
+
+// LootTracker follows loot messages and container changes.
+//
+// A container is a corpse if the same item appeared on the tile of a creature that died, as KillDetector
+// tells, so that e.g. depots and chests aren't taken for corpses.
+type LootTracker struct {
+	opts  LootOpts
+	end   time.Duration
+	kills *KillDetector
+
+	drops   []LootDrop
+	world   *world.State    // Only containers are followed; tiles don't matter.
+	corpses map[byte]string // Names of open corpses, and of containers within them, by container ID.
+
+	// Container deltas are resolved per TimeOffset, as a single move removes and adds an item in one packet.
+	at         time.Duration
+	corpseLoss map[string]map[uint16]int
+	gain       map[uint16]int
+
+	looted         map[uint16]int
+	lootedByCorpse map[string]map[uint16]int
+}
+
+// NewLootTracker initializes a LootTracker. opts may be nil.
+func NewLootTracker(opts *LootOpts) *LootTracker {
+	t := &LootTracker{
+		world:          world.New(nil),
+		corpses:        map[byte]string{},
+		corpseLoss:     map[string]map[uint16]int{},
+		gain:           map[uint16]int{},
+		looted:         map[uint16]int{},
+		lootedByCorpse: map[string]map[uint16]int{},
+	}
+	if opts != nil {
+		t.opts = *opts
+	}
+	t.kills = NewKillDetector(&KillOpts{DATFile: t.opts.DATFile})
+	return t
+}
+
+// Add processes the next operation. Operations must not be filtered by type.
+func (t *LootTracker) Add(op data.Operation) {
+	offset := data.TimeOffsetOf(op)
+	t.end = max(t.end, offset)
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	t.kills.Add(op)
+	if offset != t.at {
+		t.resolve()
+		t.at = offset
+	}
+
+	switch op := op.(type) {
+	case data.Message:
+		if drop, ok := parseLoot(op.Text); ok {
+			drop.Time = op.TimeOffset
+			t.drops = append(t.drops, drop)
+		}
+	case data.ContainerOpen:
+		t.open(op)
+	case data.ContainerClose:
+		delete(t.corpses, op.ContainerID)
+	case data.ContainerItemAdd:
+		if _, ok := t.world.Container(op.ContainerID); ok && op.Thing.HasItem {
+			t.delta(op.ContainerID, op.Thing.Item.ID, t.count(op.Thing.Item))
+		}
+	case data.ContainerItemUpdate:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) && op.Thing.HasItem {
+			old := c.Items[op.Slot]
+			t.delta(op.ContainerID, old.ID, -t.count(old))
+			t.delta(op.ContainerID, op.Thing.Item.ID, t.count(op.Thing.Item))
+		}
+	case data.ContainerItemRemove:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) {
+			old := c.Items[op.Slot]
+			t.delta(op.ContainerID, old.ID, -t.count(old))
+		}
+	}
+	t.world.Apply(op)
+}
+
+func (t *LootTracker) open(op data.ContainerOpen) {
+	// A container opened from within another one replaces it in the same window.
+	if parent, ok := t.corpses[op.ContainerID]; ok && op.HasParent != 0 {
+		t.corpses[op.ContainerID] = parent
+		return
+	}
+	if !t.kills.IsCorpse(op.ItemID) {
+		delete(t.corpses, op.ContainerID)
+		return
+	}
+	t.corpses[op.ContainerID] = op.Name
+	if op.HasParent != 0 {
+		return
+	}
+	for i := range t.drops {
+		d := &t.drops[i]
+		if d.Corpse != nil || !strings.Contains(strings.ToLower(op.Name), strings.ToLower(d.Monster)) {
+			continue
+		}
+		d.Corpse = []LootItem{}
+		total := 0
+		for _, th := range op.Items {
+			if !th.HasItem {
+				continue
+			}
+			it := th.Item
+			n := t.count(it)
+			d.Corpse = append(d.Corpse, LootItem{ItemID: it.ID, Count: n})
+			total += n
+		}
+		want := 0
+		for _, it := range d.Items {
+			want += it.Count
+		}
+		d.Verified = total == want
+		break
+	}
+}
+
+// count returns the number of items in a stack.
+func (t *LootTracker) count(it data.Item) int {
+	if t.opts.DATFile != nil && !t.opts.DATFile.IsStackable(int(it.ID)) {
+		return 1
+	}
+	return max(1, int(it.Count))
+}
+
+// delta records n items of id added to (or, if negative, removed from) an open container.
+func (t *LootTracker) delta(container byte, id uint16, n int) {
+	name, corpse := t.corpses[container]
+	switch {
+	case corpse && n < 0:
+		if t.corpseLoss[name] == nil {
+			t.corpseLoss[name] = map[uint16]int{}
+		}
+		t.corpseLoss[name][id] -= n
+	case corpse:
+		// Items put into a corpse, usually while looting it.
+		for _, loss := range t.corpseLoss {
+			loss[id] -= min(n, loss[id])
+		}
+	default:
+		t.gain[id] += n
+	}
+}
+
+// resolve credits items that left a corpse and reached the player's containers within the current TimeOffset.
+func (t *LootTracker) resolve() {
+	for corpse, items := range t.pending() {
+		if t.lootedByCorpse[corpse] == nil {
+			t.lootedByCorpse[corpse] = map[uint16]int{}
+		}
+		for id, n := range items {
+			t.looted[id] += n
+			t.lootedByCorpse[corpse][id] += n
+		}
+	}
+	clear(t.corpseLoss)
+	clear(t.gain)
+}
+
+// pending returns items looted within the current TimeOffset by corpse, without modifying the tracker.
+func (t *LootTracker) pending() map[string]map[uint16]int {
+	ret := map[string]map[uint16]int{}
+	gain := maps.Clone(t.gain)
+	for _, corpse := range slices.Sorted(maps.Keys(t.corpseLoss)) {
+		for id, n := range t.corpseLoss[corpse] {
+			if n = min(n, gain[id]); n <= 0 {
+				continue
+			}
+			gain[id] -= n
+			if ret[corpse] == nil {
+				ret[corpse] = map[uint16]int{}
+			}
+			ret[corpse][id] += n
+		}
+	}
+	return ret
+}
+
+// Report summarizes all operations added so far.
+func (t *LootTracker) Report() LootReport {
+	r := LootReport{
+		Duration:       t.end,
+		Drops:          slices.Clone(t.drops),
+		Monsters:       map[string]*MonsterLoot{},
+		Items:          map[string]int{},
+		ItemsPerHour:   map[string]float64{},
+		Looted:         map[uint16]int{},
+		LootedByCorpse: map[string]map[uint16]int{},
+		LootedPerHour:  map[uint16]float64{},
+	}
+	for _, d := range t.drops {
+		m, ok := r.Monsters[d.Monster]
+		if !ok {
+			m = &MonsterLoot{Items: map[string]int{}}
+			r.Monsters[d.Monster] = m
+		}
+		m.Drops++
+		for _, it := range d.Items {
+			m.Items[it.Name] += it.Count
+			r.Items[it.Name] += it.Count
+		}
+	}
+	for name, n := range r.Items {
+		r.ItemsPerHour[name] = perHour(int64(n), r.Duration)
+	}
+	for _, byCorpse := range []map[string]map[uint16]int{t.lootedByCorpse, t.pending()} {
+		for corpse, items := range byCorpse {
+			if r.LootedByCorpse[corpse] == nil {
+				r.LootedByCorpse[corpse] = map[uint16]int{}
+			}
+			for id, n := range items {
+				r.LootedByCorpse[corpse][id] += n
+				r.Looted[id] += n
+			}
+		}
+	}
+	for id, n := range r.Looted {
+		r.LootedPerHour[id] = perHour(int64(n), r.Duration)
+	}
+	return r
+}
+
+// parseLoot parses messages like "Loot of a dragon: 57 gold coins, a dragon ham." or "Loot of a rat: nothing.".
+// Item names are made singular, so that a single item and a stack of them are counted together.
+func parseLoot(text string) (LootDrop, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(text), "Loot of ")
+	if !ok {
+		return LootDrop{}, false
+	}
+	monster, items, ok := strings.Cut(rest, ":")
+	if !ok {
+		return LootDrop{}, false
+	}
+
+	drop := LootDrop{Monster: trimArticle(strings.TrimSpace(monster))}
+	items = strings.TrimSuffix(strings.TrimSpace(items), ".")
+	if items == "nothing" || items == "" {
+		return drop, true
+	}
+	for _, s := range strings.Split(items, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		it := LootItem{Name: trimArticle(s), Count: 1}
+		if num, name, ok := strings.Cut(s, " "); ok {
+			if n, err := strconv.Atoi(num); err == nil {
+				it = LootItem{Name: singular(strings.TrimSpace(name)), Count: n}
+			}
+		}
+		drop.Items = append(drop.Items, it)
+	}
+	return drop, true
+}
+
+// singular returns the singular of an item name for a stack, e.g. "gold coin" for "gold coins",
+// or "piece of cloth" for "pieces of cloth".
+func singular(name string) string {
+	head, tail, _ := strings.Cut(name, " of ")
+	switch {
+	case strings.HasSuffix(head, "ies"):
+		head = strings.TrimSuffix(head, "ies") + "y"
+	case strings.HasSuffix(head, "ches"), strings.HasSuffix(head, "shes"), strings.HasSuffix(head, "sses"), strings.HasSuffix(head, "xes"):
+		head = strings.TrimSuffix(head, "es")
+	case strings.HasSuffix(head, "s") && !strings.HasSuffix(head, "ss"):
+		head = strings.TrimSuffix(head, "s")
+	}
+	if tail != "" {
+		return head + " of " + tail
+	}
+	return head
+}
+
+func trimArticle(s string) string {
+	for _, a := range []string{"a ", "an ", "the "} {
+		if rest, ok := strings.CutPrefix(s, a); ok {
+			return rest
+		}
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+func TestParseLoot(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want LootDrop
+		ok   bool
+	}{
+		{
+			text: "Loot of a dragon: 57 gold coins, a dragon ham, an axe.",
+			want: LootDrop{Monster: "dragon", Items: []LootItem{
+				{Name: "gold coin", Count: 57},
+				{Name: "dragon ham", Count: 1},
+				{Name: "axe", Count: 1},
+			}},
+			ok: true,
+		},
+		{
+			text: "Loot of a rat: nothing.",
+			want: LootDrop{Monster: "rat"},
+			ok:   true,
+		},
+		{
+			// Decoded from Windows-1252, including a no-break space.
+			text: "Loot of Ferumbras:\u00a0a Ferumbras' hat, 2 crystal coins, a «cursed» amulet.",
+			want: LootDrop{Monster: "Ferumbras", Items: []LootItem{
+				{Name: "Ferumbras' hat", Count: 1},
+				{Name: "crystal coin", Count: 2},
+				{Name: "«cursed» amulet", Count: 1},
+			}},
+			ok: true,
+		},
+		{
+			text: "Loot of a dwarf: 2 pieces of cloth, 3 small rubies, 2 torches, a glass.",
+			want: LootDrop{Monster: "dwarf", Items: []LootItem{
+				{Name: "piece of cloth", Count: 2},
+				{Name: "small ruby", Count: 3},
+				{Name: "torch", Count: 2},
+				{Name: "glass", Count: 1},
+			}},
+			ok: true,
+		},
+		{text: "You see a dead rat."},
+		{text: "Loot of nothing"},
+	} {
+		got, ok := parseLoot(tc.text)
+		if ok != tc.ok {
+			t.Errorf("parseLoot(%q) ok = %v; want %v", tc.text, ok, tc.ok)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("parseLoot(%q) diff; -want +got:\n%v", tc.text, diff)
+		}
+	}
+}
+
+func TestLootTracker(t *testing.T) {
+	f, err := os.Open("../cam/testdata/Tibiantis.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	datFile, err := dat.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Item IDs from Tibiantis.dat.
+	const (
+		corpse   = 4240
+		backpack = 2854
+		bag      = 2853
+		gold     = 3031
+		sword    = 3264
+		ring     = 3350
+		chest    = 2431 // A container that can't be picked up.
+	)
+	pos := data.Location{X: 100, Y: 100, Z: 7}
+	human := data.Creature{ID: 0x40000001, Name: "human", Health: 100}
+	sec := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	item := func(id uint16, count byte) data.Thing {
+		return data.Thing{HasItem: true, Item: data.Item{ID: id, Count: count}}
+	}
+
+	tracker := NewLootTracker(&LootOpts{DATFile: datFile})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{{Location: pos, Things: []data.Thing{
+			item(102, 0), {HasCreature: true, Creature: human},
+		}}}},
+		data.ContainerOpen{TimeOffset: sec(0), ContainerID: 0, ItemID: backpack, Name: "backpack", Items: []data.Thing{item(gold, 10)}},
+		// Items taken out of a chest aren't loot.
+		data.ContainerOpen{TimeOffset: sec(1), ContainerID: 1, ItemID: chest, Name: "chest", Items: []data.Thing{item(sword, 0)}},
+		data.ContainerItemRemove{TimeOffset: sec(2), ContainerID: 1, Slot: 0},
+		data.ContainerItemAdd{TimeOffset: sec(2), ContainerID: 0, Thing: item(sword, 0)},
+		data.ContainerClose{TimeOffset: sec(3), ContainerID: 1},
+		data.CreatureHealth{TimeOffset: sec(10), CreatureID: human.ID, Health: 0},
+		data.TileItemRemove{TimeOffset: sec(10), Location: pos, StackIndex: 1},
+		data.TileItemAdd{TimeOffset: sec(10), Location: pos, Thing: item(corpse, 0)},
+		data.Message{TimeOffset: sec(10), Text: "Loot of a human: 16 gold coins, a sword, a bag."},
+		data.ContainerOpen{TimeOffset: sec(12), ContainerID: 1, ItemID: corpse, Name: "dead human", Items: []data.Thing{
+			item(gold, 16), item(sword, 0), item(bag, 0),
+		}},
+		// Gold merged into the existing stack.
+		data.ContainerItemRemove{TimeOffset: sec(13), ContainerID: 1, Slot: 0},
+		data.ContainerItemUpdate{TimeOffset: sec(13), ContainerID: 0, Slot: 0, Thing: item(gold, 26)},
+		// Moving items around the backpack isn't loot.
+		data.ContainerItemRemove{TimeOffset: sec(14), ContainerID: 0, Slot: 0},
+		data.ContainerItemAdd{TimeOffset: sec(14), ContainerID: 0, Thing: item(gold, 26)},
+		// A bag within the corpse still counts as the corpse.
+		data.ContainerOpen{TimeOffset: sec(15), ContainerID: 1, ItemID: bag, Name: "bag", HasParent: 1, Items: []data.Thing{item(ring, 0)}},
+		data.ContainerItemRemove{TimeOffset: sec(16), ContainerID: 1, Slot: 0},
+		data.ContainerItemAdd{TimeOffset: sec(16), ContainerID: 0, Thing: item(ring, 0)},
+		data.ContainerClose{TimeOffset: sec(17), ContainerID: 1},
+		data.Message{TimeOffset: sec(20), Text: "Loot of a rat: nothing."},
+		data.CamMetadata{Duration: 30 * time.Minute},
+	} {
+		tracker.Add(op)
+	}
+
+	want := LootReport{
+		Duration: 30 * time.Minute,
+		Drops: []LootDrop{
+			{
+				Time:    sec(10),
+				Monster: "human",
+				Items: []LootItem{
+					{Name: "gold coin", Count: 16},
+					{Name: "sword", Count: 1},
+					{Name: "bag", Count: 1},
+				},
+				Corpse: []LootItem{
+					{ItemID: gold, Count: 16},
+					{ItemID: sword, Count: 1},
+					{ItemID: bag, Count: 1},
+				},
+				Verified: true,
+			},
+			{Time: sec(20), Monster: "rat"},
+		},
+		Monsters: map[string]*MonsterLoot{
+			"human": {Drops: 1, Items: map[string]int{"gold coin": 16, "sword": 1, "bag": 1}},
+			"rat":   {Drops: 1, Items: map[string]int{}},
+		},
+		Items:          map[string]int{"gold coin": 16, "sword": 1, "bag": 1},
+		ItemsPerHour:   map[string]float64{"gold coin": 32, "sword": 2, "bag": 2},
+		Looted:         map[uint16]int{gold: 16, ring: 1},
+		LootedByCorpse: map[string]map[uint16]int{"dead human": {gold: 16, ring: 1}},
+		LootedPerHour:  map[uint16]float64{gold: 32, ring: 2},
+	}
+	if diff := cmp.Diff(want, tracker.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+// recordings holds per-recording state of analyzers fed with many recordings, keyed by path.
+// It implements the concurrency rules described on CreatureCatalog. The zero value is ready to use.
+type recordings[T any] struct {
+	mu   sync.Mutex
+	recs map[string]*recording[T]
+}
+
+// recording is the state of a single recording, created once.
+type recording[T any] struct {
+	once  sync.Once
+	ready atomic.Bool // Set once rec was created without an error.
+	rec   T
+	err   error
+}
+
+// get returns the state of the recording at path, creating it with create the first time.
+// create is called once per path, without holding the lock, as it may e.g. read a .dat file; concurrent
+// calls for the same path wait for it. Its error is returned by every call for that path.
+func (r *recordings[T]) get(path string, create func() (T, error)) (T, error) {
+	r.mu.Lock()
+	if r.recs == nil {
+		r.recs = map[string]*recording[T]{}
+	}
+	rec, ok := r.recs[path]
+	if !ok {
+		rec = &recording[T]{}
+		r.recs[path] = rec
+	}
+	r.mu.Unlock()
+
+	rec.once.Do(func() {
+		rec.rec, rec.err = create()
+		rec.ready.Store(rec.err == nil)
+	})
+	return rec.rec, rec.err
+}
+
+// all returns the state of every recording created without an error by path, in no particular order.
+func (r *recordings[T]) all() iter.Seq2[string, T] {
+	return func(yield func(string, T) bool) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for path, rec := range r.recs {
+			if !rec.ready.Load() {
+				continue
+			}
+			if !yield(path, rec.rec) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRecordings_Get(t *testing.T) {
+	var r recordings[*int]
+	var created atomic.Int32
+
+	var wg sync.WaitGroup
+	got := make([]*int, 8)
+	for i := range got {
+		wg.Go(func() {
+			rec, err := r.get("a.cam", func() (*int, error) {
+				created.Add(1)
+				return new(int), nil
+			})
+			if err != nil {
+				t.Errorf("get() error: %v", err)
+			}
+			got[i] = rec
+		})
+	}
+	wg.Wait()
+
+	if n := created.Load(); n != 1 {
+		t.Errorf("create called %d times, want 1", n)
+	}
+	for i, rec := range got {
+		if rec != got[0] {
+			t.Errorf("get() #%d returned a different recording than #0", i)
+		}
+	}
+}
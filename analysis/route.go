@@ -0,0 +1,317 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// StepKind classifies a RouteStep.
+type StepKind int
+
+const (
+	StepStart StepKind = iota
+	StepWalk
+	StepFloorChange // Stairs, ladders, holes and ropes.
+	StepTeleport    // Any other jump, e.g. teleports, magic ropes or logging in elsewhere.
+)
+
+var stepKindName = map[StepKind]string{
+	StepStart:       "start",
+	StepWalk:        "walk",
+	StepFloorChange: "floor change",
+	StepTeleport:    "teleport",
+}
+
+func (k StepKind) String() string {
+	if n, ok := stepKindName[k]; ok {
+		return n
+	}
+	return "StepKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// RouteStep is a change of the player's position.
+type RouteStep struct {
+	Time     time.Duration
+	Location data.Location
+	Kind     StepKind
+}
+
+// RouteArea is a named box of tiles, bounds included.
+type RouteArea struct {
+	Name     string
+	From, To data.Location
+}
+
+// Contains reports whether l lies within the area.
+func (a RouteArea) Contains(l data.Location) bool {
+	return l.X >= a.From.X && l.X <= a.To.X &&
+		l.Y >= a.From.Y && l.Y <= a.To.Y &&
+		l.Z >= a.From.Z && l.Z <= a.To.Z
+}
+
+// RouteOpts controls the behavior of RouteTracker.
+type RouteOpts struct {
+	// Heatmap cell width and height in tiles. Defaults to 1.
+	CellSize int
+
+	// Areas to measure time spent in. They may overlap.
+	Areas []RouteArea
+}
+
+// RouteReport summarizes the player's movement over a recording.
+// Time is attributed to a position from the step reaching it until the next one.
+type RouteReport struct {
+	Duration time.Duration
+	Path     []RouteStep
+
+	Distance        int         // Tiles walked, diagonal steps included.
+	DistanceByFloor map[int]int // Tiles walked by floor.
+	FloorChanges    int
+	Teleports       int
+
+	TimeByFloor map[int]time.Duration
+	TimeByArea  map[string]time.Duration // By RouteArea name.
+
+	Heatmaps map[int]*Heatmap // By floor.
+}
+
+// RouteTracker follows PlayerPos to reconstruct the player's path.
+type RouteTracker struct {
+	opts RouteOpts
+	end  time.Duration
+	path []RouteStep
+}
+
+// NewRouteTracker initializes a RouteTracker. opts may be nil.
+func NewRouteTracker(opts *RouteOpts) *RouteTracker {
+	t := &RouteTracker{}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.CellSize <= 0 {
+		t.opts.CellSize = 1
+	}
+	return t
+}
+
+// Add processes the next operation.
+func (t *RouteTracker) Add(op data.Operation) {
+	t.end = max(t.end, data.TimeOffsetOf(op))
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	pos := data.PlayerPosOf(op)
+	if pos == (data.Location{}) {
+		// Not logged in yet.
+		return
+	}
+	step := RouteStep{Time: data.TimeOffsetOf(op), Location: pos, Kind: StepStart}
+	if len(t.path) > 0 {
+		last := t.path[len(t.path)-1].Location
+		if pos == last {
+			return
+		}
+		step.Kind = stepKind(op, last, pos)
+	}
+	t.path = append(t.path, step)
+}
+
+func stepKind(op data.Operation, from, to data.Location) StepKind {
+	dist := max(abs(to.X-from.X), abs(to.Y-from.Y))
+	switch op.(type) {
+	case data.MoveFloorUp, data.MoveFloorDown:
+		if dist <= 1 {
+			return StepFloorChange
+		}
+	}
+	switch dz := abs(to.Z - from.Z); {
+	case dz == 0 && dist <= 1:
+		return StepWalk
+	case dz == 1 && dist <= 1:
+		return StepFloorChange
+	}
+	return StepTeleport
+}
+
+func abs(n int) int {
+	return max(n, -n)
+}
+
+// Report summarizes all operations added so far.
+func (t *RouteTracker) Report() RouteReport {
+	r := RouteReport{
+		Duration:        t.end,
+		Path:            append([]RouteStep(nil), t.path...),
+		DistanceByFloor: map[int]int{},
+		TimeByFloor:     map[int]time.Duration{},
+		TimeByArea:      map[string]time.Duration{},
+		Heatmaps:        map[int]*Heatmap{},
+	}
+
+	for i, s := range t.path {
+		switch s.Kind {
+		case StepWalk:
+			r.Distance++
+			r.DistanceByFloor[s.Location.Z]++
+		case StepFloorChange:
+			r.FloorChanges++
+		case StepTeleport:
+			r.Teleports++
+		}
+
+		until := t.end
+		if i+1 < len(t.path) {
+			until = t.path[i+1].Time
+		}
+		d := until - s.Time
+		r.TimeByFloor[s.Location.Z] += d
+		for _, a := range t.opts.Areas {
+			if a.Contains(s.Location) {
+				r.TimeByArea[a.Name] += d
+			}
+		}
+
+		h, ok := r.Heatmaps[s.Location.Z]
+		if !ok {
+			h = &Heatmap{Z: s.Location.Z, CellSize: t.opts.CellSize, Cells: map[data.Location]time.Duration{}}
+			r.Heatmaps[s.Location.Z] = h
+		}
+		h.Cells[h.cell(s.Location)] += d
+	}
+	return r
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// Heatmap holds time spent on a single floor in a grid of cells.
+// Only visited cells are held, so that a teleport across the map doesn't blow up the grid.
+type Heatmap struct {
+	Z        int
+	CellSize int                             // In tiles.
+	Cells    map[data.Location]time.Duration // By the top-left tile of each cell.
+}
+
+// maxHeatmapPixels bounds the size of images rendered by Heatmap.Image, which are dense.
+const maxHeatmapPixels = 1 << 24
+
+// cell returns the top-left tile of the cell holding l.
+func (h *Heatmap) cell(l data.Location) data.Location {
+	return data.Location{X: floorDiv(l.X, h.CellSize) * h.CellSize, Y: floorDiv(l.Y, h.CellSize) * h.CellSize, Z: h.Z}
+}
+
+// Bounds returns the top-left tiles of the top-left and bottom-right visited cells.
+// Both are zero if no cell was visited.
+func (h *Heatmap) Bounds() (from, to data.Location) {
+	first := true
+	for c := range h.Cells {
+		if first {
+			from, to, first = c, c, false
+			continue
+		}
+		from.X, from.Y = min(from.X, c.X), min(from.Y, c.Y)
+		to.X, to.Y = max(to.X, c.X), max(to.Y, c.Y)
+	}
+	return from, to
+}
+
+// size returns the number of columns and rows of cells within Bounds.
+func (h *Heatmap) size() (cols, rows int) {
+	if len(h.Cells) == 0 {
+		return 0, 0
+	}
+	from, to := h.Bounds()
+	return (to.X-from.X)/h.CellSize + 1, (to.Y-from.Y)/h.CellSize + 1
+}
+
+// Max returns the longest time spent in a single cell.
+func (h *Heatmap) Max() time.Duration {
+	var m time.Duration
+	for _, d := range h.Cells {
+		m = max(m, d)
+	}
+	return m
+}
+
+// WriteCSV writes the grid within Bounds in seconds, one row at a time.
+// The header row holds the X of each column and the first column the Y of each row.
+func (h *Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cols, rows := h.size()
+	if cols == 0 {
+		cw.Flush()
+		return cw.Error()
+	}
+	from, _ := h.Bounds()
+	header := []string{"y\\x"}
+	for i := range cols {
+		header = append(header, strconv.Itoa(from.X+i*h.CellSize))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	rec := make([]string, cols+1)
+	for i := range rows {
+		y := from.Y + i*h.CellSize
+		rec[0] = strconv.Itoa(y)
+		for j := range cols {
+			d := h.Cells[data.Location{X: from.X + j*h.CellSize, Y: y, Z: h.Z}]
+			rec[j+1] = strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Image renders the grid within Bounds with scale pixels per cell.
+// Unvisited cells are transparent; visited ones range from yellow to red with time spent.
+// It fails if the image would exceed 16 megapixels, e.g. after a teleport across the map; a larger
+// CellSize or a smaller scale helps.
+func (h *Heatmap) Image(scale int) (image.Image, error) {
+	scale = max(scale, 1)
+	cols, rows := h.size()
+	if w, ht := int64(cols)*int64(scale), int64(rows)*int64(scale); w*ht > maxHeatmapPixels {
+		return nil, fmt.Errorf("heatmap image of %dx%d pixels exceeds %d", w, ht, maxHeatmapPixels)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, cols*scale, rows*scale))
+	from, _ := h.Bounds()
+	top := h.Max()
+	for c, d := range h.Cells {
+		if d <= 0 {
+			continue
+		}
+		x, y := (c.X-from.X)/h.CellSize, (c.Y-from.Y)/h.CellSize
+		col := color.NRGBA{R: 255, G: uint8(255 - 255*d/top), A: 255}
+		for py := y * scale; py < (y+1)*scale; py++ {
+			for px := x * scale; px < (x+1)*scale; px++ {
+				img.SetNRGBA(px, py, col)
+			}
+		}
+	}
+	return img, nil
+}
+
+// WritePNG writes Image(scale) as PNG.
+func (h *Heatmap) WritePNG(w io.Writer, scale int) error {
+	img, err := h.Image(scale)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
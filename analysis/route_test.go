@@ -0,0 +1,111 @@
+package analysis
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func TestRouteTracker(t *testing.T) {
+	sec := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	loc := func(x, y, z int) data.Location { return data.Location{X: x, Y: y, Z: z} }
+
+	tracker := NewRouteTracker(&RouteOpts{
+		CellSize: 2,
+		Areas:    []RouteArea{{Name: "cave", From: loc(99, 99, 8), To: loc(110, 110, 8)}},
+	})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{TimeOffset: sec(0), PlayerPos: loc(100, 100, 7)},
+		data.MoveEast{TimeOffset: sec(10), PlayerPos: loc(101, 100, 7)},
+		data.CreatureHealth{TimeOffset: sec(12), PlayerPos: loc(101, 100, 7)},
+		data.MoveNorth{TimeOffset: sec(20), PlayerPos: loc(101, 99, 7)},
+		// Stairs shift the position along with the floor.
+		data.MoveFloorDown{TimeOffset: sec(30), PlayerPos: loc(100, 98, 8)},
+		data.MoveSouth{TimeOffset: sec(40), PlayerPos: loc(100, 99, 8)},
+		data.Map{TimeOffset: sec(50), PlayerPos: loc(200, 200, 7)},
+		data.CamMetadata{Duration: sec(60)},
+	} {
+		tracker.Add(op)
+	}
+
+	want := RouteReport{
+		Duration: sec(60),
+		Path: []RouteStep{
+			{Time: sec(0), Location: loc(100, 100, 7), Kind: StepStart},
+			{Time: sec(10), Location: loc(101, 100, 7), Kind: StepWalk},
+			{Time: sec(20), Location: loc(101, 99, 7), Kind: StepWalk},
+			{Time: sec(30), Location: loc(100, 98, 8), Kind: StepFloorChange},
+			{Time: sec(40), Location: loc(100, 99, 8), Kind: StepWalk},
+			{Time: sec(50), Location: loc(200, 200, 7), Kind: StepTeleport},
+		},
+		Distance:        3,
+		DistanceByFloor: map[int]int{7: 2, 8: 1},
+		FloorChanges:    1,
+		Teleports:       1,
+		TimeByFloor:     map[int]time.Duration{7: sec(40), 8: sec(20)},
+		TimeByArea:      map[string]time.Duration{"cave": sec(10)},
+		Heatmaps: map[int]*Heatmap{
+			7: {Z: 7, CellSize: 2, Cells: map[data.Location]time.Duration{
+				loc(100, 98, 7):  sec(10), // (101,99)
+				loc(100, 100, 7): sec(20), // (100,100), (101,100)
+				loc(200, 200, 7): sec(10),
+			}},
+			8: {Z: 8, CellSize: 2, Cells: map[data.Location]time.Duration{loc(100, 98, 8): sec(20)}},
+		},
+	}
+	got := tracker.Report()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+
+	var csv bytes.Buffer
+	if err := got.Heatmaps[8].WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV() err = %v", err)
+	}
+	if diff := cmp.Diff("y\\x,100\n98,20\n", csv.String()); diff != "" {
+		t.Errorf("WriteCSV() diff; -want +got:\n%v", diff)
+	}
+
+	// 51 by 52 cells from (100,98) to (201,201).
+	img, err := got.Heatmaps[7].Image(3)
+	if err != nil {
+		t.Fatalf("Image(3) err = %v", err)
+	}
+	if got, want := img.Bounds().Dx(), 51*3; got != want {
+		t.Errorf("Image(3) width = %d; want %d", got, want)
+	}
+	for _, tc := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{x: 1, y: 4, want: color.NRGBA{R: 255, A: 255}},
+		{x: 2, y: 2, want: color.NRGBA{R: 255, G: 128, A: 255}},
+		{x: 10, y: 10, want: color.NRGBA{}},
+	} {
+		if got := img.At(tc.x, tc.y); got != tc.want {
+			t.Errorf("Image(3).At(%d, %d) = %v; want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+	if err := got.Heatmaps[7].WritePNG(&bytes.Buffer{}, 1); err != nil {
+		t.Errorf("WritePNG() err = %v", err)
+	}
+}
+
+func TestHeatmap_Teleport(t *testing.T) {
+	loc := func(x, y int) data.Location { return data.Location{X: x, Y: y, Z: 7} }
+	h := &Heatmap{Z: 7, CellSize: 1, Cells: map[data.Location]time.Duration{
+		loc(100, 100):     time.Second,
+		loc(60000, 60000): time.Second,
+	}}
+	if _, err := h.Image(1); err == nil {
+		t.Errorf("Image(1) of a %v to %v heatmap err = nil; want an error", loc(100, 100), loc(60000, 60000))
+	}
+	if err := h.WritePNG(&bytes.Buffer{}, 1); err == nil {
+		t.Errorf("WritePNG() err = nil; want an error")
+	}
+}
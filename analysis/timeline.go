@@ -0,0 +1,373 @@
+package analysis
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// TimelineOpts controls the behavior of Timeline.
+type TimelineOpts struct {
+	// Location is the time zone of the server. Zone abbreviations in server messages that Go doesn't know are
+	// interpreted in it, and reported times are converted to it. Defaults to UTC.
+	Location *time.Location
+
+	// Start or End is the wall-clock time of the first or last packet, if known from outside the recording,
+	// e.g. from file metadata. The .cam header carries no time. Start takes precedence over End.
+	Start time.Time
+	End   time.Time
+
+	// ServerSave is the time of day of the daily server save in Location, e.g. 10*time.Hour.
+	// A save at midnight is 24*time.Hour. If set, server save countdown messages anchor the recording more
+	// precisely than End, which only needs to be right within half a day to tell the date.
+	ServerSave time.Duration
+
+	// Gaps between player activities longer than IdleAfter count as idle time. Defaults to 2 minutes.
+	IdleAfter time.Duration
+
+	// An activity stops outweighing less important ones Window after it was last seen. Defaults to 1 minute.
+	Window time.Duration
+
+	// Gaps between packets longer than Gap count as disconnects. Defaults to 5 minutes.
+	Gap time.Duration
+}
+
+// TimeAnchor tells where the wall-clock time of a TimelineReport comes from.
+type TimeAnchor int
+
+const (
+	AnchorNone       TimeAnchor = iota
+	AnchorServerSave            // A server save countdown and TimelineOpts.ServerSave, dated by TimelineOpts.End.
+	AnchorEnd                   // TimelineOpts.End.
+	AnchorStart                 // TimelineOpts.Start.
+)
+
+var timeAnchorName = map[TimeAnchor]string{
+	AnchorNone:       "none",
+	AnchorServerSave: "server save",
+	AnchorEnd:        "end",
+	AnchorStart:      "start",
+}
+
+func (a TimeAnchor) String() string {
+	if n, ok := timeAnchorName[a]; ok {
+		return n
+	}
+	return "TimeAnchor(" + strconv.Itoa(int(a)) + ")"
+}
+
+// SegmentKind classifies a TimelineSegment.
+// Activities are ordered by importance: a more important one outweighs others going on at the same time.
+type SegmentKind int
+
+const (
+	SegmentIdle     SegmentKind = iota
+	SegmentWalking              // The player moving around.
+	SegmentChatting             // The player speaking or sending private messages.
+	SegmentTrading              // A trade window open.
+	SegmentHunting              // The player gaining experience, looting or taking damage.
+
+	SegmentLogin      // The player logging in; Start equals End.
+	SegmentLogout     // The server ending the session with LoginError, until the next packet.
+	SegmentDisconnect // No packets for longer than TimelineOpts.Gap, or a login without a logout before it.
+)
+
+var segmentKindName = map[SegmentKind]string{
+	SegmentIdle:       "idle",
+	SegmentWalking:    "walking",
+	SegmentChatting:   "chatting",
+	SegmentTrading:    "trading",
+	SegmentHunting:    "hunting",
+	SegmentLogin:      "login",
+	SegmentLogout:     "logout",
+	SegmentDisconnect: "disconnect",
+}
+
+func (k SegmentKind) String() string {
+	if n, ok := segmentKindName[k]; ok {
+		return n
+	}
+	return "SegmentKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// TimelineSegment is a stretch of a recording spent on a single activity.
+type TimelineSegment struct {
+	Kind       SegmentKind
+	Start, End time.Duration
+	From, To   time.Time // Zero without an anchor.
+	Text       string    // The message of a logout.
+}
+
+// TimelineReport splits a recording into segments.
+type TimelineReport struct {
+	Anchor TimeAnchor
+	Start  time.Time // Wall-clock time of the first packet; zero without an anchor.
+
+	// NotBefore is the previous login from CamMetadata.LastVisit, if known. The recording started no earlier,
+	// but possibly days later, so it doesn't anchor the recording.
+	NotBefore time.Time
+
+	// Segments are contiguous, apart from login instants.
+	Segments []TimelineSegment
+}
+
+// Time returns the wall-clock time of a TimeOffset, or the zero time without an anchor.
+func (r TimelineReport) Time(offset time.Duration) time.Time {
+	if r.Start.IsZero() {
+		return time.Time{}
+	}
+	return r.Start.Add(offset)
+}
+
+// Timeline anchors a recording to wall-clock time and splits it into activity segments.
+type Timeline struct {
+	opts TimelineOpts
+
+	end       time.Duration
+	lastVisit time.Time
+	saves     []time.Duration // Offsets of server saves announced by countdowns.
+
+	playerID   uint32
+	playerName string
+	loggedIn   bool
+	loggedOut  bool // A LoginError since the last login.
+	logout     int  // Index of a logout mark waiting for the next packet, or -1.
+	seenOp     bool
+	exp        uint32
+	expSeen    bool
+
+	marks []timelineMark
+}
+
+type timelineMark struct {
+	kind     SegmentKind
+	at, till time.Duration // till is only set for disconnects and logouts.
+	text     string
+}
+
+// saveRe matches server save countdowns, e.g. "Server is saving game in 5 minutes. Please come back in 10 minutes.".
+var saveRe = regexp.MustCompile(`(?i)\bserver is (?:saving game|going down|shutting down)\b.*?\bin (\d+) minutes?`)
+
+// NewTimeline initializes a Timeline. opts may be nil.
+func NewTimeline(opts *TimelineOpts) *Timeline {
+	t := &Timeline{logout: -1}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.Location == nil {
+		t.opts.Location = time.UTC
+	}
+	if t.opts.IdleAfter <= 0 {
+		t.opts.IdleAfter = 2 * time.Minute
+	}
+	if t.opts.Window <= 0 {
+		t.opts.Window = time.Minute
+	}
+	if t.opts.Gap <= 0 {
+		t.opts.Gap = 5 * time.Minute
+	}
+	return t
+}
+
+// Add processes the next operation. Operations must not be filtered by type, so that gaps are found.
+func (t *Timeline) Add(op data.Operation) {
+	if m, ok := op.(data.CamMetadata); ok {
+		t.end = max(t.end, m.Duration)
+		t.lastVisit = m.LastVisit
+		return
+	}
+
+	offset := data.TimeOffsetOf(op)
+	if t.logout >= 0 && offset > t.marks[t.logout].at {
+		// The player is offline until the next packet.
+		t.marks[t.logout].till = offset
+		t.logout = -1
+	}
+	gap := t.seenOp && !t.loggedOut && offset-t.end > t.opts.Gap
+	if gap {
+		t.marks = append(t.marks, timelineMark{kind: SegmentDisconnect, at: t.end, till: offset})
+	}
+	last := t.end
+	t.end, t.seenOp = max(t.end, offset), true
+
+	for _, c := range creaturesOf(op) {
+		if c.ID == t.playerID && c.Name != "" {
+			t.playerName = c.Name
+		}
+	}
+
+	switch op := op.(type) {
+	case data.LoginPlayerState:
+		if t.loggedIn && !t.loggedOut && !gap {
+			// The client reconnected without being logged out.
+			t.marks = append(t.marks, timelineMark{kind: SegmentDisconnect, at: last, till: offset})
+		}
+		t.playerID, t.loggedIn, t.loggedOut = op.PlayerID, true, false
+		t.mark(SegmentLogin, offset)
+	case data.LoginError:
+		t.loggedOut, t.logout = true, len(t.marks)
+		t.marks = append(t.marks, timelineMark{kind: SegmentLogout, at: offset, till: offset, text: op.Message})
+	case data.MoveNorth, data.MoveEast, data.MoveSouth, data.MoveWest, data.MoveFloorUp, data.MoveFloorDown:
+		t.mark(SegmentWalking, offset)
+	case data.CreatureMessage:
+		if op.Name != "" && op.Name == t.playerName {
+			t.mark(SegmentChatting, offset)
+		}
+	case data.TradeOwn, data.TradeCounter, data.TradeClose:
+		t.mark(SegmentTrading, offset)
+	case data.Message:
+		text := strings.TrimSpace(op.Text)
+		switch {
+		case strings.HasPrefix(text, "Message sent to "):
+			t.mark(SegmentChatting, offset)
+		case strings.HasPrefix(text, "Loot of "), loseRe.MatchString(text):
+			t.mark(SegmentHunting, offset)
+		}
+		if m := saveRe.FindStringSubmatch(text); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			t.saves = append(t.saves, offset+time.Duration(n)*time.Minute)
+		}
+	case data.PlayerStats:
+		// The first stats after logging in carry the current experience.
+		if t.expSeen && op.Exp > t.exp {
+			t.mark(SegmentHunting, offset)
+		}
+		t.exp, t.expSeen = op.Exp, true
+	case data.CreatureSquare:
+		t.mark(SegmentHunting, offset)
+	case data.EffectText:
+		if _, ok := textColorKind[op.Color]; ok && op.Location == op.PlayerPos {
+			t.mark(SegmentHunting, offset)
+		}
+	}
+}
+
+func (t *Timeline) mark(kind SegmentKind, at time.Duration) {
+	t.marks = append(t.marks, timelineMark{kind: kind, at: at})
+}
+
+// Report anchors the recording and splits it into segments.
+func (t *Timeline) Report() TimelineReport {
+	var r TimelineReport
+	r.Anchor, r.Start = t.anchor()
+	r.NotBefore = inZone(t.lastVisit, t.opts.Location)
+	r.Segments = t.segments()
+	for i := range r.Segments {
+		s := &r.Segments[i]
+		s.From, s.To = r.Time(s.Start), r.Time(s.End)
+	}
+	return r
+}
+
+// anchor returns the wall-clock time of the first packet, using the most reliable source available.
+func (t *Timeline) anchor() (TimeAnchor, time.Time) {
+	loc := t.opts.Location
+	switch {
+	case !t.opts.Start.IsZero():
+		return AnchorStart, t.opts.Start.In(loc)
+	case t.opts.End.IsZero():
+		// A server save alone doesn't tell the day.
+		return AnchorNone, time.Time{}
+	case t.opts.ServerSave > 0 && len(t.saves) > 0:
+		// Take the save closest to when End puts it.
+		save := t.saves[0]
+		approx := t.opts.End.Add(save - t.end).In(loc)
+		y, m, d := approx.Date()
+		at := time.Date(y, m, d, 0, 0, 0, 0, loc).Add(t.opts.ServerSave)
+		for _, c := range []time.Time{at.AddDate(0, 0, -1), at.AddDate(0, 0, 1)} {
+			if d, best := c.Sub(approx), at.Sub(approx); max(d, -d) < max(best, -best) {
+				at = c
+			}
+		}
+		return AnchorServerSave, at.Add(-save)
+	}
+	return AnchorEnd, t.opts.End.Add(-t.end).In(loc)
+}
+
+// inZone returns t in loc. Times parsed with a zone abbreviation unknown to Go carry a made-up zone at UTC,
+// so their clock reading is taken to be in loc.
+func inZone(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if name, offset := t.Zone(); offset == 0 && name != "UTC" && name != "GMT" {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+	return t.In(loc)
+}
+
+// segments merges marks into contiguous segments.
+func (t *Timeline) segments() []TimelineSegment {
+	var (
+		segs []TimelineSegment
+		cur  *TimelineSegment
+
+		pos     time.Duration // End of the last segment.
+		lastAny time.Duration // Last activity of any kind.
+		lastCur time.Duration // Last activity of the current segment's kind.
+	)
+	emit := func(s TimelineSegment) {
+		segs = append(segs, s)
+		pos = s.End
+	}
+	open := func(kind SegmentKind, start, at time.Duration) {
+		cur = &TimelineSegment{Kind: kind, Start: start}
+		lastCur = at
+	}
+	// closeAt ends the current activity at, or idle time until, the given offset.
+	closeAt := func(at time.Duration) {
+		if cur != nil && at-lastAny > t.opts.IdleAfter {
+			cur.End = lastAny
+			emit(*cur)
+			cur = nil
+		}
+		if cur != nil {
+			cur.End = at
+			emit(*cur)
+			cur = nil
+		} else if at > pos {
+			emit(TimelineSegment{Kind: SegmentIdle, Start: pos, End: at})
+		}
+	}
+
+	for _, m := range t.marks {
+		switch {
+		case m.kind == SegmentDisconnect || m.kind == SegmentLogout:
+			closeAt(m.at)
+			emit(TimelineSegment{Kind: m.kind, Start: m.at, End: m.till, Text: m.text})
+			lastAny = m.till
+		case m.kind == SegmentLogin:
+			closeAt(m.at)
+			emit(TimelineSegment{Kind: m.kind, Start: m.at, End: m.at})
+			lastAny = m.at
+		case cur == nil:
+			start := pos
+			if m.at-pos > t.opts.IdleAfter {
+				emit(TimelineSegment{Kind: SegmentIdle, Start: pos, End: m.at})
+				start = m.at
+			}
+			open(m.kind, start, m.at)
+		case m.at-lastAny > t.opts.IdleAfter:
+			closeAt(m.at)
+			open(m.kind, m.at, m.at)
+		case m.kind == cur.Kind:
+			lastCur = m.at
+		case m.kind > cur.Kind:
+			cur.End = m.at
+			emit(*cur)
+			open(m.kind, m.at, m.at)
+		case m.at-lastCur > t.opts.Window:
+			// The current activity is over; the less important one started after it was last seen.
+			cur.End = lastCur
+			emit(*cur)
+			open(m.kind, lastCur, m.at)
+		}
+		lastAny = max(lastAny, m.at)
+	}
+	closeAt(t.end)
+	return segs
+}
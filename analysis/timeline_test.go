@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/data"
+)
+
+func TestTimeline(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := data.Location{X: 100, Y: 100, Z: 7}
+	step := func(at int) data.Operation { return data.MoveNorth{TimeOffset: s(at), PlayerPos: pos} }
+
+	// The file was last modified a few minutes after the recording ended.
+	end := time.Date(2025, time.November, 28, 10, 9, 0, 0, warsaw)
+	tl := NewTimeline(&TimelineOpts{Location: warsaw, ServerSave: 10 * time.Hour, End: end})
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{{Location: pos, Things: []data.Thing{
+			{HasCreature: true, Creature: data.Creature{ID: 1, Name: "Hunter"}},
+		}}}},
+		data.PlayerStats{Exp: 100},
+		step(1),
+		step(2),
+		data.CreatureMessage{TimeOffset: s(10), Name: "Hunter", Type: data.SpeakSay, Text: "hi"},
+		step(20),
+		data.PlayerStats{TimeOffset: s(30), Exp: 150},
+		data.CreatureSquare{TimeOffset: s(40), CreatureID: 0x40000001},
+		step(50),
+		step(120),
+		data.TradeOwn{TimeOffset: s(130), Name: "Hunter"},
+		data.TradeClose{TimeOffset: s(131)},
+		step(300),
+		data.Message{TimeOffset: s(310), Type: data.MessageWarning, Text: "Server is saving game in 5 minutes. Please come back in 10 minutes."},
+		data.LoginError{TimeOffset: s(320), Message: "Server is going down."},
+		data.LoginPlayerState{TimeOffset: s(1000), PlayerID: 1},
+		step(1001),
+		data.LoginPlayerState{TimeOffset: s(1002), PlayerID: 1},
+		step(1003),
+		data.CamMetadata{Duration: s(1010), LastVisit: time.Date(2025, time.November, 25, 21, 0, 0, 0, warsaw)},
+	} {
+		tl.Add(op)
+	}
+
+	// The save at 10:00 was announced 610 seconds into the recording.
+	start := time.Date(2025, time.November, 28, 9, 49, 50, 0, warsaw)
+	segment := func(kind SegmentKind, from, to int, text string) TimelineSegment {
+		return TimelineSegment{Kind: kind, Start: s(from), End: s(to), From: start.Add(s(from)), To: start.Add(s(to)), Text: text}
+	}
+	want := TimelineReport{
+		Anchor:    AnchorServerSave,
+		Start:     start,
+		NotBefore: time.Date(2025, time.November, 25, 21, 0, 0, 0, warsaw),
+		Segments: []TimelineSegment{
+			segment(SegmentLogin, 0, 0, ""),
+			segment(SegmentWalking, 0, 10, ""),
+			segment(SegmentChatting, 10, 30, ""),
+			segment(SegmentHunting, 30, 40, ""),
+			segment(SegmentWalking, 40, 130, ""),
+			segment(SegmentTrading, 130, 131, ""),
+			segment(SegmentIdle, 131, 300, ""),
+			segment(SegmentWalking, 300, 320, ""),
+			segment(SegmentLogout, 320, 1000, "Server is going down."),
+			segment(SegmentLogin, 1000, 1000, ""),
+			segment(SegmentWalking, 1000, 1001, ""),
+			segment(SegmentDisconnect, 1001, 1002, ""),
+			segment(SegmentLogin, 1002, 1002, ""),
+			segment(SegmentWalking, 1002, 1010, ""),
+		},
+	}
+	if diff := cmp.Diff(want, tl.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestTimeline_Anchor(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An abbreviation unknown to Go parses as a made-up zone at UTC.
+	lastVisit, err := time.Parse("02. Jan 2006 15:04:05 MST", "28. Nov 2025 14:37:22 XYZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := time.Date(2025, time.November, 28, 14, 37, 22, 0, warsaw)
+	end := time.Date(2025, time.November, 28, 16, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name          string
+		opts          TimelineOpts
+		lastVisit     bool
+		wantAnchor    TimeAnchor
+		wantStart     time.Time
+		wantNotBefore time.Time
+	}{
+		{"none", TimelineOpts{Location: warsaw}, false, AnchorNone, time.Time{}, time.Time{}},
+		{"last visit", TimelineOpts{Location: warsaw}, true, AnchorNone, time.Time{}, notBefore},
+		{"end", TimelineOpts{Location: warsaw, End: end}, true, AnchorEnd, time.Date(2025, time.November, 28, 16, 50, 0, 0, warsaw), notBefore},
+		{"start", TimelineOpts{Start: end, End: end.Add(time.Hour)}, false, AnchorStart, end, time.Time{}},
+		// Days after the last visit, the save announced 10 minutes in is at 10:00 on the day End tells.
+		{
+			"server save",
+			TimelineOpts{Location: warsaw, ServerSave: 10 * time.Hour, End: time.Date(2025, time.December, 1, 10, 20, 0, 0, warsaw)},
+			true, AnchorServerSave, time.Date(2025, time.December, 1, 9, 45, 0, 0, warsaw), notBefore,
+		},
+		// Without End, the day of the save is unknown.
+		{"server save without a date", TimelineOpts{Location: warsaw, ServerSave: 10 * time.Hour}, true, AnchorNone, time.Time{}, notBefore},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := NewTimeline(&tt.opts)
+			tl.Add(data.LoginPlayerState{})
+			tl.Add(data.Message{TimeOffset: 10 * time.Minute, Type: data.MessageWarning, Text: "Server is saving game in 5 minutes. Please come back in 10 minutes."})
+			if tt.lastVisit {
+				tl.Add(data.CamMetadata{LastVisit: lastVisit})
+			}
+			r := tl.Report()
+			if r.Anchor != tt.wantAnchor || !r.Start.Equal(tt.wantStart) || !r.NotBefore.Equal(tt.wantNotBefore) {
+				t.Errorf("Report() = %v at %v not before %v, want %v at %v not before %v",
+					r.Anchor, r.Start, r.NotBefore, tt.wantAnchor, tt.wantStart, tt.wantNotBefore)
+			}
+		})
+	}
+}
+
+func TestTimeline_Recording(t *testing.T) {
+	b, err := os.ReadFile("../cam/testdata/tibiantis.cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl := NewTimeline(&TimelineOpts{Location: warsaw})
+	for op, err := range cam.Parse(bytes.NewReader(b), &cam.ParseOpts{DATFile: readDAT(t, "Tibiantis.dat")}) {
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		tl.Add(op)
+	}
+	r := tl.Report()
+
+	// "Your last visit in Tibiantis: 28. Nov 2025 14:37:22 CET."
+	if want := time.Date(2025, time.November, 28, 13, 37, 22, 0, time.UTC); r.Anchor != AnchorNone || !r.NotBefore.Equal(want) {
+		t.Errorf("Report() = %v not before %v, want %v not before %v", r.Anchor, r.NotBefore, AnchorNone, want)
+	}
+	kinds := map[SegmentKind]bool{}
+	var end time.Duration
+	for _, seg := range r.Segments {
+		kinds[seg.Kind] = true
+		if seg.Start != end && seg.Kind != SegmentLogin {
+			t.Errorf("Report() has %v starting at %v, want %v", seg.Kind, seg.Start, end)
+		}
+		end = seg.End
+	}
+	if !kinds[SegmentHunting] || !kinds[SegmentWalking] {
+		t.Errorf("Report() has segments of %v, want hunting and walking", kinds)
+	}
+}
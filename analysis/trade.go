@@ -0,0 +1,314 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// TradeOpts controls the behavior of TradeTracker.
+type TradeOpts struct {
+	// Item changes up to Window before or after a trade closes count as its outcome. Defaults to 1 second.
+	Window time.Duration
+}
+
+// TradeSession is a single trade window, from the first offer until it closed.
+type TradeSession struct {
+	Partner    string
+	OwnItems   []data.Item // The last offer of the player; containers are followed by their contents.
+	TheirItems []data.Item // The last offer of the partner.
+
+	// Accepted is inferred: the trade wasn't cancelled, and the player lost offered items or gained
+	// ones offered to them as it closed.
+	Accepted bool
+
+	Start, End time.Duration
+}
+
+// TradeReport lists trades over a recording.
+type TradeReport struct {
+	Sessions []TradeSession
+}
+
+// TradeTracker stitches TradeOwn, TradeCounter and TradeClose into trade sessions.
+//
+// The protocol doesn't tell completed trades from cancelled ones, so the outcome is inferred from
+// "Trade cancelled." messages and from inventory and container changes as the trade closes.
+type TradeTracker struct {
+	opts TradeOpts
+
+	sessions []TradeSession
+	open     *TradeSession
+
+	// closing is the last closed session while its outcome is being looked for.
+	closing *TradeSession
+
+	cancelled  bool
+	cancelTime time.Duration // Of the last "Trade cancelled." message.
+
+	world   *world.State  // Only containers and inventory are followed; tiles don't matter.
+	changes []tradeChange // Recent item changes.
+}
+
+type tradeChange struct {
+	time   time.Duration
+	id     uint16
+	gained bool
+}
+
+// tradeCancelled is the message shown when either side cancels a trade.
+const tradeCancelled = "Trade cancelled."
+
+// NewTradeTracker initializes a TradeTracker. opts may be nil.
+func NewTradeTracker(opts *TradeOpts) *TradeTracker {
+	t := &TradeTracker{world: world.New(nil)}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.Window <= 0 {
+		t.opts.Window = time.Second
+	}
+	return t
+}
+
+// Add processes the next operation.
+func (t *TradeTracker) Add(op data.Operation) {
+	if _, ok := op.(data.CamMetadata); ok {
+		return
+	}
+	offset := data.TimeOffsetOf(op)
+	if t.closing != nil && offset-t.closing.End > t.opts.Window {
+		t.settle()
+	}
+	horizon := offset - t.opts.Window
+	if t.closing != nil {
+		horizon = min(horizon, t.closing.End-t.opts.Window)
+	}
+	for len(t.changes) > 0 && t.changes[0].time < horizon {
+		t.changes = t.changes[1:]
+	}
+
+	switch op := op.(type) {
+	case data.TradeOwn:
+		t.session(op.TimeOffset).OwnItems = tradeItems(op.Items)
+	case data.TradeCounter:
+		s := t.session(op.TimeOffset)
+		s.Partner, s.TheirItems = op.Name, tradeItems(op.Items)
+	case data.TradeClose:
+		if s := t.open; s != nil {
+			t.open = nil
+			if t.closing != nil {
+				t.settle()
+			}
+			s.End = op.TimeOffset
+			t.closing = s
+		}
+	case data.Message:
+		if strings.TrimSpace(op.Text) == tradeCancelled {
+			t.cancelled, t.cancelTime = true, op.TimeOffset
+		}
+	case data.ContainerItemAdd:
+		if _, ok := t.world.Container(op.ContainerID); ok && op.Thing.HasItem {
+			t.change(offset, op.Thing.Item.ID, true)
+		}
+	case data.ContainerItemUpdate:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) && op.Thing.HasItem {
+			t.replace(offset, c.Items[op.Slot], op.Thing.Item)
+		}
+	case data.ContainerItemRemove:
+		if c, ok := t.world.Container(op.ContainerID); ok && int(op.Slot) < len(c.Items) {
+			t.change(offset, c.Items[op.Slot].ID, false)
+		}
+	case data.InventoryItemSet:
+		if old, ok := t.world.Inventory(op.Slot); ok {
+			t.replace(offset, old, op.Item)
+		} else {
+			t.change(offset, op.Item.ID, true)
+		}
+	case data.InventoryItemClear:
+		if old, ok := t.world.Inventory(op.Slot); ok {
+			t.change(offset, old.ID, false)
+		}
+	}
+	t.world.Apply(op)
+}
+
+// session returns the open session, starting one if needed.
+func (t *TradeTracker) session(at time.Duration) *TradeSession {
+	if t.open == nil {
+		t.open = &TradeSession{Start: at}
+	}
+	return t.open
+}
+
+func (t *TradeTracker) change(at time.Duration, id uint16, gained bool) {
+	t.changes = append(t.changes, tradeChange{time: at, id: id, gained: gained})
+}
+
+// replace records an item replaced in place, including count changes of a stack, e.g. gold merging with
+// gold received in a trade.
+func (t *TradeTracker) replace(at time.Duration, old, new data.Item) {
+	if old.ID != new.ID {
+		t.change(at, old.ID, false)
+		t.change(at, new.ID, true)
+		return
+	}
+	if d := int(new.Count) - int(old.Count); d != 0 {
+		t.change(at, old.ID, d > 0)
+	}
+}
+
+// settle records the closing session with its outcome.
+func (t *TradeTracker) settle() {
+	s := *t.closing
+	t.closing = nil
+	s.Accepted = t.accepted(s)
+	t.sessions = append(t.sessions, s)
+}
+
+// accepted infers the outcome of a closed session from item changes around its end.
+func (t *TradeTracker) accepted(s TradeSession) bool {
+	// The message may come right before or after the trade closes.
+	if d := t.cancelTime - s.End; t.cancelled && max(d, -d) <= t.opts.Window {
+		return false
+	}
+	for _, c := range t.changes {
+		if c.time < max(s.Start, s.End-t.opts.Window) || c.time > s.End+t.opts.Window {
+			continue
+		}
+		offered := s.OwnItems
+		if c.gained {
+			offered = s.TheirItems
+		}
+		if slices.ContainsFunc(offered, func(it data.Item) bool { return it.ID == c.id }) {
+			return true
+		}
+	}
+	return false
+}
+
+func tradeItems(things []data.Thing) []data.Item {
+	var items []data.Item
+	for _, th := range things {
+		if th.HasItem {
+			items = append(items, th.Item)
+		}
+	}
+	return items
+}
+
+// Report returns the sessions so far. A session still open is included as not accepted.
+func (t *TradeTracker) Report() TradeReport {
+	r := TradeReport{Sessions: slices.Clone(t.sessions)}
+	if t.closing != nil {
+		s := *t.closing
+		s.Accepted = t.accepted(s)
+		r.Sessions = append(r.Sessions, s)
+	}
+	if t.open != nil {
+		r.Sessions = append(r.Sessions, *t.open)
+	}
+	return r
+}
+
+// LedgerEntry is a trade within an archive.
+type LedgerEntry struct {
+	Recording string
+	Player    string // From CamMetadata; empty if unknown.
+	TradeSession
+}
+
+// TradeLedger collects trades across many recordings.
+//
+// Like CreatureCatalog, a single TradeLedger is fed with every recording.
+type TradeLedger struct {
+	opts TradeOpts
+	recs recordings[*tradeRecording]
+}
+
+type tradeRecording struct {
+	tracker *TradeTracker
+	player  string
+}
+
+// NewTradeLedger initializes a TradeLedger. opts may be nil.
+func NewTradeLedger(opts *TradeOpts) *TradeLedger {
+	l := &TradeLedger{}
+	if opts != nil {
+		l.opts = *opts
+	}
+	return l
+}
+
+// Add processes the next operation of the recording at path.
+// It never fails; the error is there to match batch.ParseAll.
+func (l *TradeLedger) Add(path string, op data.Operation) error {
+	rec, _ := l.recs.get(path, func() (*tradeRecording, error) {
+		return &tradeRecording{tracker: NewTradeTracker(&l.opts)}, nil
+	})
+
+	if m, ok := op.(data.CamMetadata); ok {
+		rec.player = m.PlayerName
+	}
+	rec.tracker.Add(op)
+	return nil
+}
+
+// Report returns trades from all recordings, ordered by recording and start.
+func (l *TradeLedger) Report() []LedgerEntry {
+	var ret []LedgerEntry
+	for path, rec := range l.recs.all() {
+		for _, s := range rec.tracker.Report().Sessions {
+			ret = append(ret, LedgerEntry{Recording: path, Player: rec.player, TradeSession: s})
+		}
+	}
+	slices.SortFunc(ret, func(a, b LedgerEntry) int {
+		return cmp.Or(cmp.Compare(a.Recording, b.Recording), cmp.Compare(a.Start, b.Start))
+	})
+	return ret
+}
+
+// WriteLedgerCSV writes ledger entries as CSV with a header row.
+// Items are written as space-separated "ID" or "IDxCount" for stacks.
+func WriteLedgerCSV(w io.Writer, entries []LedgerEntry) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	cw.Write([]string{"recording", "player", "partner", "start", "end", "accepted", "own items", "their items"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Recording,
+			e.Player,
+			e.Partner,
+			e.Start.String(),
+			e.End.String(),
+			strconv.FormatBool(e.Accepted),
+			ledgerItems(e.OwnItems),
+			ledgerItems(e.TheirItems),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func ledgerItems(items []data.Item) string {
+	var parts []string
+	for _, it := range items {
+		s := strconv.Itoa(int(it.ID))
+		if it.Count > 1 {
+			s += "x" + strconv.Itoa(int(it.Count))
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
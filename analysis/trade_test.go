@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func tradeOps() []data.Operation {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	item := func(id uint16, count byte) data.Thing {
+		return data.Thing{HasItem: true, Item: data.Item{ID: id, Count: count}}
+	}
+	const (
+		backpack = 2854
+		gold     = 3031
+		sword    = 3264
+		shield   = 3412
+	)
+	return []data.Operation{
+		data.InventoryItemSet{Slot: data.SlotLeft, Item: data.Item{ID: sword}},
+		data.ContainerOpen{ContainerID: 0, ItemID: backpack, Name: "backpack", Items: []data.Thing{item(shield, 0)}},
+
+		// The sword for 100 gold: the sword leaves the hand and the gold shows up in the backpack.
+		data.TradeOwn{TimeOffset: s(10), Name: "Hunter", Items: []data.Thing{item(sword, 0)}},
+		data.TradeCounter{TimeOffset: s(12), Name: "Buyer", Items: []data.Thing{item(gold, 100)}},
+		data.InventoryItemClear{TimeOffset: s(15), Slot: data.SlotLeft},
+		data.ContainerItemAdd{TimeOffset: s(15), ContainerID: 0, Thing: item(gold, 100)},
+		data.TradeClose{TimeOffset: s(15)},
+
+		// The shield, cancelled by the partner.
+		data.TradeOwn{TimeOffset: s(20), Name: "Hunter", Items: []data.Thing{item(shield, 0)}},
+		data.TradeCounter{TimeOffset: s(21), Name: "Scammer", Items: []data.Thing{item(gold, 1)}},
+		data.TradeClose{TimeOffset: s(22)},
+		data.Message{TimeOffset: s(22), Type: data.MessageStatusSmall, Text: "Trade cancelled."},
+
+		// The shield again, with unrelated item changes long after closing.
+		data.TradeCounter{TimeOffset: s(30), Name: "Scammer", Items: []data.Thing{item(backpack, 0), item(gold, 50)}},
+		data.TradeOwn{TimeOffset: s(31), Name: "Hunter", Items: []data.Thing{item(shield, 0)}},
+		data.TradeClose{TimeOffset: s(32)},
+		data.ContainerItemRemove{TimeOffset: s(40), ContainerID: 0, Slot: 1},
+	}
+}
+
+func TestTradeTracker(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	tr := NewTradeTracker(nil)
+	for _, op := range tradeOps() {
+		tr.Add(op)
+	}
+
+	want := TradeReport{Sessions: []TradeSession{
+		{
+			Partner:    "Buyer",
+			OwnItems:   []data.Item{{ID: 3264}},
+			TheirItems: []data.Item{{ID: 3031, Count: 100}},
+			Accepted:   true,
+			Start:      s(10),
+			End:        s(15),
+		},
+		{
+			Partner:    "Scammer",
+			OwnItems:   []data.Item{{ID: 3412}},
+			TheirItems: []data.Item{{ID: 3031, Count: 1}},
+			Start:      s(20),
+			End:        s(22),
+		},
+		{
+			Partner:    "Scammer",
+			OwnItems:   []data.Item{{ID: 3412}},
+			TheirItems: []data.Item{{ID: 2854}, {ID: 3031, Count: 50}},
+			Start:      s(30),
+			End:        s(32),
+		},
+	}}
+	if diff := cmp.Diff(want, tr.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestTradeTracker_FullContainer(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	item := func(id uint16) data.Thing { return data.Thing{HasItem: true, Item: data.Item{ID: id}} }
+	tr := NewTradeTracker(nil)
+	for _, op := range []data.Operation{
+		data.ContainerOpen{ContainerID: 0, ItemID: 2853, Name: "bag", Volume: 1, Items: []data.Thing{item(3264)}},
+		// The bag is full, so the sword drops out of view.
+		data.ContainerItemAdd{TimeOffset: s(1), ContainerID: 0, Thing: item(3412)},
+		data.TradeOwn{TimeOffset: s(10), Name: "Hunter", Items: []data.Thing{item(3264)}},
+		data.TradeClose{TimeOffset: s(12)},
+		// Points past the single slot, so it can't be the sword leaving.
+		data.ContainerItemRemove{TimeOffset: s(12), ContainerID: 0, Slot: 1},
+	} {
+		tr.Add(op)
+	}
+
+	want := TradeReport{Sessions: []TradeSession{
+		{OwnItems: []data.Item{{ID: 3264}}, Start: s(10), End: s(12)},
+	}}
+	if diff := cmp.Diff(want, tr.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestTradeTracker_StackMerge(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	item := func(id uint16, count byte) data.Thing {
+		return data.Thing{HasItem: true, Item: data.Item{ID: id, Count: count}}
+	}
+	tr := NewTradeTracker(nil)
+	for _, op := range []data.Operation{
+		data.ContainerOpen{ContainerID: 0, ItemID: 2854, Name: "backpack", Items: []data.Thing{item(3031, 20)}},
+		data.InventoryItemSet{Slot: data.SlotAmmo, Item: data.Item{ID: 3447, Count: 50}},
+
+		// A sword from another container for 30 gold, which merges with the stack.
+		data.TradeOwn{TimeOffset: s(10), Name: "Hunter", Items: []data.Thing{item(3264, 0)}},
+		data.TradeCounter{TimeOffset: s(11), Name: "Buyer", Items: []data.Thing{item(3031, 30)}},
+		data.TradeClose{TimeOffset: s(12)},
+		data.ContainerItemUpdate{TimeOffset: s(12), ContainerID: 0, Slot: 0, Thing: item(3031, 50)},
+
+		// 10 arrows from the ammo slot for a shield kept elsewhere.
+		data.TradeOwn{TimeOffset: s(20), Name: "Hunter", Items: []data.Thing{item(3447, 10)}},
+		data.TradeCounter{TimeOffset: s(21), Name: "Archer", Items: []data.Thing{item(3412, 0)}},
+		data.TradeClose{TimeOffset: s(22)},
+		data.InventoryItemSet{TimeOffset: s(22), Slot: data.SlotAmmo, Item: data.Item{ID: 3447, Count: 40}},
+	} {
+		tr.Add(op)
+	}
+
+	want := TradeReport{Sessions: []TradeSession{
+		{
+			Partner:    "Buyer",
+			OwnItems:   []data.Item{{ID: 3264}},
+			TheirItems: []data.Item{{ID: 3031, Count: 30}},
+			Accepted:   true,
+			Start:      s(10),
+			End:        s(12),
+		},
+		{
+			Partner:    "Archer",
+			OwnItems:   []data.Item{{ID: 3447, Count: 10}},
+			TheirItems: []data.Item{{ID: 3412}},
+			Accepted:   true,
+			Start:      s(20),
+			End:        s(22),
+		},
+	}}
+	if diff := cmp.Diff(want, tr.Report()); diff != "" {
+		t.Errorf("Report() diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestTradeLedger(t *testing.T) {
+	l := NewTradeLedger(nil)
+	for _, path := range []string{"b.cam", "a.cam"} {
+		for _, op := range append(tradeOps()[:7], data.CamMetadata{PlayerName: "Hunter"}) {
+			if err := l.Add(path, op); err != nil {
+				t.Fatalf("Add() error: %v", err)
+			}
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := WriteLedgerCSV(buf, l.Report()); err != nil {
+		t.Fatalf("WriteLedgerCSV() error: %v", err)
+	}
+	want := `recording,player,partner,start,end,accepted,own items,their items
+a.cam,Hunter,Buyer,10s,15s,true,3264,3031x100
+b.cam,Hunter,Buyer,10s,15s,true,3264,3031x100
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("WriteLedgerCSV() diff; -want +got:\n%v", diff)
+	}
+}
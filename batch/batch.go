@@ -0,0 +1,154 @@
+// Package batch parses many recordings concurrently.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+// DatResolver returns item metadata for the recording at path.
+// Returned *dat.File instances are treated as immutable and may be shared between files.
+type DatResolver func(path string) (*dat.File, error)
+
+// StaticDat returns a DatResolver that uses f for every recording.
+func StaticDat(f *dat.File) DatResolver {
+	return func(string) (*dat.File, error) { return f, nil }
+}
+
+// RegistryDat returns a DatResolver that picks a .dat file from the registry for every recording
+// with cam.DetectDat.
+func RegistryDat(reg *dat.Registry) DatResolver {
+	return func(path string) (*dat.File, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		_, datFile, err := cam.DetectDat(f, reg)
+		return datFile, err
+	}
+}
+
+// FileError records a failure to parse a single recording.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Result summarizes a ParseAll run.
+type Result struct {
+	// Stats holds parsing statistics merged across recordings parsed without errors.
+	Stats *cam.ParseStats
+
+	// Parsed is the number of recordings parsed without errors.
+	Parsed int
+
+	// Errors holds per-file errors, in no particular order.
+	Errors []*FileError
+}
+
+// ParseAll parses recordings at paths using up to workers goroutines and calls fn for every operation.
+//
+// fn is called concurrently for different recordings, but sequentially and in order within a single recording.
+// A path listed more than once is parsed once.
+// An error from fn stops parsing of that recording only; it's reported in Result.Errors like any other per-file error.
+//
+// The returned error is non-nil only if ctx was cancelled before all recordings were processed.
+func ParseAll(ctx context.Context, paths []string, resolve DatResolver, workers int, fn func(path string, op data.Operation) error) (*Result, error) {
+	if resolve == nil {
+		return nil, errors.New("batch: DatResolver is required")
+	}
+	workers = max(1, min(workers, len(paths)))
+
+	jobs := make(chan string)
+	go func() {
+		defer close(jobs)
+		seen := map[string]bool{}
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	res := &Result{Stats: cam.NewParseStats()}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for path := range jobs {
+				// Partial statistics of failed files would skew the totals, so each file gets its own.
+				stats := cam.NewParseStats()
+				err := parseFile(ctx, path, resolve, stats, fn)
+				if err != nil && ctx.Err() != nil {
+					// Cancelled files are neither parsed nor failed.
+					continue
+				}
+
+				mu.Lock()
+				if err != nil {
+					res.Errors = append(res.Errors, &FileError{Path: path, Err: err})
+				} else {
+					res.Parsed++
+					res.Stats.Merge(stats)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	return res, ctx.Err()
+}
+
+func parseFile(ctx context.Context, path string, resolve DatResolver, stats *cam.ParseStats, fn func(string, data.Operation) error) error {
+	datFile, err := resolve(path)
+	if err != nil {
+		return fmt.Errorf("resolving .dat: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for op, err := range cam.Parse(f, &cam.ParseOpts{DATFile: datFile, Stats: stats}) {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if fn == nil {
+			continue
+		}
+		if err := fn(path, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+const testdata = "../cam/testdata"
+
+func TestParseAll(t *testing.T) {
+	resolve := testResolver(t)
+	paths := []string{
+		filepath.Join(testdata, "tibiantis.cam"),
+		filepath.Join(testdata, "relic.cam"),
+		filepath.Join(testdata, "tibiantis.cam"),
+		filepath.Join(testdata, "missing.cam"),
+	}
+
+	var mu sync.Mutex
+	players := map[string]int{}
+	res, err := batch.ParseAll(context.Background(), paths, resolve, 3, func(path string, op data.Operation) error {
+		if m, ok := op.(data.CamMetadata); ok {
+			mu.Lock()
+			players[m.PlayerName]++
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseAll() error: %v", err)
+	}
+
+	// The duplicate path is parsed once.
+	if got, want := res.Parsed, 2; got != want {
+		t.Errorf("Result.Parsed = %d, want %d", got, want)
+	}
+	if diff := cmp.Diff(map[string]int{"Shy Teddy": 1, "Golden": 1}, players); diff != "" {
+		t.Errorf("CamMetadata.PlayerName counts diff; -want +got:\n%v", diff)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Path != paths[3] || !errors.Is(res.Errors[0], os.ErrNotExist) {
+		t.Errorf("Result.Errors = %v, want a single os.ErrNotExist for %q", res.Errors, paths[3])
+	}
+
+	want := cam.NewParseStats()
+	for _, path := range paths[:2] {
+		want.Merge(parseStats(t, path, resolve))
+	}
+	for op, n := range want.Count {
+		if got := res.Stats.Count[op]; got != n {
+			t.Errorf("Result.Stats.Count[%s] = %d, want %d", data.OpName[op], got, n)
+		}
+	}
+}
+
+func TestParseAll_RegistryDat(t *testing.T) {
+	reg := dat.NewRegistry()
+	reg.Add("tibiantis", readDat(t, filepath.Join(testdata, "Tibiantis.dat")))
+	reg.Add("relic", readDat(t, filepath.Join(testdata, "TibiaRelic.dat")))
+	paths := []string{
+		filepath.Join(testdata, "tibiantis.cam"),
+		filepath.Join(testdata, "relic.cam"),
+	}
+
+	res, err := batch.ParseAll(context.Background(), paths, batch.RegistryDat(reg), 2, nil)
+	if err != nil {
+		t.Fatalf("ParseAll() error: %v", err)
+	}
+	if got, want := res.Parsed, 2; got != want {
+		t.Errorf("Result.Parsed = %d, want %d; errors: %v", got, want, res.Errors)
+	}
+}
+
+func TestParseAll_CallbackError(t *testing.T) {
+	paths := []string{
+		filepath.Join(testdata, "tibiantis.cam"),
+		filepath.Join(testdata, "relic.cam"),
+	}
+	errStop := errors.New("stop")
+
+	res, err := batch.ParseAll(context.Background(), paths, testResolver(t), 2, func(path string, op data.Operation) error {
+		if strings.HasSuffix(path, "relic.cam") {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseAll() error: %v", err)
+	}
+	if got, want := res.Parsed, 1; got != want {
+		t.Errorf("Result.Parsed = %d, want %d", got, want)
+	}
+	if len(res.Errors) != 1 || !errors.Is(res.Errors[0], errStop) {
+		t.Errorf("Result.Errors = %v, want a single %v", res.Errors, errStop)
+	}
+
+	// The failed recording doesn't count towards the statistics.
+	want := parseStats(t, paths[0], testResolver(t))
+	if diff := cmp.Diff(want.Count, res.Stats.Count); diff != "" {
+		t.Errorf("Result.Stats.Count diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestParseAll_Cancel(t *testing.T) {
+	var paths []string
+	for range 20 {
+		paths = append(paths, filepath.Join(testdata, "tibiantis.cam"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res, err := batch.ParseAll(ctx, paths, testResolver(t), 2, func(path string, op data.Operation) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ParseAll() error = %v, want %v", err, context.Canceled)
+	}
+	if res.Parsed+len(res.Errors) >= len(paths) {
+		t.Errorf("ParseAll() processed %d files after cancellation, want fewer than %d", res.Parsed+len(res.Errors), len(paths))
+	}
+}
+
+func testResolver(t *testing.T) batch.DatResolver {
+	t.Helper()
+
+	tibiantis := readDat(t, filepath.Join(testdata, "Tibiantis.dat"))
+	relic := readDat(t, filepath.Join(testdata, "TibiaRelic.dat"))
+	return func(path string) (*dat.File, error) {
+		if strings.Contains(path, "relic") {
+			return relic, nil
+		}
+		return tibiantis, nil
+	}
+}
+
+func readDat(t *testing.T, path string) *dat.File {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q) error: %v", path, err)
+	}
+	defer f.Close()
+
+	file, err := dat.Read(f)
+	if err != nil {
+		t.Fatalf("Read(%q) error: %v", path, err)
+	}
+	return file
+}
+
+func parseStats(t *testing.T, path string, resolve batch.DatResolver) *cam.ParseStats {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q) error: %v", path, err)
+	}
+	defer f.Close()
+
+	datFile, _ := resolve(path)
+	stats := cam.NewParseStats()
+	for _, err := range cam.Parse(f, &cam.ParseOpts{DATFile: datFile, Stats: stats}) {
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", path, err)
+		}
+	}
+	return stats
+}
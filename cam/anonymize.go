@@ -0,0 +1,254 @@
+package cam
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// AnonymizeOpts controls the behavior of Anonymize.
+type AnonymizeOpts struct {
+	// DATFile holds item metadata from a Tibia client .dat file.
+	DATFile *dat.File
+
+	// Format of both the input and the output. Defaults to FormatCAM.
+	Format Format
+
+	// Replaces the server name in the "Your last visit" message. If empty, the server name is kept.
+	ServerName string
+
+	// Replaces the time in the "Your last visit" message. Defaults to 01. Jan 2000 00:00:00 CET.
+	LastVisit time.Time
+
+	// If set, Anonymize stores the original name of each pseudonym.
+	Pseudonyms map[string]string
+}
+
+// Anonymize copies the recording from r to w, replacing player names with consistent pseudonyms: "Player 1"
+// for the first player seen (usually the recording player), "Player 2" for the next, and so on.
+//
+// Players are collected from creatures on the map, VIP entries, trade partners, private channels, rule
+// violation reports, and speakers on channels and in private messages. Their names are then replaced
+// wherever a string holds them as a whole word in any case, including inside chat texts and server messages.
+// Monsters and NPCs keep their names: they are the same for everyone playing on the server and identify no one,
+// while telling who the player hunted or traded with keeps the recording useful. The "Your last visit" message
+// gets the server name and time from opts.
+//
+// Packets are re-encoded with updated lengths; their timing is preserved.
+//
+// r is an io.ReadSeeker because Anonymize reads it twice: once to collect player names, and again from the start
+// to rewrite them, as a name may be seen only after it was first mentioned.
+func Anonymize(w io.Writer, r io.ReadSeeker, opts *AnonymizeOpts) error {
+	if opts == nil || opts.DATFile == nil {
+		return errMissingDat
+	}
+
+	a := &anonymizer{opts: *opts, pseudonyms: map[string]string{}}
+	if a.opts.LastVisit.IsZero() {
+		a.opts.LastVisit = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	}
+	if err := a.collect(r); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return Convert(a.opts.Format, w, a.rewrite(r))
+}
+
+type anonymizer struct {
+	opts       AnonymizeOpts
+	pseudonyms map[string]string // By lowercase original name.
+	names      *regexp.Regexp
+}
+
+// collect finds player names in the recording and assigns pseudonyms in order of appearance.
+func (a *anonymizer) collect(r io.ReadSeeker) error {
+	var names []string
+	add := func(name string) {
+		// Names are unique regardless of case.
+		key := strings.ToLower(name)
+		if name == "" || a.pseudonyms[key] != "" {
+			return
+		}
+		names = append(names, name)
+		a.pseudonyms[key] = "Player " + strconv.Itoa(len(names))
+		if a.opts.Pseudonyms != nil {
+			a.opts.Pseudonyms[a.pseudonyms[key]] = name
+		}
+	}
+	for op, err := range parseFormat(a.opts.Format, r, a.opts.DATFile) {
+		if err != nil {
+			return err
+		}
+		for _, tile := range data.TilesOf(op) {
+			for _, t := range tile.Things {
+				if t.HasCreature && t.Creature.IsPlayer() {
+					add(t.Creature.Name)
+				}
+			}
+		}
+		switch op := op.(type) {
+		case data.CreatureMessage:
+			// Monsters only speak in the monster say and yell types, NPCs only locally.
+			if op.Type.HasChannel() || !op.Type.HasLocation() && op.Type != data.SpeakMonsterSay && op.Type != data.SpeakMonsterYell {
+				add(op.Name)
+			}
+		case data.VIPState:
+			add(op.Name)
+		case data.TradeOwn:
+			add(op.Name)
+		case data.TradeCounter:
+			add(op.Name)
+		case data.PrivateChannelOpen:
+			add(op.Name)
+		case data.RuleViolationsRemove:
+			add(op.Name)
+		case data.RuleViolationCancel:
+			add(op.Name)
+		case data.PromptTextUpdate:
+			add(op.Author)
+		}
+	}
+
+	a.setNames(names)
+	return nil
+}
+
+// setNames builds the pattern matching names. Longer names come first, so that "Sam Smith" is not matched
+// as "Sam". Word boundaries are checked by replace rather than with \b, which needs a word character on
+// one side and so never matches next to names ending with e.g. a dot.
+func (a *anonymizer) setNames(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	names = slices.Clone(names)
+	slices.SortFunc(names, func(a, b string) int { return len(b) - len(a) })
+	for i, n := range names {
+		names[i] = regexp.QuoteMeta(n)
+	}
+	a.names = regexp.MustCompile(`(?i)(` + strings.Join(names, "|") + `)(?:$|[^\pL\pN_])`)
+}
+
+// parseFormat is ParseStream for any Format, without the trailing CamMetadata.
+func parseFormat(f Format, r io.ReadSeeker, datFile *dat.File) iter.Seq2[data.Operation, error] {
+	return func(yield func(data.Operation, error) bool) {
+		state := &parseState{msg: message{dat: datFile}}
+		opts := &ParseOpts{DATFile: datFile}
+		for packet, err := range ReadFormat(f, r, &ReadOpts{ReuseBuffers: true}) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			ops, err := parsePacket(state, packet.Data, packet.TimeOffset, opts)
+			if err != nil {
+				yield(nil, fmt.Errorf("at file offset %d: %w", packet.FileOffset, err))
+				return
+			}
+			for _, op := range ops {
+				if !yield(op, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rewrite returns the packets of r with strings replaced.
+func (a *anonymizer) rewrite(r io.ReadSeeker) iter.Seq2[data.RawPacket, error] {
+	return func(yield func(data.RawPacket, error) bool) {
+		state := &parseState{msg: message{dat: a.opts.DATFile, recordSpans: true}}
+		opts := &ParseOpts{DATFile: a.opts.DATFile}
+		for packet, err := range ReadFormat(a.opts.Format, r, &ReadOpts{ReuseBuffers: true}) {
+			if err != nil {
+				yield(packet, err)
+				return
+			}
+			state.msg.spans = state.msg.spans[:0]
+			if _, err := parsePacket(state, packet.Data, packet.TimeOffset, opts); err != nil {
+				yield(packet, fmt.Errorf("at file offset %d: %w", packet.FileOffset, err))
+				return
+			}
+			packet.Data, err = a.replace(packet.Data, state.msg.spans)
+			if err != nil {
+				yield(packet, fmt.Errorf("at file offset %d: %w", packet.FileOffset, err))
+				return
+			}
+			if !yield(packet, nil) {
+				return
+			}
+		}
+	}
+}
+
+// replace returns a copy of buf with the strings in spans anonymized.
+func (a *anonymizer) replace(buf []byte, spans []stringSpan) ([]byte, error) {
+	out := make([]byte, 0, len(buf))
+	pos := 0
+	for _, s := range spans {
+		str := a.anonymize(s.value)
+		if str == s.value {
+			continue
+		}
+		enc, err := charmap.Windows1252.NewEncoder().String(str)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %q: %w", str, err)
+		}
+		out = append(out, buf[pos:s.start]...)
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(enc)))
+		out = append(out, enc...)
+		pos = s.end
+	}
+	return append(out, buf[pos:]...), nil
+}
+
+func (a *anonymizer) anonymize(s string) string {
+	if server, _, ok := parseLastVisitMessage(s); ok {
+		if a.opts.ServerName != "" {
+			server = a.opts.ServerName
+		}
+		return lastVisitPrefix + server + ": " + a.opts.LastVisit.Format("02. Jan 2006 15:04:05 MST") + "."
+	}
+	if a.names == nil {
+		return s
+	}
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(s); {
+		m := a.names.FindStringSubmatchIndex(s[i:])
+		if m == nil {
+			break
+		}
+		start, end := i+m[2], i+m[3]
+		if r, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isWordRune(r) {
+			// Part of a longer word; try again from its next character.
+			_, n := utf8.DecodeRuneInString(s[start:])
+			i = start + n
+			continue
+		}
+		b.WriteString(s[pos:start])
+		b.WriteString(a.pseudonyms[strings.ToLower(s[start:end])])
+		pos, i = end, end
+	}
+	if pos == 0 {
+		return s
+	}
+	b.WriteString(s[pos:])
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
@@ -0,0 +1,165 @@
+package cam
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+func TestAnonymize(t *testing.T) {
+	for _, fx := range camFixtures() {
+		t.Run(fx.name, func(t *testing.T) {
+			pseudonyms := map[string]string{}
+			w := bytes.NewBuffer(nil)
+			if err := Anonymize(w, bytes.NewReader(fx.cam), &AnonymizeOpts{
+				DATFile:    fx.dat,
+				ServerName: "Server",
+				Pseudonyms: pseudonyms,
+			}); err != nil {
+				t.Fatalf("Anonymize() error: %v", err)
+			}
+			if len(pseudonyms) == 0 {
+				t.Fatalf("Anonymize() found no players")
+			}
+
+			var want, got []data.Operation
+			for op, err := range Parse(bytes.NewReader(fx.cam), &ParseOpts{DATFile: fx.dat}) {
+				if err != nil {
+					t.Fatalf("Parse(original) error: %v", err)
+				}
+				want = append(want, op)
+			}
+			var text strings.Builder
+			for op, err := range Parse(bytes.NewReader(w.Bytes()), &ParseOpts{DATFile: fx.dat}) {
+				if err != nil {
+					t.Fatalf("Parse(anonymized) error: %v", err)
+				}
+				got = append(got, op)
+				fmt.Fprintf(&text, "%+v\n", op)
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("Parse(anonymized) returned %d operations, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if data.TimeOffsetOf(got[i]) != data.TimeOffsetOf(want[i]) || fmt.Sprintf("%T", got[i]) != fmt.Sprintf("%T", want[i]) {
+					t.Fatalf("Operation %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+			checkAnonymized(t, want, got, pseudonyms)
+			for pseudonym, name := range pseudonyms {
+				if strings.Contains(text.String(), `"`+name+`"`) {
+					t.Errorf("Anonymized recording still contains %q (%s)", name, pseudonym)
+				}
+			}
+
+			meta := got[len(got)-1].(data.CamMetadata)
+			wantMeta := data.CamMetadata{
+				Duration:   want[len(want)-1].(data.CamMetadata).Duration,
+				PlayerName: "Player 1",
+				ServerName: "Server",
+				LastVisit:  time.Date(2000, time.January, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600)),
+			}
+			if meta.PlayerName != wantMeta.PlayerName || meta.ServerName != wantMeta.ServerName ||
+				!meta.LastVisit.Equal(wantMeta.LastVisit) || meta.Duration != wantMeta.Duration {
+				t.Errorf("CamMetadata = %+v, want %+v", meta, wantMeta)
+			}
+		})
+	}
+}
+
+// checkAnonymized compares operations of an anonymized recording with the original ones: player names in creatures,
+// speakers, VIP lists and messages must be replaced by their pseudonyms, while monsters and NPCs keep their names.
+func checkAnonymized(t *testing.T, want, got []data.Operation, pseudonyms map[string]string) {
+	t.Helper()
+
+	byName := map[string]string{}
+	var words []string
+	for pseudonym, name := range pseudonyms {
+		byName[strings.ToLower(name)] = pseudonym
+		words = append(words, regexp.QuoteMeta(name))
+	}
+	names := regexp.MustCompile(`(?i)(^|[^\pL\pN_])(` + strings.Join(words, "|") + `)($|[^\pL\pN_])`)
+
+	var players, others, speakers, vips, messages int
+	checkName := func(i int, what, orig, name string, player bool) {
+		wantName := orig
+		if player {
+			wantName = byName[strings.ToLower(orig)]
+		}
+		if name != wantName {
+			t.Errorf("Operation %d: %s %q anonymized to %q, want %q", i, what, orig, name, wantName)
+		}
+	}
+	for i := range want {
+		wantTiles, gotTiles := data.TilesOf(want[i]), data.TilesOf(got[i])
+		for j := range min(len(wantTiles), len(gotTiles)) {
+			for k := range min(len(wantTiles[j].Things), len(gotTiles[j].Things)) {
+				w, g := wantTiles[j].Things[k], gotTiles[j].Things[k]
+				if !w.HasCreature || w.Creature.Name == "" {
+					continue
+				}
+				if w.Creature.IsPlayer() {
+					players++
+				} else {
+					others++
+				}
+				checkName(i, "creature", w.Creature.Name, g.Creature.Name, w.Creature.IsPlayer())
+			}
+		}
+
+		switch w := want[i].(type) {
+		case data.CreatureMessage:
+			if w.Name == "" {
+				continue
+			}
+			_, player := byName[strings.ToLower(w.Name)]
+			if player {
+				speakers++
+			}
+			checkName(i, "speaker", w.Name, got[i].(data.CreatureMessage).Name, player)
+		case data.VIPState:
+			vips++
+			checkName(i, "VIP", w.Name, got[i].(data.VIPState).Name, true)
+		case data.Message:
+			g := got[i].(data.Message)
+			if g.Text != w.Text {
+				messages++
+			}
+			if m := names.FindStringSubmatch(g.Text); m != nil {
+				t.Errorf("Operation %d: message %q still holds player name %q", i, g.Text, m[2])
+			}
+		}
+	}
+	if players == 0 || others == 0 || speakers == 0 || vips == 0 || messages == 0 {
+		t.Errorf("Checked %d players, %d monsters and NPCs, %d speakers, %d VIPs and %d messages, want some of each",
+			players, others, speakers, vips, messages)
+	}
+}
+
+func TestAnonymizer_Names(t *testing.T) {
+	a := &anonymizer{pseudonyms: map[string]string{"sam": "Player 1", "sam smith": "Player 2", "dr. who.": "Player 3"}}
+	a.setNames([]string{"Sam", "Sam Smith", "Dr. Who."})
+
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"Sam: hi", "Player 1: hi"},
+		{"hi sam, hi SAM SMITH", "hi Player 1, hi Player 2"},
+		{"Sam Smithers", "Player 1 Smithers"},
+		{"Samantha and Sam_ and Sams", "Samantha and Sam_ and Sams"},
+		{"Ask Dr. Who.", "Ask Player 3"},
+		{"Ask Dr. Who.x", "Ask Dr. Who.x"},
+		{"ÜSam", "ÜSam"},
+		{"Sam,Sam", "Player 1,Player 1"},
+	} {
+		if got := a.anonymize(tt.in); got != tt.want {
+			t.Errorf("anonymize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
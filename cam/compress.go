@@ -0,0 +1,136 @@
+package cam
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the compression wrapped around a recording file.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+var (
+	gzipMagic = []byte{0x1F, 0x8B}
+	zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+)
+
+var compressionExt = map[Compression]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// CompressionForPath guesses the compression from the file extension, e.g. "x.cam.gz".
+func CompressionForPath(path string) Compression {
+	ext := strings.ToLower(filepath.Ext(path))
+	for c, e := range compressionExt {
+		if e == ext {
+			return c
+		}
+	}
+	return CompressionNone
+}
+
+// Compress wraps w so that everything written to it gets compressed.
+// The returned io.WriteCloser must be closed to flush the compressed stream; it does not close w.
+func Compress(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", c)
+	}
+}
+
+// Decompress detects gzip or zstd compression by its magic number and returns a seekable view of the decompressed
+// recording. Uncompressed input is returned as is, rewound to the start.
+//
+// Compressed recordings are fully decompressed into memory.
+func Decompress(r io.ReadSeeker) (io.ReadSeeker, error) {
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(r, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	c := detectCompression(magic[:n])
+	if c == CompressionNone {
+		return r, nil
+	}
+	zr, err := newDecompressor(r, c)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	buf, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// decompressStream is the non-seeking counterpart of Decompress.
+// It returns a nil io.ReadCloser if br is not compressed.
+func decompressStream(br *bufio.Reader) (io.ReadCloser, error) {
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	c := detectCompression(magic)
+	if c == CompressionNone {
+		return nil, nil
+	}
+	return newDecompressor(br, c)
+}
+
+func detectCompression(magic []byte) Compression {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+func newDecompressor(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		zd, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zd.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", c)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
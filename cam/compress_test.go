@@ -0,0 +1,60 @@
+package cam
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRead_Compressed(t *testing.T) {
+	want := readPackets(t, FormatCAM, relicCam)
+
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		for _, format := range []Format{FormatCAM, FormatREC} {
+			t.Run(format.String()+compressionExt[c], func(t *testing.T) {
+				buf := bytes.NewBuffer(nil)
+				cw, err := Compress(buf, c)
+				if err != nil {
+					t.Fatalf("Compress() error: %v", err)
+				}
+				if err := Convert(format, cw, Read(bytes.NewReader(relicCam), nil)); err != nil {
+					t.Fatalf("Convert() error: %v", err)
+				}
+				if err := cw.Close(); err != nil {
+					t.Fatalf("Close() error: %v", err)
+				}
+				if buf.Len() >= len(relicCam) {
+					t.Errorf("Compressed size = %d, want less than %d", buf.Len(), len(relicCam))
+				}
+
+				got := readPackets(t, format, buf.Bytes())
+				if len(got) != len(want) {
+					t.Fatalf("ReadFormat() returned %d packets, want %d", len(got), len(want))
+				}
+				for i := range want {
+					if got[i].TimeOffset != want[i].TimeOffset || !bytes.Equal(got[i].Data, want[i].Data) {
+						t.Fatalf("Packet %d differs", i)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCompressionForPath(t *testing.T) {
+	for _, tt := range []struct {
+		path        string
+		compression Compression
+		format      Format
+	}{
+		{path: "a.cam", compression: CompressionNone, format: FormatCAM},
+		{path: "a.cam.gz", compression: CompressionGzip, format: FormatCAM},
+		{path: "a.rec.ZST", compression: CompressionZstd, format: FormatREC},
+	} {
+		if got, want := CompressionForPath(tt.path), tt.compression; got != want {
+			t.Errorf("CompressionForPath(%q) = %v, want %v", tt.path, got, want)
+		}
+		if got, err := FormatForPath(tt.path); err != nil || got != tt.format {
+			t.Errorf("FormatForPath(%q) = %v, %v, want %v, nil", tt.path, got, err, tt.format)
+		}
+	}
+}
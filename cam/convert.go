@@ -0,0 +1,162 @@
+package cam
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// Convert writes data.RawPackets from src into w, encoded in the dst format.
+//
+// Packet timing is preserved with millisecond precision. CAM output gets a zeroed checksum (see Merge).
+// REC and TMV output stores one length-prefixed packet per frame; TMV output is gzip-compressed.
+func Convert(dst Format, w io.Writer, src iter.Seq2[data.RawPacket, error]) error {
+	var pw packetWriter
+	switch dst {
+	case FormatCAM:
+		pw = &camWriter{w: w}
+	case FormatREC:
+		pw = &recWriter{w: w}
+	case FormatTMV:
+		pw = &tmvWriter{w: w}
+	default:
+		return fmt.Errorf("unsupported format %v", dst)
+	}
+
+	for packet, err := range src {
+		if err != nil {
+			return err
+		}
+		if err := pw.writePacket(packet); err != nil {
+			return fmt.Errorf("at file offset %d: %w", packet.FileOffset, err)
+		}
+	}
+	return pw.close()
+}
+
+type packetWriter interface {
+	writePacket(p data.RawPacket) error
+	close() error
+}
+
+// camHeader holds the header size (8) followed by a placeholder checksum.
+var camHeader = []byte{8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+type camWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (cw *camWriter) writePacket(p data.RawPacket) error {
+	if !cw.started {
+		cw.started = true
+		if _, err := cw.w.Write(camHeader); err != nil {
+			return err
+		}
+	}
+	if len(p.Data) > math.MaxUint16 {
+		return fmt.Errorf("packet too long for CAM: %d bytes", len(p.Data))
+	}
+
+	var rec [10]byte
+	binary.LittleEndian.PutUint64(rec[0:], uint64(p.TimeOffset.Milliseconds()))
+	binary.LittleEndian.PutUint16(rec[8:], uint16(len(p.Data)))
+	if _, err := cw.w.Write(rec[:]); err != nil {
+		return err
+	}
+	_, err := cw.w.Write(p.Data)
+	return err
+}
+
+func (cw *camWriter) close() error {
+	if !cw.started {
+		_, err := cw.w.Write(camHeader)
+		return err
+	}
+	return nil
+}
+
+// recWriter buffers frames, since the .rec header holds the frame count.
+type recWriter struct {
+	w      io.Writer
+	buf    bytes.Buffer
+	frames uint32
+}
+
+func (rw *recWriter) writePacket(p data.RawPacket) error {
+	if len(p.Data) > math.MaxUint16 {
+		return fmt.Errorf("packet too long for REC: %d bytes", len(p.Data))
+	}
+
+	var frame [10]byte
+	binary.LittleEndian.PutUint32(frame[0:], uint32(len(p.Data)+2))
+	binary.LittleEndian.PutUint32(frame[4:], uint32(p.TimeOffset.Milliseconds()))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(len(p.Data)))
+	rw.buf.Write(frame[:])
+	rw.buf.Write(p.Data)
+	rw.frames++
+	return nil
+}
+
+func (rw *recWriter) close() error {
+	var header [6]byte
+	binary.LittleEndian.PutUint16(header[0:], recVersionU32)
+	binary.LittleEndian.PutUint32(header[2:], rw.frames)
+	if _, err := rw.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := rw.buf.WriteTo(rw.w)
+	return err
+}
+
+// tmvWriter buffers frames, since the .tmv header holds the total duration.
+type tmvWriter struct {
+	w        io.Writer
+	buf      bytes.Buffer
+	lastTick time.Duration
+}
+
+func (tw *tmvWriter) writePacket(p data.RawPacket) error {
+	if len(p.Data)+2 > math.MaxUint16 {
+		return fmt.Errorf("packet too long for TMV: %d bytes", len(p.Data))
+	}
+	if p.TimeOffset < tw.lastTick {
+		return fmt.Errorf("non-monotonic time offset %v after %v", p.TimeOffset, tw.lastTick)
+	}
+
+	delay := (p.TimeOffset - tw.lastTick).Milliseconds()
+
+	var frame [9]byte
+	frame[0] = tmvFramePacket
+	binary.LittleEndian.PutUint32(frame[1:], uint32(delay))
+	binary.LittleEndian.PutUint16(frame[5:], uint16(len(p.Data)+2))
+	binary.LittleEndian.PutUint16(frame[7:], uint16(len(p.Data)))
+	tw.buf.Write(frame[:])
+	tw.buf.Write(p.Data)
+	tw.lastTick += time.Duration(delay) * time.Millisecond
+	return nil
+}
+
+func (tw *tmvWriter) close() error {
+	zw := gzip.NewWriter(tw.w)
+
+	// Client version is not known from the packet stream alone; 0 marks it as unknown.
+	var header [8]byte
+	binary.LittleEndian.PutUint16(header[0:], tmvVersion)
+	binary.LittleEndian.PutUint16(header[2:], 0)
+	binary.LittleEndian.PutUint32(header[4:], uint32(tw.lastTick.Milliseconds()))
+	if _, err := zw.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := tw.buf.WriteTo(zw); err != nil {
+		return err
+	}
+	return zw.Close()
+}
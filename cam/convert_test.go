@@ -0,0 +1,144 @@
+package cam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+func TestConvert(t *testing.T) {
+	for _, fx := range camFixtures() {
+		for _, format := range []Format{FormatCAM, FormatREC, FormatTMV} {
+			t.Run(fx.name+"/"+format.String(), func(t *testing.T) {
+				want := readPackets(t, FormatCAM, fx.cam)
+
+				w := bytes.NewBuffer(nil)
+				if err := Convert(format, w, Read(bytes.NewReader(fx.cam), nil)); err != nil {
+					t.Fatalf("Convert(%v) error: %v", format, err)
+				}
+				got := readPackets(t, format, w.Bytes())
+
+				if len(got) != len(want) {
+					t.Fatalf("ReadFormat(%v) returned %d packets, want %d", format, len(got), len(want))
+				}
+				for i := range want {
+					if got[i].TimeOffset != want[i].TimeOffset || !bytes.Equal(got[i].Data, want[i].Data) {
+						t.Fatalf("Packet %d = {%d, % x}, want {%d, % x}", i, got[i].TimeOffset, got[i].Data, want[i].TimeOffset, want[i].Data)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConvert_RoundTrip(t *testing.T) {
+	tmv := bytes.NewBuffer(nil)
+	if err := Convert(FormatTMV, tmv, Read(bytes.NewReader(tibiantisCam), nil)); err != nil {
+		t.Fatalf("Convert(tmv) error: %v", err)
+	}
+	rec := bytes.NewBuffer(nil)
+	if err := Convert(FormatREC, rec, ReadFormat(FormatTMV, bytes.NewReader(tmv.Bytes()), nil)); err != nil {
+		t.Fatalf("Convert(rec) error: %v", err)
+	}
+	out := bytes.NewBuffer(nil)
+	if err := Convert(FormatCAM, out, ReadFormat(FormatREC, bytes.NewReader(rec.Bytes()), nil)); err != nil {
+		t.Fatalf("Convert(cam) error: %v", err)
+	}
+
+	want := bytes.NewBuffer(nil)
+	if err := Convert(FormatCAM, want, Read(bytes.NewReader(tibiantisCam), nil)); err != nil {
+		t.Fatalf("Convert(cam) error: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), want.Bytes()) {
+		t.Errorf("Convert(cam) after a tmv -> rec round trip differs from a direct conversion")
+	}
+}
+
+func TestReadFormat_REC(t *testing.T) {
+	// A u32-length .rec with two frames, the second one ticking back by 5 s.
+	frame := func(tick uint32, payload ...byte) []byte {
+		chunk := binary.LittleEndian.AppendUint16(nil, uint16(len(payload)))
+		chunk = append(chunk, payload...)
+		ret := binary.LittleEndian.AppendUint32(nil, uint32(len(chunk)))
+		ret = binary.LittleEndian.AppendUint32(ret, tick)
+		return append(ret, chunk...)
+	}
+	rec := binary.LittleEndian.AppendUint16(nil, recVersionU32)
+	rec = binary.LittleEndian.AppendUint32(rec, 2)
+	rec = append(rec, frame(10000, 0x01)...)
+	rec = append(rec, frame(5000, 0x02)...)
+
+	var regressions []time.Duration
+	var got []time.Duration
+	for p, err := range ReadFormat(FormatREC, bytes.NewReader(rec), &ReadOpts{
+		OnTickRegression: func(p data.RawPacket, prev time.Duration) { regressions = append(regressions, p.TimeOffset) },
+	}) {
+		if err != nil {
+			t.Fatalf("ReadFormat() error: %v", err)
+		}
+		got = append(got, p.TimeOffset)
+	}
+	if want := []time.Duration{0, -5 * time.Second}; !slices.Equal(got, want) {
+		t.Errorf("ReadFormat() offsets = %v, want %v", got, want)
+	}
+	if want := []time.Duration{-5 * time.Second}; !slices.Equal(regressions, want) {
+		t.Errorf("OnTickRegression() offsets = %v, want %v", regressions, want)
+	}
+
+	// A frame claiming 4 GiB must fail before allocating it.
+	huge := binary.LittleEndian.AppendUint16(nil, recVersionU32)
+	huge = binary.LittleEndian.AppendUint32(huge, 1)
+	huge = binary.LittleEndian.AppendUint32(huge, 0xFFFFFFFF)
+	huge = binary.LittleEndian.AppendUint32(huge, 0)
+	var gotErr error
+	for _, err := range ReadFormat(FormatREC, bytes.NewReader(huge), nil) {
+		gotErr = err
+	}
+	if gotErr == nil {
+		t.Errorf("ReadFormat() of an oversized frame returned no error")
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	for _, tt := range []struct {
+		path    string
+		want    Format
+		wantErr bool
+	}{
+		{path: "a/b.cam", want: FormatCAM},
+		{path: "b.REC", want: FormatREC},
+		{path: "c.tmv", want: FormatTMV},
+		{path: "d.txt", wantErr: true},
+	} {
+		got, err := FormatForPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("FormatForPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FormatForPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+type packet struct {
+	TimeOffset int64
+	Data       []byte
+}
+
+func readPackets(t *testing.T, format Format, buf []byte) []packet {
+	t.Helper()
+
+	var ret []packet
+	for p, err := range ReadFormat(format, bytes.NewReader(buf), nil) {
+		if err != nil {
+			t.Fatalf("ReadFormat(%v) error: %v", format, err)
+		}
+		ret = append(ret, packet{TimeOffset: p.TimeOffset.Milliseconds(), Data: p.Data})
+	}
+	return ret
+}
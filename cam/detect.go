@@ -0,0 +1,84 @@
+package cam
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+// detectPackets is the number of packets DetectDat trial-parses.
+const detectPackets = 1000
+
+var (
+	errNoCandidates = errors.New("no .dat candidates registered")
+	errNoPackets    = errors.New("no packets to detect the .dat file with")
+)
+
+// DetectDat picks the .dat file a recording was made with, by trial-parsing its first packets with every
+// candidate from the registry. A candidate matches if every packet parses cleanly and is consumed exactly.
+//
+// If several candidates match, the one registered first wins. A recording without packets is an error.
+// DetectDat consumes r.
+func DetectDat(r io.Reader, registry *dat.Registry) (name string, f *dat.File, err error) {
+	if registry == nil || registry.Len() == 0 {
+		return "", nil, errNoCandidates
+	}
+
+	type candidate struct {
+		name  string
+		opts  *ParseOpts
+		state *parseState
+		err   error
+	}
+	var candidates []*candidate
+	for _, name := range registry.Names() {
+		f, _ := registry.Get(name)
+		candidates = append(candidates, &candidate{
+			name:  name,
+			opts:  &ParseOpts{DATFile: f, TFilter: map[data.OpType]bool{}},
+			state: &parseState{msg: message{dat: f}},
+		})
+	}
+
+	n := 0
+	for packet, err := range ReadStream(r, &ReadOpts{ReuseBuffers: true}) {
+		if err != nil {
+			return "", nil, err
+		}
+
+		remaining := 0
+		for _, c := range candidates {
+			if c.err != nil {
+				continue
+			}
+			if _, err := parsePacket(c.state, packet.Data, packet.TimeOffset, c.opts); err != nil {
+				c.err = fmt.Errorf("at file offset %d: %w", packet.FileOffset, err)
+				continue
+			}
+			remaining++
+		}
+
+		n++
+		if remaining == 0 || n >= detectPackets {
+			break
+		}
+	}
+
+	if n == 0 {
+		return "", nil, errNoPackets
+	}
+
+	var errs []string
+	for _, c := range candidates {
+		if c.err == nil {
+			f, _ := registry.Get(c.name)
+			return c.name, f, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", c.name, c.err))
+	}
+	return "", nil, fmt.Errorf("no .dat candidate parses the recording: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,46 @@
+package cam
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/s5i/tcam/dat"
+)
+
+func TestDetectDat(t *testing.T) {
+	for _, order := range [][]string{{"tibiantis", "relic"}, {"relic", "tibiantis"}} {
+		reg := dat.NewRegistry()
+		for _, name := range order {
+			reg.Add(name, map[string]*dat.File{"tibiantis": tibiantisDAT, "relic": tibiaRelicDAT}[name])
+		}
+
+		for _, fx := range camFixtures() {
+			t.Run(fx.name+"/"+order[0]+"_first", func(t *testing.T) {
+				name, f, err := DetectDat(bytes.NewReader(fx.cam), reg)
+				if err != nil {
+					t.Fatalf("DetectDat() error: %v", err)
+				}
+				if name != fx.name || f != fx.dat {
+					t.Errorf("DetectDat() = %q, want %q", name, fx.name)
+				}
+			})
+		}
+	}
+}
+
+func TestDetectDat_NoMatch(t *testing.T) {
+	reg := dat.NewRegistry()
+	reg.Add("tibiantis", tibiantisDAT)
+
+	if _, _, err := DetectDat(bytes.NewReader(relicCam), reg); err == nil {
+		t.Fatal("DetectDat() error = nil, want error")
+	}
+	if _, _, err := DetectDat(bytes.NewReader(relicCam), dat.NewRegistry()); err == nil {
+		t.Fatal("DetectDat() with an empty registry error = nil, want error")
+	}
+
+	if _, _, err := DetectDat(bytes.NewReader(camHeader), reg); !errors.Is(err, errNoPackets) {
+		t.Fatalf("DetectDat() of an empty recording error = %v, want %v", err, errNoPackets)
+	}
+}
@@ -0,0 +1,278 @@
+package cam
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+// DiffOpts controls the behavior of Diff.
+type DiffOpts struct {
+	// DATFile holds item metadata from a Tibia client .dat file. Both recordings must use it.
+	DATFile *dat.File
+
+	// Shift is added to every TimeOffset of the second recording, e.g. if it started later.
+	Shift time.Duration
+
+	// Operations of the same type at most Tolerance apart may be aligned. Defaults to 1s.
+	Tolerance time.Duration
+
+	// World states are compared every StateInterval. Defaults to 10s; negative disables the comparison.
+	StateInterval time.Duration
+}
+
+// OpDiffKind classifies an OpDiff.
+type OpDiffKind int
+
+const (
+	OpMissing OpDiffKind = iota // Only in the first recording.
+	OpExtra                     // Only in the second recording.
+	OpChanged                   // In both, with different fields.
+)
+
+var opDiffKindName = map[OpDiffKind]string{
+	OpMissing: "missing",
+	OpExtra:   "extra",
+	OpChanged: "changed",
+}
+
+func (k OpDiffKind) String() string {
+	if n, ok := opDiffKindName[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("OpDiffKind(%d)", int(k))
+}
+
+// OpDiff is an operation that doesn't match between recordings.
+type OpDiff struct {
+	Kind OpDiffKind
+	Time time.Duration  // Of A, or of B (shifted) for OpExtra.
+	A, B data.Operation // Nil for OpExtra and OpMissing respectively.
+	Diff string         // For OpChanged: the fields that differ, -A +B.
+}
+
+// StateDiff is a divergence of the reconstructed world states.
+type StateDiff struct {
+	Time time.Duration
+	Text string
+}
+
+// DiffReport lists the differences between two recordings.
+type DiffReport struct {
+	A, B data.CamMetadata
+
+	Matched int // Aligned operations with equal fields.
+	Ops     []OpDiff
+	State   []StateDiff // Only divergences not already present at the previous comparison.
+}
+
+// Equal reports whether no differences were found.
+func (r *DiffReport) Equal() bool {
+	return len(r.Ops) == 0 && len(r.State) == 0
+}
+
+// ignoreTimeOffset excludes TimeOffset from reported differences; alignment already accounts for it.
+var ignoreTimeOffset = gocmp.FilterPath(func(p gocmp.Path) bool {
+	sf, ok := p.Last().(gocmp.StructField)
+	return ok && sf.Name() == "TimeOffset"
+}, gocmp.Ignore())
+
+// Diff compares two recordings of the same session, e.g. made by different players or tools.
+//
+// Operations are aligned by type and time: in order, each operation is paired with the next one of the same
+// type in the other recording if they are at most opts.Tolerance apart. If a pair differs but one side's next
+// operation equals the other side's current one, the unequal operation is reported as missing or extra instead.
+// Paired operations are compared field by field, ignoring TimeOffset.
+//
+// The world states reconstructed from both recordings are compared periodically: player positions and
+// visible creatures with their positions and health.
+func Diff(a, b io.ReadSeeker, opts *DiffOpts) (*DiffReport, error) {
+	if opts == nil || opts.DATFile == nil {
+		return nil, errMissingDat
+	}
+	o := *opts
+	if o.Tolerance <= 0 {
+		o.Tolerance = time.Second
+	}
+	if o.StateInterval == 0 {
+		o.StateInterval = 10 * time.Second
+	}
+
+	r := &DiffReport{}
+	opsA, err := diffOps(a, o.DATFile, 0, &r.A)
+	if err != nil {
+		return nil, fmt.Errorf("first recording: %w", err)
+	}
+	opsB, err := diffOps(b, o.DATFile, o.Shift, &r.B)
+	if err != nil {
+		return nil, fmt.Errorf("second recording: %w", err)
+	}
+
+	byTypeA, byTypeB := map[data.OpType][]timedOp{}, map[data.OpType][]timedOp{}
+	var types []data.OpType
+	for _, op := range opsA {
+		if _, ok := byTypeA[op.typ]; !ok {
+			types = append(types, op.typ)
+		}
+		byTypeA[op.typ] = append(byTypeA[op.typ], op)
+	}
+	for _, op := range opsB {
+		if _, ok := byTypeA[op.typ]; !ok {
+			if _, ok := byTypeB[op.typ]; !ok {
+				types = append(types, op.typ)
+			}
+		}
+		byTypeB[op.typ] = append(byTypeB[op.typ], op)
+	}
+	for _, t := range types {
+		r.alignOps(byTypeA[t], byTypeB[t], o.Tolerance)
+	}
+	slices.SortStableFunc(r.Ops, func(x, y OpDiff) int { return cmp.Compare(x.Time, y.Time) })
+
+	if o.StateInterval > 0 {
+		r.compareStates(opsA, opsB, o.DATFile, o.StateInterval)
+	}
+	return r, nil
+}
+
+// timedOp is an operation with its type and TimeOffset, shifted for the second recording.
+type timedOp struct {
+	op   data.Operation
+	typ  data.OpType
+	time time.Duration
+
+	// zeroTime is op with a zero TimeOffset, for comparisons much faster than gocmp.Equal with options.
+	zeroTime any
+}
+
+func diffOps(r io.ReadSeeker, datFile *dat.File, shift time.Duration, meta *data.CamMetadata) ([]timedOp, error) {
+	var ops []timedOp
+	for op, err := range Parse(r, &ParseOpts{DATFile: datFile}) {
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := op.(data.CamMetadata); ok {
+			*meta = m
+			continue
+		}
+		t, _ := data.TypeOf(op)
+		v := reflect.New(reflect.TypeOf(op)).Elem()
+		v.Set(reflect.ValueOf(op))
+		if f := v.FieldByName("TimeOffset"); f.IsValid() {
+			f.SetInt(0)
+		}
+		ops = append(ops, timedOp{op: op, typ: t, time: data.TimeOffsetOf(op) + shift, zeroTime: v.Interface()})
+	}
+	return ops, nil
+}
+
+func (r *DiffReport) alignOps(a, b []timedOp, tolerance time.Duration) {
+	near := func(x, y timedOp) bool { return max(x.time-y.time, y.time-x.time) <= tolerance }
+	equal := func(x, y timedOp) bool { return reflect.DeepEqual(x.zeroTime, y.zeroTime) }
+	missing := func(x timedOp) { r.Ops = append(r.Ops, OpDiff{Kind: OpMissing, Time: x.time, A: x.op}) }
+	extra := func(y timedOp) { r.Ops = append(r.Ops, OpDiff{Kind: OpExtra, Time: y.time, B: y.op}) }
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		x, y := a[i], b[j]
+		switch {
+		case !near(x, y) && x.time < y.time:
+			missing(x)
+			i++
+		case !near(x, y):
+			extra(y)
+			j++
+		case equal(x, y):
+			r.Matched++
+			i++
+			j++
+		case i+1 < len(a) && near(a[i+1], y) && equal(a[i+1], y):
+			missing(x)
+			i++
+		case j+1 < len(b) && near(x, b[j+1]) && equal(x, b[j+1]):
+			extra(y)
+			j++
+		default:
+			r.Ops = append(r.Ops, OpDiff{Kind: OpChanged, Time: x.time, A: x.op, B: y.op, Diff: gocmp.Diff(x.op, y.op, ignoreTimeOffset)})
+			i++
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		missing(a[i])
+	}
+	for ; j < len(b); j++ {
+		extra(b[j])
+	}
+}
+
+func (r *DiffReport) compareStates(a, b []timedOp, datFile *dat.File, interval time.Duration) {
+	sa, sb := world.New(datFile), world.New(datFile)
+	end := max(lastTime(a), lastTime(b))
+	var prev map[string]bool
+	for t := interval; t < end+interval; t += interval {
+		a = applyUntil(sa, a, t)
+		b = applyUntil(sb, b, t)
+
+		cur := map[string]bool{}
+		for _, text := range stateDiffs(sa, sb) {
+			cur[text] = true
+			if !prev[text] {
+				r.State = append(r.State, StateDiff{Time: t, Text: text})
+			}
+		}
+		prev = cur
+	}
+}
+
+func lastTime(ops []timedOp) time.Duration {
+	if len(ops) == 0 {
+		return 0
+	}
+	return ops[len(ops)-1].time
+}
+
+// applyUntil applies operations up to t and returns the remaining ones.
+func applyUntil(s *world.State, ops []timedOp, t time.Duration) []timedOp {
+	for len(ops) > 0 && ops[0].time <= t {
+		s.Apply(ops[0].op)
+		ops = ops[1:]
+	}
+	return ops
+}
+
+func stateDiffs(a, b *world.State) []string {
+	name := func(c world.Creature) string { return fmt.Sprintf("creature %d %q", c.ID, c.Name) }
+
+	var ret []string
+	if pa, pb := a.PlayerPos(), b.PlayerPos(); pa != pb {
+		ret = append(ret, fmt.Sprintf("player at %v in A, %v in B", pa, pb))
+	}
+
+	ca, cb := a.Creatures(), b.Creatures()
+	for _, c := range ca {
+		d, ok := b.Creature(c.ID)
+		switch {
+		case !ok || !d.Visible:
+			ret = append(ret, fmt.Sprintf("%s visible only in A", name(c)))
+		case c.Location != d.Location:
+			ret = append(ret, fmt.Sprintf("%s at %v in A, %v in B", name(c), c.Location, d.Location))
+		case c.Health != d.Health:
+			ret = append(ret, fmt.Sprintf("%s at %d%% health in A, %d%% in B", name(c), c.Health, d.Health))
+		}
+	}
+	for _, c := range cb {
+		if d, ok := a.Creature(c.ID); !ok || !d.Visible {
+			ret = append(ret, fmt.Sprintf("%s visible only in B", name(c)))
+		}
+	}
+	return ret
+}
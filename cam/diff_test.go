@@ -0,0 +1,90 @@
+package cam
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+)
+
+func TestDiff_Same(t *testing.T) {
+	for _, fx := range camFixtures() {
+		t.Run(fx.name, func(t *testing.T) {
+			r, err := Diff(bytes.NewReader(fx.cam), bytes.NewReader(fx.cam), &DiffOpts{DATFile: fx.dat})
+			if err != nil {
+				t.Fatalf("Diff() error: %v", err)
+			}
+			if !r.Equal() || r.Matched == 0 {
+				t.Errorf("Diff() of a recording with itself = %d matched, %v, %v; want no differences", r.Matched, r.Ops, r.State)
+			}
+		})
+	}
+}
+
+func TestDiff_Edited(t *testing.T) {
+	// Drop the packet with the first CreatureMessage and anonymize the rest.
+	var dropped data.Operation
+	var packets []data.RawPacket
+	state := &parseState{msg: message{dat: tibiantisDAT}}
+	for p, err := range Read(bytes.NewReader(tibiantisCam), nil) {
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		ops, err := parsePacket(state, p.Data, p.TimeOffset, testParseOpts())
+		if err != nil {
+			t.Fatalf("parsePacket() error: %v", err)
+		}
+		if len(ops) == 1 && dropped == nil {
+			if _, ok := ops[0].(data.CreatureMessage); ok {
+				dropped = ops[0]
+				continue
+			}
+		}
+		packets = append(packets, p)
+	}
+	edited := bytes.NewBuffer(nil)
+	if err := Convert(FormatCAM, edited, func(yield func(data.RawPacket, error) bool) {
+		for _, p := range packets {
+			if !yield(p, nil) {
+				return
+			}
+		}
+	}); err != nil {
+		t.Fatalf("Convert() error: %v", err)
+	}
+	anonymized := bytes.NewBuffer(nil)
+	if err := Anonymize(anonymized, bytes.NewReader(edited.Bytes()), &AnonymizeOpts{DATFile: tibiantisDAT}); err != nil {
+		t.Fatalf("Anonymize() error: %v", err)
+	}
+
+	r, err := Diff(bytes.NewReader(tibiantisCam), bytes.NewReader(anonymized.Bytes()), &DiffOpts{DATFile: tibiantisDAT})
+	if err != nil {
+		t.Fatalf("Diff() error: %v", err)
+	}
+
+	var missing, changed int
+	for _, d := range r.Ops {
+		switch d.Kind {
+		case OpMissing:
+			missing++
+			if !cmp.Equal(d.A, dropped) {
+				t.Errorf("Diff() reports %v missing, want %v", d.A, dropped)
+			}
+		case OpExtra:
+			t.Errorf("Diff() reports %v extra, want none", d.B)
+		case OpChanged:
+			changed++
+			if !strings.Contains(d.Diff, "Player ") && !strings.Contains(d.Diff, "01. Jan 2000") {
+				t.Errorf("Diff() reports a change other than anonymization:\n%s", d.Diff)
+			}
+		}
+	}
+	if missing != 1 || changed == 0 {
+		t.Errorf("Diff() = %d missing, %d changed; want 1 missing and some changed", missing, changed)
+	}
+	if len(r.State) != 0 {
+		t.Errorf("Diff().State = %v, want none; creature IDs and positions are unchanged", r.State)
+	}
+}
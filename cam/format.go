@@ -0,0 +1,287 @@
+package cam
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+// Format identifies a recording file format.
+type Format int
+
+const (
+	// FormatCAM is the native .cam format: a sized header followed by
+	// (absolute u64 tick, u16 length, payload) records.
+	FormatCAM Format = iota
+
+	// FormatREC is the unencrypted TibiCAM .rec format: a u16 version, a u32
+	// frame count and (u32 length, absolute u32 tick, chunk) frames.
+	FormatREC
+
+	// FormatTMV is the gzip-compressed TibiaMovie .tmv format: a u16 version,
+	// u16 client version and u32 duration followed by (u8 type, delta u32 tick,
+	// u16 length, chunk) frames.
+	FormatTMV
+)
+
+var formatName = map[Format]string{
+	FormatCAM: "cam",
+	FormatREC: "rec",
+	FormatTMV: "tmv",
+}
+
+func (f Format) String() string {
+	if name, ok := formatName[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatForPath guesses the recording format from the file extension, ignoring compression extensions.
+func FormatForPath(path string) (Format, error) {
+	if c := CompressionForPath(path); c != CompressionNone {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	for f, name := range formatName {
+		if name == ext {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown recording format for %q", path)
+}
+
+// ReadFormat returns an iterator over the provided io.ReadSeeker that returns subsequent data.RawPackets
+// of a recording in the given format. opts may be nil.
+//
+// CAM and REC recordings may be gzip- or zstd-compressed (see Decompress).
+// REC and TMV frames carry chunks of the network stream, where every packet is prefixed with its u16 length.
+// ReadFormat splits them into separate data.RawPackets, matching the CAM layout; with opts.ReuseBuffers,
+// packets of a chunk share its buffer, which is reused for the next one.
+// TMV frames are timed by delays, so their ticks never go back and opts.OnTickRegression is never called.
+func ReadFormat(f Format, r io.ReadSeeker, opts *ReadOpts) iter.Seq2[data.RawPacket, error] {
+	switch f {
+	case FormatCAM:
+		return Read(r, opts)
+	case FormatREC:
+		return readREC(r, opts)
+	case FormatTMV:
+		return readTMV(r, opts)
+	default:
+		return func(yield func(data.RawPacket, error) bool) {
+			yield(data.RawPacket{}, fmt.Errorf("unsupported format %v", f))
+		}
+	}
+}
+
+const (
+	recVersionU16 = 0x0102
+	recVersionU32 = 0x0202
+
+	tmvVersion     = 2
+	tmvFramePacket = 0
+	tmvFrameMarker = 1
+
+	// maxChunkLen bounds the length of a .rec frame, which is read from the file, before allocating it.
+	// Clients receive packets of at most 64 KiB, and recorders write one or a few per frame.
+	maxChunkLen = 1 << 20
+)
+
+var errEncryptedREC = errors.New("encrypted .rec files are not supported")
+
+func readREC(rs io.ReadSeeker, opts *ReadOpts) iter.Seq2[data.RawPacket, error] {
+	return func(yield func(data.RawPacket, error) bool) {
+		reuse := opts != nil && opts.ReuseBuffers
+		var onRegression func(data.RawPacket, time.Duration)
+		if opts != nil {
+			onRegression = opts.OnTickRegression
+		}
+
+		r, err := Decompress(rs)
+		if err != nil {
+			yield(data.RawPacket{}, err)
+			return
+		}
+
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:6]); err != nil {
+			yield(data.RawPacket{}, err)
+			return
+		}
+		version := binary.LittleEndian.Uint16(hdr[0:2])
+		if version != recVersionU16 && version != recVersionU32 {
+			if version > recVersionU32 {
+				yield(data.RawPacket{}, errEncryptedREC)
+				return
+			}
+			yield(data.RawPacket{}, fmt.Errorf("unknown .rec version 0x%04X", version))
+			return
+		}
+		frames := binary.LittleEndian.Uint32(hdr[2:6])
+
+		// Frames hold a u16 or u32 length, depending on the version, and a u32 tick.
+		lenSize := 4
+		if version == recVersionU16 {
+			lenSize = 2
+		}
+		var startTick uint32
+		var prev time.Duration
+		var chunk []byte
+		for i := range frames {
+			if _, err := io.ReadFull(r, hdr[:lenSize+4]); err != nil {
+				yield(data.RawPacket{}, err)
+				return
+			}
+			frameLen := uint32(binary.LittleEndian.Uint16(hdr[0:2]))
+			if lenSize == 4 {
+				frameLen = binary.LittleEndian.Uint32(hdr[0:4])
+			}
+			tick := binary.LittleEndian.Uint32(hdr[lenSize:])
+			if i == 0 {
+				startTick = tick
+			}
+
+			cur, _ := r.Seek(0, io.SeekCurrent)
+			if frameLen > maxChunkLen {
+				yield(data.RawPacket{}, fmt.Errorf("at file offset %d: frame length %d exceeds %d", cur, frameLen, maxChunkLen))
+				return
+			}
+			if !reuse || cap(chunk) < int(frameLen) {
+				chunk = make([]byte, frameLen)
+			}
+			chunk = chunk[:frameLen]
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				yield(data.RawPacket{}, err)
+				return
+			}
+
+			timeOffset := time.Duration(int64(tick)-int64(startTick)) * time.Millisecond
+			yieldPacket := yield
+			if regressed := i > 0 && timeOffset < prev; regressed && onRegression != nil {
+				// Report the first packet of the frame.
+				yieldPacket = func(p data.RawPacket, err error) bool {
+					if regressed && err == nil {
+						onRegression(p, prev)
+						regressed = false
+					}
+					return yield(p, err)
+				}
+			}
+			if !yieldChunk(yieldPacket, chunk, int(cur), timeOffset) {
+				return
+			}
+			prev = timeOffset
+		}
+	}
+}
+
+func readTMV(r io.Reader, opts *ReadOpts) iter.Seq2[data.RawPacket, error] {
+	return func(yield func(data.RawPacket, error) bool) {
+		reuse := opts != nil && opts.ReuseBuffers
+
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			yield(data.RawPacket{}, err)
+			return
+		}
+		defer zr.Close()
+		cr := &countingReader{r: zr}
+
+		// A u16 version, a u16 client version and a u32 duration.
+		var hdr [8]byte
+		if _, err := io.ReadFull(cr, hdr[:8]); err != nil {
+			yield(data.RawPacket{}, err)
+			return
+		}
+		if version := binary.LittleEndian.Uint16(hdr[0:2]); version != tmvVersion {
+			yield(data.RawPacket{}, fmt.Errorf("unknown .tmv version %d", version))
+			return
+		}
+
+		var tick uint64
+		var chunk []byte
+		for {
+			if _, err := io.ReadFull(cr, hdr[:1]); err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(data.RawPacket{}, err)
+				}
+				return
+			}
+			frameType := hdr[0]
+			if frameType == tmvFrameMarker {
+				continue
+			}
+			if frameType != tmvFramePacket {
+				yield(data.RawPacket{}, fmt.Errorf("unknown .tmv frame type %d at offset %d", frameType, cr.n-1))
+				return
+			}
+
+			// A u32 delay and a u16 length.
+			if _, err := io.ReadFull(cr, hdr[:6]); err != nil {
+				yield(data.RawPacket{}, err)
+				return
+			}
+			tick += uint64(binary.LittleEndian.Uint32(hdr[0:4]))
+			frameLen := int(binary.LittleEndian.Uint16(hdr[4:6]))
+
+			cur := cr.n
+			if !reuse || cap(chunk) < frameLen {
+				chunk = make([]byte, frameLen)
+			}
+			chunk = chunk[:frameLen]
+			if _, err := io.ReadFull(cr, chunk); err != nil {
+				yield(data.RawPacket{}, err)
+				return
+			}
+
+			if !yieldChunk(yield, chunk, int(cur), time.Duration(tick)*time.Millisecond) {
+				return
+			}
+		}
+	}
+}
+
+// yieldChunk splits a chunk of length-prefixed packets and yields them one by one.
+func yieldChunk(yield func(data.RawPacket, error) bool, chunk []byte, fileOffset int, timeOffset time.Duration) bool {
+	for pos := 0; pos < len(chunk); {
+		if len(chunk)-pos < 2 {
+			yield(data.RawPacket{}, fmt.Errorf("at file offset %d: truncated packet length", fileOffset+pos))
+			return false
+		}
+		pktLen := int(binary.LittleEndian.Uint16(chunk[pos:]))
+		pos += 2
+		if len(chunk)-pos < pktLen {
+			yield(data.RawPacket{}, fmt.Errorf("at file offset %d: packet length %d exceeds chunk", fileOffset+pos, pktLen))
+			return false
+		}
+		if !yield(data.RawPacket{
+			FileOffset: fileOffset + pos,
+			TimeOffset: timeOffset,
+			Data:       chunk[pos : pos+pktLen],
+		}, nil) {
+			return false
+		}
+		pos += pktLen
+	}
+	return true
+}
+
+// countingReader keeps track of the number of bytes read so far.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -44,7 +44,7 @@ func Merge(w io.WriteSeeker, opts *MergeOpts, r ...io.ReadSeeker) error {
 	tick := uint64(0)
 	for _, r := range r {
 		tickOffset := tick
-		for packet, err := range Read(r) {
+		for packet, err := range Read(r, nil) {
 			tick = tickOffset + uint64(packet.TimeOffset.Milliseconds())
 			if err != nil {
 				return err
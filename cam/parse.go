@@ -23,10 +23,24 @@ type ParseOpts struct {
 
 	// If set, Parse will populate the maps.
 	Stats *ParseStats
+
+	// If set, called with packets timestamped earlier than the one before them. See ReadOpts.OnTickRegression.
+	OnTickRegression func(p data.RawPacket, prev time.Duration)
 }
 
 // Parse returns an iterator over the provided io.ReadSeeker that returns subsequent data.Operations.
 func Parse(r io.ReadSeeker, opts *ParseOpts) iter.Seq2[data.Operation, error] {
+	return ParseStream(r, opts)
+}
+
+// ParseStream returns an iterator over the provided io.Reader that returns subsequent data.Operations.
+// Like ReadStream, it never seeks.
+func ParseStream(r io.Reader, opts *ParseOpts) iter.Seq2[data.Operation, error] {
+	// Packets are decoded before the next one is read, so their buffers can be reused.
+	return parseStream(r, opts, &ReadOpts{ReuseBuffers: true})
+}
+
+func parseStream(r io.Reader, opts *ParseOpts, readOpts *ReadOpts) iter.Seq2[data.Operation, error] {
 	return func(yield func(data.Operation, error) bool) {
 		if opts == nil || opts.DATFile == nil {
 			yield(nil, errMissingDat)
@@ -41,11 +55,20 @@ func Parse(r io.ReadSeeker, opts *ParseOpts) iter.Seq2[data.Operation, error] {
 		}
 
 		state := &parseState{
+			msg:   message{dat: opts.DATFile},
 			stats: opts.Stats,
 		}
 
+		if opts.OnTickRegression != nil {
+			ro := ReadOpts{OnTickRegression: opts.OnTickRegression}
+			if readOpts != nil {
+				ro.ReuseBuffers = readOpts.ReuseBuffers
+			}
+			readOpts = &ro
+		}
+
 		var finalTimeOffset time.Duration
-		for packet, err := range Read(r) {
+		for packet, err := range ReadStream(r, readOpts) {
 			if err != nil {
 				yieldErr(err)
 				return
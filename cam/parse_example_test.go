@@ -25,7 +25,7 @@ func ExampleParse_dialogues() {
 			panic(err)
 		}
 
-		if msg, ok := op.(data.CreatureMessage); ok && msg.Type == 1 {
+		if msg, ok := op.(data.CreatureMessage); ok && msg.Type == data.SpeakSay {
 			messages = append(messages, msg)
 		}
 	}
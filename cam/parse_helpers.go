@@ -1,9 +1,7 @@
 package cam
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
 	"strings"
 	"time"
@@ -14,58 +12,91 @@ import (
 )
 
 type message struct {
-	r   io.ReadSeeker
-	len int64
+	buf []byte
+	pos int
 	dat *dat.File
+
+	// If set, getString appends the strings it reads to spans. Used by Anonymize.
+	recordSpans bool
+	spans       []stringSpan
+}
+
+// stringSpan is a length-prefixed string within message.buf.
+type stringSpan struct {
+	start, end int // Including the length prefix.
+	value      string
+}
+
+// reset points the message at a new packet, so that it can be reused.
+func (m *message) reset(buf []byte) {
+	m.buf = buf
+	m.pos = 0
 }
 
-func newMessage(buf []byte, dat *dat.File) *message {
-	return &message{
-		r:   bytes.NewReader(buf),
-		len: int64(len(buf)),
-		dat: dat,
+// next consumes n bytes, mirroring io.ReadFull errors.
+func (m *message) next(n int) ([]byte, error) {
+	switch rem := len(m.buf) - m.pos; {
+	case rem <= 0:
+		return nil, io.EOF
+	case rem < n:
+		m.pos = len(m.buf)
+		return nil, io.ErrUnexpectedEOF
 	}
+	b := m.buf[m.pos : m.pos+n]
+	m.pos += n
+	return b, nil
 }
 
 func (m *message) getByte() (byte, error) {
-	var b byte
-	err := binary.Read(m.r, binary.LittleEndian, &b)
-	return b, err
+	b, err := m.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
 }
 
 func (m *message) getU16() (uint16, error) {
-	var v uint16
-	err := binary.Read(m.r, binary.LittleEndian, &v)
-	return v, err
+	b, err := m.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
 }
 
 func (m *message) getU32() (uint32, error) {
-	var v uint32
-	err := binary.Read(m.r, binary.LittleEndian, &v)
-	return v, err
+	b, err := m.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
 }
 
 func (m *message) getString(ret *string, ignore bool) error {
+	start := m.pos
 	length, err := m.getU16()
 	if err != nil {
 		return err
 	}
-	if ignore {
-		if _, err := m.r.Seek(int64(length), io.SeekCurrent); err != nil {
-			return err
-		}
+	if length == 0 {
+		*ret = ""
 		return nil
 	}
-	buf := make([]byte, length)
-	if _, err := io.ReadFull(m.r, buf); err != nil {
+	buf, err := m.next(int(length))
+	if err != nil {
 		return err
 	}
-	utf, err := decoder.Bytes(buf)
+	if ignore {
+		return nil
+	}
+	str, err := decodeString(buf)
 	if err != nil {
 		return err
 	}
 
-	*ret = string(utf)
+	*ret = str
+	if m.recordSpans {
+		m.spans = append(m.spans, stringSpan{start: start, end: m.pos, value: str})
+	}
 	return nil
 }
 
@@ -121,28 +152,23 @@ func (m *message) peekU16() (uint16, error) {
 	if err != nil {
 		return 0, err
 	}
-	if _, err := m.r.Seek(-2, io.SeekCurrent); err != nil {
-		return 0, fmt.Errorf("seek back after peek: %w", err)
-	}
+	m.pos -= 2
 	return v, nil
 }
 
 func (m *message) remaining() int {
-	cur, err := m.r.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return 0
-	}
-	return int(m.len - cur)
+	return len(m.buf) - m.pos
 }
 
 type parseState struct {
-	stats        *ParseStats
-	playerPos    data.Location
-	playerID     uint32
-	playerName   string
-	serverName   string
-	lastVisit    time.Time
-	seenMessage  bool
+	msg         message // Reused across packets.
+	stats       *ParseStats
+	playerPos   data.Location
+	playerID    uint32
+	playerName  string
+	serverName  string
+	lastVisit   time.Time
+	seenMessage bool
 }
 
 const lastVisitPrefix = "Your last visit in "
@@ -325,14 +351,16 @@ func (m *message) getThing(ignore bool) (data.Thing, error) {
 		if err != nil {
 			return data.Thing{}, err
 		}
-		c.Skull, err = m.getByte()
+		skull, err := m.getByte()
 		if err != nil {
 			return data.Thing{}, err
 		}
-		c.Shield, err = m.getByte()
+		c.Skull = data.Skull(skull)
+		shield, err := m.getByte()
 		if err != nil {
 			return data.Thing{}, err
 		}
+		c.Shield = data.Shield(shield)
 		return data.Thing{HasCreature: true, Creature: c}, nil
 	}
 
@@ -374,12 +402,25 @@ func getItem(m *message, itemID uint16) (data.Item, error) {
 			return data.Item{}, err
 		}
 	} else if m.dat.IsFluid(int(itemID)) || m.dat.IsFluidContainer(int(itemID)) {
-		item.SubType, err = m.getByte()
+		subType, err := m.getByte()
 		if err != nil {
 			return data.Item{}, err
 		}
+		item.SubType = data.FluidColor(subType)
 	}
 	return item, nil
 }
 
-var decoder = charmap.Windows1252.NewDecoder()
+// decodeString converts Windows-1252 text, skipping the decoder for plain ASCII.
+func decodeString(buf []byte) (string, error) {
+	for _, b := range buf {
+		if b >= 0x80 {
+			utf, err := charmap.Windows1252.NewDecoder().Bytes(buf)
+			if err != nil {
+				return "", err
+			}
+			return string(utf), nil
+		}
+	}
+	return string(buf), nil
+}
@@ -8,7 +8,8 @@ import (
 )
 
 func parsePacket(state *parseState, buf []byte, timeOffset time.Duration, opts *ParseOpts) ([]data.Operation, error) {
-	m := newMessage(buf, opts.DATFile)
+	m := &state.msg
+	m.reset(buf)
 	var ops []data.Operation
 
 	for m.remaining() > 0 {
@@ -400,7 +401,7 @@ func parseInventoryItemSet(m *message, s *parseState, ignore bool, offset time.D
 	if err != nil {
 		return nil, err
 	}
-	return data.InventoryItemSet{TimeOffset: offset, PlayerPos: s.playerPos, Slot: slot, Item: item}, nil
+	return data.InventoryItemSet{TimeOffset: offset, PlayerPos: s.playerPos, Slot: data.InventorySlot(slot), Item: item}, nil
 
 }
 
@@ -409,7 +410,7 @@ func parseInventoryItemClear(m *message, s *parseState, ignore bool, offset time
 	if err != nil {
 		return nil, err
 	}
-	return data.InventoryItemClear{TimeOffset: offset, PlayerPos: s.playerPos, Slot: slot}, nil
+	return data.InventoryItemClear{TimeOffset: offset, PlayerPos: s.playerPos, Slot: data.InventorySlot(slot)}, nil
 }
 
 func parseTradeOwn(m *message, s *parseState, ignore bool, offset time.Duration) (data.Operation, error) {
@@ -586,7 +587,7 @@ func parseCreatureSkull(m *message, s *parseState, ignore bool, offset time.Dura
 	if err != nil {
 		return nil, err
 	}
-	return data.CreatureSkull{TimeOffset: offset, PlayerPos: s.playerPos, CreatureID: id, Skull: skull}, nil
+	return data.CreatureSkull{TimeOffset: offset, PlayerPos: s.playerPos, CreatureID: id, Skull: data.Skull(skull)}, nil
 }
 
 func parseCreatureParty(m *message, s *parseState, ignore bool, offset time.Duration) (data.Operation, error) {
@@ -598,7 +599,7 @@ func parseCreatureParty(m *message, s *parseState, ignore bool, offset time.Dura
 	if err != nil {
 		return nil, err
 	}
-	return data.CreatureParty{TimeOffset: offset, PlayerPos: s.playerPos, CreatureID: id, Shield: shield}, nil
+	return data.CreatureParty{TimeOffset: offset, PlayerPos: s.playerPos, CreatureID: id, Shield: data.Shield(shield)}, nil
 }
 
 func parsePromptTextUpdate(m *message, s *parseState, ignore bool, offset time.Duration) (data.Operation, error) {
@@ -716,7 +717,7 @@ func parsePlayerIcons(m *message, s *parseState, ignore bool, offset time.Durati
 	if err != nil {
 		return nil, err
 	}
-	return data.PlayerIcons{TimeOffset: offset, PlayerPos: s.playerPos, Icons: icons}, nil
+	return data.PlayerIcons{TimeOffset: offset, PlayerPos: s.playerPos, Icons: data.Icons(icons)}, nil
 }
 
 func parseTargetClear(m *message, s *parseState, ignore bool, offset time.Duration) (data.Operation, error) {
@@ -734,18 +735,19 @@ func parseCreatureMessage(m *message, s *parseState, ignore bool, offset time.Du
 	if err != nil {
 		return nil, err
 	}
-	op.Type, err = m.getByte()
+	t, err := m.getByte()
 	if err != nil {
 		return nil, err
 	}
-	switch op.Type {
-	case 1, 2, 3, 0x10, 0x11:
+	op.Type = data.SpeakType(t)
+	switch {
+	case op.Type.HasLocation():
 		loc, err := m.getLocation()
 		if err != nil {
 			return nil, err
 		}
 		op.Location = &loc
-	case 5, 6, 0xA, 0xC, 0xE:
+	case op.Type.HasChannel():
 		ch, err := m.getU16()
 		if err != nil {
 			return nil, err
@@ -875,7 +877,7 @@ func parseMessage(m *message, s *parseState, ignore bool, offset time.Duration)
 			s.lastVisit = lastVisit
 		}
 	}
-	return data.Message{TimeOffset: offset, PlayerPos: s.playerPos, Type: t, Text: text}, nil
+	return data.Message{TimeOffset: offset, PlayerPos: s.playerPos, Type: data.MessageType(t), Text: text}, nil
 }
 
 func parseMoveCancel(m *message, s *parseState, ignore bool, offset time.Duration) (data.Operation, error) {
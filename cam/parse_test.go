@@ -2,10 +2,12 @@ package cam
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"os"
 	"reflect"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
@@ -27,7 +29,7 @@ func TestRead(t *testing.T) {
 			r := bytes.NewReader(fx.cam)
 			w := bytes.NewBuffer(nil)
 
-			for packet, err := range Read(r) {
+			for packet, err := range Read(r, nil) {
 				if err != nil {
 					t.Fatalf("Read() error: %v", err)
 				}
@@ -63,12 +65,8 @@ func TestParse(t *testing.T) {
 				switch op := op.(type) {
 				case data.CamMetadata:
 				default:
-					t := time.Duration(reflect.ValueOf(op).FieldByName("TimeOffset").Int()).Truncate(time.Second)
-					x := reflect.ValueOf(op).FieldByName("PlayerPos").FieldByName("X").Int()
-					y := reflect.ValueOf(op).FieldByName("PlayerPos").FieldByName("Y").Int()
-					z := reflect.ValueOf(op).FieldByName("PlayerPos").FieldByName("Z").Int()
-					n := reflect.TypeOf(op).Name()
-					fmt.Fprintf(w, "%v - (%d,%d,%d) - %s\n", t, x, y, z, n)
+					t := data.TimeOffsetOf(op).Truncate(time.Second)
+					fmt.Fprintf(w, "%v - %v - %v\n", t, data.PlayerPosOf(op), op)
 				}
 			}
 
@@ -168,6 +166,7 @@ func TestParse_MissingDat(t *testing.T) {
 }
 
 func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
 	for b.Loop() {
 		r := bytes.NewReader(tibiantisCam)
 		for _, err := range Parse(r, testParseOpts()) {
@@ -178,7 +177,21 @@ func BenchmarkParse(b *testing.B) {
 	}
 }
 
+// BenchmarkParseCopyBuffers parses without reusing packet buffers, as a baseline for BenchmarkParse.
+func BenchmarkParseCopyBuffers(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		r := bytes.NewReader(tibiantisCam)
+		for _, err := range parseStream(r, testParseOpts(), nil) {
+			if err != nil {
+				b.Fatalf("Parse() error: %v", err)
+			}
+		}
+	}
+}
+
 func BenchmarkParseIgnore(b *testing.B) {
+	b.ReportAllocs()
 	for b.Loop() {
 		r := bytes.NewReader(tibiantisCam)
 		for _, err := range Parse(r, &ParseOpts{
@@ -193,12 +206,128 @@ func BenchmarkParseIgnore(b *testing.B) {
 }
 
 func BenchmarkRead(b *testing.B) {
+	b.ReportAllocs()
 	for b.Loop() {
 		r := bytes.NewReader(tibiantisCam)
-		for _, err := range Read(r) {
+		for _, err := range Read(r, nil) {
 			if err != nil {
 				b.Fatalf("Read() error: %v", err)
 			}
 		}
 	}
 }
+
+func BenchmarkReadReuseBuffers(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		r := bytes.NewReader(tibiantisCam)
+		for _, err := range Read(r, &ReadOpts{ReuseBuffers: true}) {
+			if err != nil {
+				b.Fatalf("Read() error: %v", err)
+			}
+		}
+	}
+}
+
+func TestRead_ReuseBuffers(t *testing.T) {
+	want := readPackets(t, FormatCAM, tibiantisCam)
+
+	var i int
+	var prev []byte
+	for packet, err := range Read(bytes.NewReader(tibiantisCam), &ReadOpts{ReuseBuffers: true}) {
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		if !bytes.Equal(packet.Data, want[i].Data) {
+			t.Fatalf("Packet %d differs", i)
+		}
+		if i > 0 && len(packet.Data) <= cap(prev) && &packet.Data[:1][0] != &prev[:1][0] {
+			t.Fatalf("Packet %d did not reuse the previous buffer", i)
+		}
+		prev = packet.Data
+		i++
+	}
+	if i != len(want) {
+		t.Fatalf("Read() returned %d packets, want %d", i, len(want))
+	}
+}
+
+func TestRead_TickRegression(t *testing.T) {
+	b := bytes.Clone(camHeader)
+	for _, tick := range []uint64{1000, 3000, 2500, 500, 4000} {
+		b = binary.LittleEndian.AppendUint64(b, tick)
+		b = append(b, 1, 0, 0x1E)
+	}
+
+	type regression struct{ at, prev time.Duration }
+	var got []regression
+	var offsets []time.Duration
+	for packet, err := range Read(bytes.NewReader(b), &ReadOpts{OnTickRegression: func(p data.RawPacket, prev time.Duration) {
+		got = append(got, regression{p.TimeOffset, prev})
+	}}) {
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		offsets = append(offsets, packet.TimeOffset)
+	}
+
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	if diff := cmp.Diff([]time.Duration{0, ms(2000), ms(1500), ms(-500), ms(3000)}, offsets); diff != "" {
+		t.Errorf("Read() TimeOffset diff; -want +got:\n%v", diff)
+	}
+	want := []regression{{ms(1500), ms(2000)}, {ms(-500), ms(1500)}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(regression{})); diff != "" {
+		t.Errorf("OnTickRegression() calls diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	for _, fx := range camFixtures() {
+		t.Run(fx.name, func(t *testing.T) {
+			want := readPackets(t, FormatCAM, fx.cam)
+
+			// Hide io.Seeker and return short reads.
+			r := iotest.HalfReader(bytes.NewReader(fx.cam))
+
+			var i int
+			for packet, err := range ReadStream(r, nil) {
+				if err != nil {
+					t.Fatalf("ReadStream() error: %v", err)
+				}
+				if i >= len(want) {
+					t.Fatalf("ReadStream() returned more than %d packets", len(want))
+				}
+				if got := packet.TimeOffset.Milliseconds(); got != want[i].TimeOffset || !bytes.Equal(packet.Data, want[i].Data) {
+					t.Fatalf("Packet %d differs", i)
+				}
+				if got, want := packet.Data, fx.cam[packet.FileOffset:packet.FileOffset+len(packet.Data)]; !bytes.Equal(got, want) {
+					t.Fatalf("Packet %d FileOffset = %d does not point at the packet data", i, packet.FileOffset)
+				}
+				i++
+			}
+			if i != len(want) {
+				t.Fatalf("ReadStream() returned %d packets, want %d", i, len(want))
+			}
+		})
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	var want, got []data.Operation
+	for op, err := range Parse(bytes.NewReader(relicCam), &ParseOpts{DATFile: tibiaRelicDAT}) {
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		want = append(want, op)
+	}
+	for op, err := range ParseStream(iotest.HalfReader(bytes.NewReader(relicCam)), &ParseOpts{DATFile: tibiaRelicDAT}) {
+		if err != nil {
+			t.Fatalf("ParseStream() error: %v", err)
+		}
+		got = append(got, op)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ParseStream() diff; -Parse() +ParseStream():\n%v", cmp.Diff(want, got))
+	}
+}
@@ -1,6 +1,7 @@
 package cam
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -10,67 +11,139 @@ import (
 	"github.com/s5i/tcam/data"
 )
 
+// ReadOpts controls the behavior of Read and ReadStream.
+type ReadOpts struct {
+	// If set, the returned data.RawPacket.Data is only valid until the next iteration,
+	// as its backing array gets reused for subsequent packets.
+	ReuseBuffers bool
+
+	// If set, OnTickRegression is called with each packet timestamped earlier than the one before it,
+	// before the packet is returned. prev is the TimeOffset of the packet before it. Recordings are written
+	// in order, so a regression hints at an edited recording.
+	OnTickRegression func(p data.RawPacket, prev time.Duration)
+}
+
 // Read returns an iterator over the provided io.ReadSeeker that returns subsequent data.RawPackets.
-func Read(r io.ReadSeeker) iter.Seq2[data.RawPacket, error] {
-		return func(yield func(data.RawPacket, error) bool) {
+// opts may be nil.
+//
+// Read consumes the recording from its current position, same as ReadStream.
+func Read(r io.ReadSeeker, opts *ReadOpts) iter.Seq2[data.RawPacket, error] {
+	return ReadStream(r, opts)
+}
+
+// ReadStream returns an iterator over the provided io.Reader that returns subsequent data.RawPackets.
+// It never seeks, so it works with pipes, HTTP bodies or archive entries.
+// TimeOffset is relative to the first packet, and negative for packets timestamped earlier than it.
+//
+// Gzip- and zstd-compressed recordings are decompressed transparently (see Decompress);
+// FileOffset then refers to the decompressed stream.
+func ReadStream(r io.Reader, opts *ReadOpts) iter.Seq2[data.RawPacket, error] {
+	return func(yield func(data.RawPacket, error) bool) {
 		yieldVal := func(p data.RawPacket) bool { return yield(p, nil) }
 		yieldErr := func(err error) {
 			if !errors.Is(err, io.EOF) {
 				yield(data.RawPacket{}, err)
 			}
 		}
+		reuse := opts != nil && opts.ReuseBuffers
+		var onRegression func(data.RawPacket, time.Duration)
+		if opts != nil {
+			onRegression = opts.OnTickRegression
+		}
 
-		var headerSize uint32
-		if err := binary.Read(r, binary.LittleEndian, &headerSize); err != nil {
+		br := bufio.NewReader(r)
+		zr, err := decompressStream(br)
+		if err != nil {
 			yieldErr(err)
 			return
 		}
-		dataOffset := int64(headerSize) + 4
-		if _, err := r.Seek(dataOffset, io.SeekStart); err != nil {
-			yieldErr(err)
-			return
+		if zr != nil {
+			defer zr.Close()
+			br = bufio.NewReader(zr)
 		}
 
-		// Read first tick count (8 bytes), rewind.
-		var startTick uint64
-		if err := binary.Read(r, binary.LittleEndian, &startTick); err != nil {
+		// Discard the header.
+		var hdr [10]byte
+		if _, err := io.ReadFull(br, hdr[:4]); err != nil {
 			yieldErr(err)
 			return
 		}
-		if _, err := r.Seek(-8, io.SeekCurrent); err != nil {
+		headerSize := binary.LittleEndian.Uint32(hdr[:4])
+		if _, err := br.Discard(int(headerSize)); err != nil {
 			yieldErr(err)
 			return
 		}
+		offset := 4 + int(headerSize)
 
-		for {
-			// Read tick count (8 bytes).
-			var curTick uint64
-			if err := binary.Read(r, binary.LittleEndian, &curTick); err != nil {
+		var startTick uint64
+		var prev time.Duration
+		var buf []byte
+		for first := true; ; first = false {
+			// Read tick count (8 bytes) and packet length (2 bytes).
+			if _, err := io.ReadFull(br, hdr[:]); err != nil {
 				yieldErr(err)
 				return
 			}
-
-			// Read packet length (2 bytes).
-			var pktLen uint16
-			if err := binary.Read(r, binary.LittleEndian, &pktLen); err != nil {
-				yieldErr(err)
-				return
+			curTick := binary.LittleEndian.Uint64(hdr[0:8])
+			pktLen := int(binary.LittleEndian.Uint16(hdr[8:10]))
+			offset += len(hdr)
+			if first {
+				startTick = curTick
 			}
 
-			cur, _ := r.Seek(0, io.SeekCurrent)
-			packetData := make([]byte, pktLen)
-			if _, err := io.ReadFull(r, packetData); err != nil {
+			if !reuse || cap(buf) < pktLen {
+				buf = make([]byte, pktLen)
+			}
+			packetData := buf[:pktLen]
+			if _, err := io.ReadFull(br, packetData); err != nil {
 				yieldErr(err)
 				return
 			}
 
-			if !yieldVal(data.RawPacket{
-				FileOffset: int(cur),
-				TimeOffset: time.Duration(curTick-startTick) * time.Millisecond,
+			packet := data.RawPacket{
+				FileOffset: offset,
+				TimeOffset: time.Duration(int64(curTick-startTick)) * time.Millisecond,
 				Data:       packetData,
-			}) {
+			}
+			if !first && packet.TimeOffset < prev && onRegression != nil {
+				onRegression(packet, prev)
+			}
+			prev = packet.TimeOffset
+			if !yieldVal(packet) {
 				return
 			}
+			offset += pktLen
 		}
 	}
 }
+
+// ReadHeader returns the header of a .cam recording: the bytes following its 4-byte size field.
+// Tibia Cam headers hold an 8-byte checksum; recordings written by Convert have it zeroed.
+//
+// Gzip- and zstd-compressed recordings are decompressed transparently.
+func ReadHeader(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	zr, err := decompressStream(br)
+	if err != nil {
+		return nil, err
+	}
+	if zr != nil {
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(br, size[:]); err != nil {
+		return nil, err
+	}
+	// Read through a LimitReader rather than allocating a corrupt size upfront.
+	n := int64(binary.LittleEndian.Uint32(size[:]))
+	hdr, err := io.ReadAll(io.LimitReader(br, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(hdr)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return hdr, nil
+}
@@ -0,0 +1,35 @@
+package cam
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	hdr, err := ReadHeader(bytes.NewReader(relicCam))
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if want := relicCam[4:12]; !bytes.Equal(hdr, want) {
+		t.Errorf("ReadHeader() = % x, want % x", hdr, want)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	cw, err := Compress(buf, CompressionGzip)
+	if err != nil {
+		t.Fatalf("Compress() error: %v", err)
+	}
+	if err := Convert(FormatCAM, cw, Read(bytes.NewReader(relicCam), nil)); err != nil {
+		t.Fatalf("Convert() error: %v", err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	hdr, err = ReadHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadHeader() of a converted recording error: %v", err)
+	}
+	if want := make([]byte, 8); !bytes.Equal(hdr, want) {
+		t.Errorf("ReadHeader() of a converted recording = % x, want % x", hdr, want)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+
+	"github.com/s5i/tcam/cam"
+)
+
+func runConvert(args []string) error {
+	if len(args) != 2 {
+		return errors.New("want exactly two arguments: <in> <out>")
+	}
+	inPath, outPath := args[0], args[1]
+
+	src, err := cam.FormatForPath(inPath)
+	if err != nil {
+		return err
+	}
+	dst, err := cam.FormatForPath(outPath)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	cw, err := cam.Compress(w, cam.CompressionForPath(outPath))
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := cam.Convert(dst, cw, cam.ReadFormat(src, in, &cam.ReadOpts{ReuseBuffers: true})); err != nil {
+		out.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
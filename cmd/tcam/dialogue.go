@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"runtime"
+
+	"github.com/s5i/tcam/analysis"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/dat"
+)
+
+func runDialogue(args []string) error {
+	fs := flag.NewFlagSet("dialogue", flag.ContinueOnError)
+	datPath := fs.String("dat", "", "client .dat file the recordings were made with")
+	asJSON := fs.Bool("json", false, "write JSON instead of Markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *datPath == "" {
+		return errors.New("-dat is required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("want at least one .cam recording")
+	}
+
+	df, err := os.Open(*datPath)
+	if err != nil {
+		return err
+	}
+	datFile, err := dat.Read(bufio.NewReader(df))
+	df.Close()
+	if err != nil {
+		return err
+	}
+
+	x := analysis.NewDialogueExtractor(nil)
+	res, err := batch.ParseAll(context.Background(), fs.Args(), batch.StaticDat(datFile), runtime.NumCPU(), x.Add)
+	if err != nil {
+		return err
+	}
+	if len(res.Errors) > 0 {
+		return res.Errors[0]
+	}
+
+	r := x.Report()
+	if *asJSON {
+		return r.WriteJSON(os.Stdout)
+	}
+	return r.WriteMarkdown(os.Stdout)
+}
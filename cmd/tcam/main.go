@@ -0,0 +1,53 @@
+// Command tcam works with Tibia recordings.
+//
+// Usage:
+//
+//	tcam convert <in> <out>
+//	tcam query -dat <file.dat> [-v] <in> <query>
+//	tcam dialogue -dat <file.dat> [-json] <in.cam>...
+//	tcam trades -dat <file.dat> <in.cam>...
+//	tcam timeline -dat <file.dat> [-tz <zone>] [-save <hh:mm>] [-start <time>] [-mtime] <in.cam>
+//
+// Recording formats are inferred from file extensions (.cam, .rec, .tmv),
+// optionally followed by a compression extension (.gz, .zst).
+// See package query for the query syntax.
+// The dialogue command writes NPC keyword graphs as Markdown or JSON,
+// the trades command writes a CSV ledger of trades, and the timeline command
+// lists activity segments with wall-clock times.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"convert":  runConvert,
+	"query":    runQuery,
+	"dialogue": runDialogue,
+	"trades":   runTrades,
+	"timeline": runTimeline,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "tcam %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tcam convert <in> <out>")
+	fmt.Fprintln(os.Stderr, "       tcam query -dat <file.dat> [-v] <in> <query>")
+	fmt.Fprintln(os.Stderr, "       tcam dialogue -dat <file.dat> [-json] <in.cam>...")
+	fmt.Fprintln(os.Stderr, "       tcam trades -dat <file.dat> <in.cam>...")
+	fmt.Fprintln(os.Stderr, "       tcam timeline -dat <file.dat> [-tz <zone>] [-save <hh:mm>] [-start <time>] [-mtime] <in.cam>")
+	os.Exit(2)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/query"
+)
+
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	datPath := fs.String("dat", "", "client .dat file the recording was made with")
+	verbose := fs.Bool("v", false, "print all operation fields")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *datPath == "" {
+		return errors.New("-dat is required")
+	}
+	if fs.NArg() != 2 {
+		return errors.New("want exactly two arguments: <in> <query>")
+	}
+	inPath := fs.Arg(0)
+
+	q, err := query.Compile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	src, err := cam.FormatForPath(inPath)
+	if err != nil {
+		return err
+	}
+
+	df, err := os.Open(*datPath)
+	if err != nil {
+		return err
+	}
+	datFile, err := dat.Read(bufio.NewReader(df))
+	df.Close()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var r io.ReadSeeker = in
+	if src != cam.FormatCAM {
+		// Parse only reads CAM recordings.
+		buf := bytes.NewBuffer(nil)
+		if err := cam.Convert(cam.FormatCAM, buf, cam.ReadFormat(src, in, &cam.ReadOpts{ReuseBuffers: true})); err != nil {
+			return err
+		}
+		r = bytes.NewReader(buf.Bytes())
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	format := "%v - %v\n"
+	if *verbose {
+		format = "%v - %+v\n"
+	}
+	for op, err := range q.Filter(cam.Parse(r, &cam.ParseOpts{DATFile: datFile, TFilter: q.Types()})) {
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, format, data.TimeOffsetOf(op).Truncate(time.Millisecond), op)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/s5i/tcam/analysis"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+)
+
+func runTimeline(args []string) error {
+	fs := flag.NewFlagSet("timeline", flag.ContinueOnError)
+	datPath := fs.String("dat", "", "client .dat file the recording was made with")
+	tz := fs.String("tz", "UTC", "time zone of the server, e.g. Europe/Berlin")
+	save := fs.String("save", "", "time of day of the daily server save in -tz, e.g. 10:00; refines -mtime")
+	start := fs.String("start", "", "wall-clock time the recording started at, in RFC 3339")
+	mtime := fs.Bool("mtime", false, "take the file modification time as the time the recording ended at")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *datPath == "" {
+		return errors.New("-dat is required")
+	}
+	if fs.NArg() != 1 {
+		return errors.New("want exactly one .cam recording")
+	}
+	inPath := fs.Arg(0)
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	opts := &analysis.TimelineOpts{Location: loc}
+	if *save != "" {
+		t, err := time.Parse("15:04", *save)
+		if err != nil {
+			return fmt.Errorf("-save: %v", err)
+		}
+		opts.ServerSave = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if opts.ServerSave == 0 {
+			opts.ServerSave = 24 * time.Hour
+		}
+	}
+	if *start != "" {
+		if opts.Start, err = time.Parse(time.RFC3339, *start); err != nil {
+			return fmt.Errorf("-start: %v", err)
+		}
+	}
+	if *mtime {
+		fi, err := os.Stat(inPath)
+		if err != nil {
+			return err
+		}
+		opts.End = fi.ModTime()
+	}
+
+	df, err := os.Open(*datPath)
+	if err != nil {
+		return err
+	}
+	datFile, err := dat.Read(bufio.NewReader(df))
+	df.Close()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tl := analysis.NewTimeline(opts)
+	for op, err := range cam.Parse(in, &cam.ParseOpts{DATFile: datFile}) {
+		if err != nil {
+			return err
+		}
+		tl.Add(op)
+	}
+	r := tl.Report()
+
+	w := bufio.NewWriter(os.Stdout)
+	if r.Anchor == analysis.AnchorNone {
+		fmt.Fprint(w, "anchor: none")
+	} else {
+		fmt.Fprintf(w, "anchor: %v, start %v", r.Anchor, r.Start.Format(time.DateTime+" MST"))
+	}
+	if !r.NotBefore.IsZero() {
+		fmt.Fprintf(w, ", not before %v", r.NotBefore.Format(time.DateTime+" MST"))
+	}
+	fmt.Fprintln(w)
+	for _, s := range r.Segments {
+		span := fmt.Sprintf("%v-%v", s.Start.Truncate(time.Second), s.End.Truncate(time.Second))
+		if !s.From.IsZero() {
+			span = fmt.Sprintf("%s-%s", s.From.Format(time.DateTime), s.To.Format(time.TimeOnly))
+		}
+		fmt.Fprintf(w, "%s %v", span, s.Kind)
+		if s.Text != "" {
+			fmt.Fprintf(w, " %q", s.Text)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
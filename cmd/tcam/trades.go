@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"runtime"
+
+	"github.com/s5i/tcam/analysis"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/dat"
+)
+
+func runTrades(args []string) error {
+	fs := flag.NewFlagSet("trades", flag.ContinueOnError)
+	datPath := fs.String("dat", "", "client .dat file the recordings were made with")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *datPath == "" {
+		return errors.New("-dat is required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("want at least one .cam recording")
+	}
+
+	df, err := os.Open(*datPath)
+	if err != nil {
+		return err
+	}
+	datFile, err := dat.Read(bufio.NewReader(df))
+	df.Close()
+	if err != nil {
+		return err
+	}
+
+	l := analysis.NewTradeLedger(nil)
+	res, err := batch.ParseAll(context.Background(), fs.Args(), batch.StaticDat(datFile), runtime.NumCPU(), l.Add)
+	if err != nil {
+		return err
+	}
+	if len(res.Errors) > 0 {
+		return res.Errors[0]
+	}
+	return analysis.WriteLedgerCSV(os.Stdout, l.Report())
+}
@@ -8,6 +8,7 @@ import (
 // Properties holds metadata flags parsed from a Tibia .dat item entry.
 type Properties struct {
 	Ground         bool
+	GroundSpeed    uint16 // Walking over the ground takes 1000*GroundSpeed/Creature.Speed milliseconds.
 	GroundBorder   bool
 	OnBottom       bool
 	OnTop          bool
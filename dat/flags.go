@@ -33,10 +33,12 @@ func readPropertiesV1(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.OnBottom = true
 		case 0x02:
@@ -107,10 +109,12 @@ func readPropertiesV2(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.OnBottom = true
 		case 0x02:
@@ -171,10 +175,12 @@ func readPropertiesV3(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.GroundBorder = true
 		case 0x02:
@@ -248,10 +254,12 @@ func readPropertiesV4(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.GroundBorder = true
 		case 0x02:
@@ -323,10 +331,12 @@ func readPropertiesV5(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.GroundBorder = true
 		case 0x02:
@@ -403,10 +413,12 @@ func readPropertiesV6(br *binReader, id uint16) (Properties, error) {
 		}
 		switch flag {
 		case 0x00:
-			if _, err := br.u16(); err != nil {
+			speed, err := br.u16()
+			if err != nil {
 				return p, err
 			}
 			p.Ground = true
+			p.GroundSpeed = speed
 		case 0x01:
 			p.GroundBorder = true
 		case 0x02:
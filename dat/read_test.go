@@ -155,3 +155,36 @@ func assertIDs(t *testing.T, itemCount uint16, name string, check func(int) bool
 		}
 	}
 }
+
+func TestRegistry(t *testing.T) {
+	reg := dat.NewRegistry()
+	if err := reg.AddFile("tibiantis", "testdata/Tibiantis.dat"); err != nil {
+		t.Fatalf("AddFile(tibiantis) error: %v", err)
+	}
+	if err := reg.AddFile("relic", "testdata/TibiaRelic.dat"); err != nil {
+		t.Fatalf("AddFile(relic) error: %v", err)
+	}
+	if err := reg.AddFile("missing", "testdata/missing.dat"); err == nil {
+		t.Fatal("AddFile(missing) error = nil, want error")
+	}
+
+	if got, want := reg.Names(), []string{"tibiantis", "relic"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+
+	f, ok := reg.Get("relic")
+	if !ok {
+		t.Fatal("Get(relic) = _, false, want true")
+	}
+	if f.Signature != 0x439D5A33 {
+		t.Fatalf("Get(relic).Signature = 0x%X, want 0x439D5A33", f.Signature)
+	}
+
+	reg.Add("relic", readFile(t, "testdata/Tibiantis.dat"))
+	if got, want := reg.Len(), 2; got != want {
+		t.Fatalf("Len() after replacing = %d, want %d", got, want)
+	}
+	if f, _ := reg.Get("relic"); f.Signature != 0x6970EFAD {
+		t.Fatalf("Get(relic).Signature after replacing = 0x%X, want 0x6970EFAD", f.Signature)
+	}
+}
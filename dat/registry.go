@@ -0,0 +1,62 @@
+package dat
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Registry holds several .dat files, e.g. one per supported server or client version.
+type Registry struct {
+	names []string
+	files map[string]*File
+}
+
+// NewRegistry initializes an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{files: map[string]*File{}}
+}
+
+// Add registers f under name, replacing any previous file with the same name.
+func (r *Registry) Add(name string, f *File) {
+	if _, ok := r.files[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.files[name] = f
+}
+
+// AddFile reads the .dat file at path and registers it under name.
+func (r *Registry) AddFile(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.AddReader(name, f)
+}
+
+// AddReader reads a .dat file from rd and registers it under name.
+func (r *Registry) AddReader(name string, rd io.Reader) error {
+	f, err := Read(rd)
+	if err != nil {
+		return fmt.Errorf("dat: %s: %w", name, err)
+	}
+	r.Add(name, f)
+	return nil
+}
+
+// Get returns the file registered under name.
+func (r *Registry) Get(name string) (*File, bool) {
+	f, ok := r.files[name]
+	return f, ok
+}
+
+// Names returns registered names in the order they were first added.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Len returns the number of registered files.
+func (r *Registry) Len() int {
+	return len(r.names)
+}
@@ -0,0 +1,366 @@
+package data
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Values below follow protocol 7.x, which is what the parser reads; values only later protocols send are noted.
+// Protocols 8.40 to 8.60 renumbered speak and message types; SpeakTypeFor and MessageTypeFor map them.
+
+// SpeakType is the kind of a CreatureMessage.
+type SpeakType byte
+
+const (
+	SpeakSay                   SpeakType = 0x01
+	SpeakWhisper               SpeakType = 0x02
+	SpeakYell                  SpeakType = 0x03
+	SpeakPrivate               SpeakType = 0x04
+	SpeakChannel               SpeakType = 0x05
+	SpeakRuleViolation         SpeakType = 0x06
+	SpeakRuleViolationAnswer   SpeakType = 0x07
+	SpeakRuleViolationContinue SpeakType = 0x08
+	SpeakBroadcast             SpeakType = 0x09
+	SpeakChannelRed            SpeakType = 0x0A
+	SpeakPrivateRed            SpeakType = 0x0B
+	SpeakChannelOrange         SpeakType = 0x0C
+	SpeakChannelRedAnonymous   SpeakType = 0x0E
+	SpeakMonsterSay            SpeakType = 0x10
+	SpeakMonsterYell           SpeakType = 0x11
+)
+
+var speakTypeName = map[SpeakType]string{
+	SpeakSay:                   "say",
+	SpeakWhisper:               "whisper",
+	SpeakYell:                  "yell",
+	SpeakPrivate:               "private",
+	SpeakChannel:               "channel",
+	SpeakRuleViolation:         "rule violation",
+	SpeakRuleViolationAnswer:   "rule violation answer",
+	SpeakRuleViolationContinue: "rule violation continue",
+	SpeakBroadcast:             "broadcast",
+	SpeakChannelRed:            "channel red",
+	SpeakPrivateRed:            "private red",
+	SpeakChannelOrange:         "channel orange",
+	SpeakChannelRedAnonymous:   "channel red anonymous",
+	SpeakMonsterSay:            "monster say",
+	SpeakMonsterYell:           "monster yell",
+}
+
+func (t SpeakType) String() string { return enumName(speakTypeName, t, "SpeakType") }
+
+// HasLocation reports whether messages of this type carry a Location.
+func (t SpeakType) HasLocation() bool {
+	switch t {
+	case SpeakSay, SpeakWhisper, SpeakYell, SpeakMonsterSay, SpeakMonsterYell:
+		return true
+	}
+	return false
+}
+
+// HasChannel reports whether messages of this type carry a ChannelID.
+func (t SpeakType) HasChannel() bool {
+	switch t {
+	case SpeakChannel, SpeakRuleViolation, SpeakChannelRed, SpeakChannelOrange, SpeakChannelRedAnonymous:
+		return true
+	}
+	return false
+}
+
+// speakType84 maps speak types of protocols 8.40 to 8.60 to their 7.x values.
+// Private messages from and to NPCs (0x04, 0x05) and white channel messages (0x08) have none.
+var speakType84 = map[byte]SpeakType{
+	0x01: SpeakSay,
+	0x02: SpeakWhisper,
+	0x03: SpeakYell,
+	0x06: SpeakPrivate,
+	0x07: SpeakChannel,
+	0x09: SpeakRuleViolation,
+	0x0A: SpeakRuleViolationAnswer,
+	0x0B: SpeakRuleViolationContinue,
+	0x0C: SpeakBroadcast,
+	0x0D: SpeakChannelRed,
+	0x0E: SpeakPrivateRed,
+	0x0F: SpeakChannelOrange,
+	0x11: SpeakChannelRedAnonymous,
+	0x13: SpeakMonsterSay,
+	0x14: SpeakMonsterYell,
+}
+
+// SpeakTypeFor returns the SpeakType of a raw speak type sent by a server of the given protocol version,
+// e.g. 772 for 7.72. ok is false if the type has no 7.x counterpart or the version's numbering isn't known.
+func SpeakTypeFor(version int, raw byte) (t SpeakType, ok bool) {
+	switch {
+	case version < 840:
+		_, ok = speakTypeName[SpeakType(raw)]
+		return SpeakType(raw), ok
+	case version < 861:
+		t, ok = speakType84[raw]
+		return t, ok
+	}
+	return 0, false
+}
+
+// MessageType is the kind of a Message, which determines its color and where the client shows it.
+type MessageType byte
+
+const (
+	MessageConsoleYellow MessageType = 0x01
+	MessageConsoleBlue   MessageType = 0x04 // Light blue.
+	MessageConsoleOrange MessageType = 0x11
+	MessageWarning       MessageType = 0x12 // Red, in the game window and console.
+	MessageEventAdvance  MessageType = 0x13 // White, in the game window and console, e.g. "You are dead.".
+	MessageEventDefault  MessageType = 0x14 // White, at the bottom and in the console, e.g. the last visit.
+	MessageStatusDefault MessageType = 0x15 // White, at the bottom and in the console, e.g. "You lose ...".
+	MessageInfo          MessageType = 0x16 // Green, in the game window and console, e.g. "You see ...".
+	MessageStatusSmall   MessageType = 0x17 // White, at the bottom only, e.g. "Sorry, not possible.".
+	MessageConsoleDBlue  MessageType = 0x18 // Dark blue.
+	MessageConsoleRed    MessageType = 0x19
+)
+
+var messageTypeName = map[MessageType]string{
+	MessageConsoleYellow: "console yellow",
+	MessageConsoleBlue:   "console blue",
+	MessageConsoleOrange: "console orange",
+	MessageWarning:       "warning",
+	MessageEventAdvance:  "event advance",
+	MessageEventDefault:  "event default",
+	MessageStatusDefault: "status default",
+	MessageInfo:          "info",
+	MessageStatusSmall:   "status small",
+	MessageConsoleDBlue:  "console dark blue",
+	MessageConsoleRed:    "console red",
+}
+
+func (t MessageType) String() string { return enumName(messageTypeName, t, "MessageType") }
+
+// messageType84 maps message types of protocols 8.40 to 8.60 to their 7.x values.
+// Orange event messages (0x13) are shown like orange console ones.
+var messageType84 = map[byte]MessageType{
+	0x12: MessageConsoleRed,
+	0x13: MessageConsoleOrange,
+	0x14: MessageConsoleOrange,
+	0x15: MessageWarning,
+	0x16: MessageEventAdvance,
+	0x17: MessageEventDefault,
+	0x18: MessageStatusDefault,
+	0x19: MessageInfo,
+	0x1A: MessageStatusSmall,
+	0x1B: MessageConsoleDBlue,
+}
+
+// MessageTypeFor returns the MessageType of a raw message type sent by a server of the given protocol version,
+// e.g. 772 for 7.72. ok is false if the type has no 7.x counterpart or the version's numbering isn't known.
+func MessageTypeFor(version int, raw byte) (t MessageType, ok bool) {
+	switch {
+	case version < 840:
+		_, ok = messageTypeName[MessageType(raw)]
+		return MessageType(raw), ok
+	case version < 861:
+		t, ok = messageType84[raw]
+		return t, ok
+	}
+	return 0, false
+}
+
+// Skull is shown next to a creature's name.
+type Skull byte
+
+const (
+	SkullNone   Skull = 0
+	SkullYellow Skull = 1
+	SkullGreen  Skull = 2
+	SkullWhite  Skull = 3
+	SkullRed    Skull = 4
+	SkullBlack  Skull = 5 // Protocol 8.5 and later.
+)
+
+var skullName = map[Skull]string{
+	SkullNone:   "none",
+	SkullYellow: "yellow",
+	SkullGreen:  "green",
+	SkullWhite:  "white",
+	SkullRed:    "red",
+	SkullBlack:  "black",
+}
+
+func (s Skull) String() string { return enumName(skullName, s, "Skull") }
+
+// Shield shows a creature's party membership.
+type Shield byte
+
+const (
+	ShieldNone                   Shield = 0
+	ShieldWhiteYellow            Shield = 1 // Invited by the player.
+	ShieldWhiteBlue              Shield = 2 // Inviting the player.
+	ShieldBlue                   Shield = 3 // Party member.
+	ShieldYellow                 Shield = 4 // Party leader.
+	ShieldBlueSharedExp          Shield = 5 // Protocol 8.1 and later, as are the shared experience shields below.
+	ShieldYellowSharedExp        Shield = 6
+	ShieldBlueNoSharedExpBlink   Shield = 7
+	ShieldYellowNoSharedExpBlink Shield = 8
+	ShieldBlueNoSharedExp        Shield = 9
+	ShieldYellowNoSharedExp      Shield = 10
+)
+
+var shieldName = map[Shield]string{
+	ShieldNone:                   "none",
+	ShieldWhiteYellow:            "white yellow",
+	ShieldWhiteBlue:              "white blue",
+	ShieldBlue:                   "blue",
+	ShieldYellow:                 "yellow",
+	ShieldBlueSharedExp:          "blue shared exp",
+	ShieldYellowSharedExp:        "yellow shared exp",
+	ShieldBlueNoSharedExpBlink:   "blue no shared exp blink",
+	ShieldYellowNoSharedExpBlink: "yellow no shared exp blink",
+	ShieldBlueNoSharedExp:        "blue no shared exp",
+	ShieldYellowNoSharedExp:      "yellow no shared exp",
+}
+
+func (s Shield) String() string { return enumName(shieldName, s, "Shield") }
+
+// InventorySlot is an equipment slot of the player.
+type InventorySlot byte
+
+const (
+	SlotHead     InventorySlot = 1
+	SlotNecklace InventorySlot = 2
+	SlotBackpack InventorySlot = 3
+	SlotArmor    InventorySlot = 4
+	SlotRight    InventorySlot = 5
+	SlotLeft     InventorySlot = 6
+	SlotLegs     InventorySlot = 7
+	SlotFeet     InventorySlot = 8
+	SlotRing     InventorySlot = 9
+	SlotAmmo     InventorySlot = 10
+)
+
+var inventorySlotName = map[InventorySlot]string{
+	SlotHead:     "head",
+	SlotNecklace: "necklace",
+	SlotBackpack: "backpack",
+	SlotArmor:    "armor",
+	SlotRight:    "right hand",
+	SlotLeft:     "left hand",
+	SlotLegs:     "legs",
+	SlotFeet:     "feet",
+	SlotRing:     "ring",
+	SlotAmmo:     "ammo",
+}
+
+func (s InventorySlot) String() string { return enumName(inventorySlotName, s, "InventorySlot") }
+
+// Skill indexes PlayerSkills.Skills.
+type Skill int
+
+const (
+	SkillFist Skill = iota
+	SkillClub
+	SkillSword
+	SkillAxe
+	SkillDistance
+	SkillShielding
+	SkillFishing
+)
+
+var skillName = map[Skill]string{
+	SkillFist:      "fist",
+	SkillClub:      "club",
+	SkillSword:     "sword",
+	SkillAxe:       "axe",
+	SkillDistance:  "distance",
+	SkillShielding: "shielding",
+	SkillFishing:   "fishing",
+}
+
+func (s Skill) String() string { return enumName(skillName, s, "Skill") }
+
+// FluidColor is the SubType of splashes and fluid containers.
+// The protocol only carries the color, so e.g. water and mana fluid can't be told apart.
+type FluidColor byte
+
+const (
+	FluidEmpty  FluidColor = 0
+	FluidBlue   FluidColor = 1 // Water, mana fluid.
+	FluidRed    FluidColor = 2 // Blood, wine, life fluid.
+	FluidBrown  FluidColor = 3 // Beer, mud.
+	FluidGreen  FluidColor = 4 // Slime.
+	FluidYellow FluidColor = 5 // Urine, lemonade.
+	FluidWhite  FluidColor = 6 // Milk.
+	FluidPurple FluidColor = 7
+)
+
+var fluidColorName = map[FluidColor]string{
+	FluidEmpty:  "empty",
+	FluidBlue:   "blue",
+	FluidRed:    "red",
+	FluidBrown:  "brown",
+	FluidGreen:  "green",
+	FluidYellow: "yellow",
+	FluidWhite:  "white",
+	FluidPurple: "purple",
+}
+
+func (c FluidColor) String() string { return enumName(fluidColorName, c, "FluidColor") }
+
+// Icons is a bitmask of the player's conditions.
+type Icons byte
+
+const (
+	IconPoison Icons = 1 << iota
+	IconBurn
+	IconEnergy
+	IconDrunk
+	IconManaShield
+	IconParalyze
+	IconHaste
+	IconSwords // In battle.
+)
+
+var iconName = map[Icons]string{
+	IconPoison:     "poison",
+	IconBurn:       "burn",
+	IconEnergy:     "energy",
+	IconDrunk:      "drunk",
+	IconManaShield: "mana shield",
+	IconParalyze:   "paralyze",
+	IconHaste:      "haste",
+	IconSwords:     "swords",
+}
+
+// Has reports whether all icons in mask are set.
+func (i Icons) Has(mask Icons) bool { return i&mask == mask }
+
+// String lists the set icons, e.g. "poison|swords", or "none".
+func (i Icons) String() string {
+	if i == 0 {
+		return "none"
+	}
+	var names []string
+	for bit := Icons(1); bit != 0; bit <<= 1 {
+		if i.Has(bit) {
+			names = append(names, iconName[bit])
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func enumName[T ~byte | ~int](names map[T]string, v T, typ string) string {
+	if n, ok := names[v]; ok {
+		return n
+	}
+	return typ + "(" + strconv.Itoa(int(v)) + ")"
+}
+
+var directionName = map[Direction]string{
+	North: "north",
+	East:  "east",
+	South: "south",
+	West:  "west",
+	NE:    "northeast",
+	NW:    "northwest",
+	SE:    "southeast",
+	SW:    "southwest",
+}
+
+func (d Direction) String() string { return enumName(directionName, d, "Direction") }
@@ -0,0 +1,46 @@
+package data
+
+import "testing"
+
+func TestSpeakTypeFor(t *testing.T) {
+	for _, tc := range []struct {
+		version int
+		raw     byte
+		want    SpeakType
+		wantOK  bool
+	}{
+		{version: 772, raw: 0x04, want: SpeakPrivate, wantOK: true},
+		{version: 772, raw: 0x10, want: SpeakMonsterSay, wantOK: true},
+		{version: 772, raw: 0x0D, want: 0x0D, wantOK: false},
+		{version: 854, raw: 0x06, want: SpeakPrivate, wantOK: true},
+		{version: 854, raw: 0x13, want: SpeakMonsterSay, wantOK: true},
+		{version: 854, raw: 0x04, wantOK: false},
+		{version: 910, raw: 0x01, wantOK: false},
+	} {
+		got, ok := SpeakTypeFor(tc.version, tc.raw)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("SpeakTypeFor(%d, 0x%02X) = %v, %v; want %v, %v", tc.version, tc.raw, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestMessageTypeFor(t *testing.T) {
+	for _, tc := range []struct {
+		version int
+		raw     byte
+		want    MessageType
+		wantOK  bool
+	}{
+		{version: 772, raw: 0x16, want: MessageInfo, wantOK: true},
+		{version: 772, raw: 0x1A, want: 0x1A, wantOK: false},
+		{version: 854, raw: 0x19, want: MessageInfo, wantOK: true},
+		{version: 854, raw: 0x12, want: MessageConsoleRed, wantOK: true},
+		{version: 854, raw: 0x01, wantOK: false},
+		{version: 910, raw: 0x19, wantOK: false},
+	} {
+		got, ok := MessageTypeFor(tc.version, tc.raw)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("MessageTypeFor(%d, 0x%02X) = %v, %v; want %v, %v", tc.version, tc.raw, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -163,7 +163,7 @@ type ContainerItemRemove struct {
 type InventoryItemSet struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
-	Slot       byte
+	Slot       InventorySlot
 	Item       Item
 }
 
@@ -171,7 +171,7 @@ type InventoryItemSet struct {
 type InventoryItemClear struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
-	Slot       byte
+	Slot       InventorySlot
 }
 
 // TradeOwn (0x7D).
@@ -276,7 +276,7 @@ type CreatureSkull struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
 	CreatureID uint32
-	Skull      byte
+	Skull      Skull
 }
 
 // CreatureParty (0x91).
@@ -284,7 +284,7 @@ type CreatureParty struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
 	CreatureID uint32
-	Shield     byte
+	Shield     Shield
 }
 
 // PromptTextUpdate (0x96).
@@ -334,14 +334,14 @@ type SkillValue struct {
 type PlayerSkills struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
-	Skills     [7]SkillValue
+	Skills     [7]SkillValue // Indexed by Skill.
 }
 
 // PlayerIcons (0xA2).
 type PlayerIcons struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
-	Icons      byte
+	Icons      Icons
 }
 
 // TargetClear (0xA3).
@@ -356,9 +356,9 @@ type CreatureMessage struct {
 	PlayerPos   Location
 	StatementID uint32
 	Name        string
-	Type        byte
-	Location    *Location // for types 1,2,3,0x10,0x11
-	ChannelID   *uint16   // for types 5,6,0xA,0xC,0xE
+	Type        SpeakType
+	Location    *Location // if Type.HasLocation()
+	ChannelID   *uint16   // if Type.HasChannel()
 	Text        string
 }
 
@@ -436,7 +436,7 @@ type PrivateChannelClose struct {
 type Message struct {
 	TimeOffset time.Duration
 	PlayerPos  Location
-	Type       byte
+	Type       MessageType
 	Text       string
 }
 
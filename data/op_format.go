@@ -0,0 +1,419 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operations print as a one-line summary with %v and %s, e.g.
+//
+//	CreatureMessage say "Muzir" @ (33175,32524,7): "Here you are."
+//
+// and as a multi-line listing of all fields with %+v.
+
+func (l Location) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", l.X, l.Y, l.Z)
+}
+
+func (o Outfit) String() string {
+	switch {
+	case o.LookType != 0:
+		return fmt.Sprintf("outfit %d [%d %d %d %d]", o.LookType, o.Head, o.Body, o.Legs, o.Feet)
+	case o.LookItem != 0:
+		return fmt.Sprintf("outfit item %d", o.LookItem)
+	}
+	return "outfit invisible"
+}
+
+func (i Item) String() string {
+	s := "item " + strconv.Itoa(int(i.ID))
+	if i.Count != 0 {
+		s += " x" + strconv.Itoa(int(i.Count))
+	}
+	if i.SubType != 0 {
+		s += " " + i.SubType.String()
+	}
+	return s
+}
+
+func (c Creature) String() string {
+	s := "creature " + strconv.FormatUint(uint64(c.ID), 10)
+	if c.Name != "" {
+		s += fmt.Sprintf(" %q %d%%", c.Name, c.Health)
+	}
+	return s
+}
+
+func (t Thing) String() string {
+	switch {
+	case t.HasCreature:
+		return t.Creature.String()
+	case t.HasItem:
+		return t.Item.String()
+	}
+	return "nothing"
+}
+
+func (t Tile) String() string {
+	return t.Location.String() + ": " + joinThings(t.Things)
+}
+
+func joinThings(things []Thing) string {
+	if len(things) == 0 {
+		return "empty"
+	}
+	s := make([]string, len(things))
+	for i, th := range things {
+		s[i] = th.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+func tiles(name string, ts []Tile) string {
+	return name + " " + strconv.Itoa(len(ts)) + " tiles"
+}
+
+func stackPos(l Location, stack byte) string {
+	return l.String() + "#" + strconv.Itoa(int(stack))
+}
+
+func (o LoginPlayerState) String() string {
+	return fmt.Sprintf("LoginPlayerState player %d access %d", o.PlayerID, o.AccessLevel)
+}
+
+func (o LoginError) String() string { return fmt.Sprintf("LoginError %q", o.Message) }
+
+func (o LoginWaitList) String() string {
+	return fmt.Sprintf("LoginWaitList retry in %ds: %q", o.Time, o.Message)
+}
+
+func (o Ping) String() string { return "Ping" }
+
+func (o Map) String() string       { return tiles("Map", o.Tiles) }
+func (o MoveNorth) String() string { return tiles("MoveNorth", o.Tiles) }
+func (o MoveEast) String() string  { return tiles("MoveEast", o.Tiles) }
+func (o MoveSouth) String() string { return tiles("MoveSouth", o.Tiles) }
+func (o MoveWest) String() string  { return tiles("MoveWest", o.Tiles) }
+
+func (o TileUpdate) String() string {
+	if !o.HasTile {
+		return "TileUpdate @ " + o.Location.String() + " cleared"
+	}
+	return "TileUpdate @ " + o.Location.String() + ": " + joinThings(o.Tile.Things)
+}
+
+func (o TileItemAdd) String() string {
+	return "TileItemAdd @ " + o.Location.String() + ": " + o.Thing.String()
+}
+
+func (o TileItemUpdate) String() string {
+	return "TileItemUpdate @ " + stackPos(o.Location, o.StackIndex) + ": " + o.Thing.String()
+}
+
+func (o TileItemRemove) String() string {
+	return "TileItemRemove @ " + stackPos(o.Location, o.StackIndex)
+}
+
+func (o CreatureMove) String() string {
+	return "CreatureMove " + stackPos(o.OldLocation, o.OldStack) + " -> " + o.NewLocation.String()
+}
+
+func (o ContainerOpen) String() string {
+	s := fmt.Sprintf("ContainerOpen %d %q (item %d, %d/%d items", o.ContainerID, o.Name, o.ItemID, len(o.Items), o.Volume)
+	if o.HasParent != 0 {
+		s += ", has parent"
+	}
+	return s + ")"
+}
+
+func (o ContainerClose) String() string { return fmt.Sprintf("ContainerClose %d", o.ContainerID) }
+
+func (o ContainerItemAdd) String() string {
+	return fmt.Sprintf("ContainerItemAdd %d: %v", o.ContainerID, o.Thing)
+}
+
+func (o ContainerItemUpdate) String() string {
+	return fmt.Sprintf("ContainerItemUpdate %d#%d: %v", o.ContainerID, o.Slot, o.Thing)
+}
+
+func (o ContainerItemRemove) String() string {
+	return fmt.Sprintf("ContainerItemRemove %d#%d", o.ContainerID, o.Slot)
+}
+
+func (o InventoryItemSet) String() string {
+	return "InventoryItemSet " + o.Slot.String() + ": " + o.Item.String()
+}
+
+func (o InventoryItemClear) String() string { return "InventoryItemClear " + o.Slot.String() }
+
+func (o TradeOwn) String() string {
+	return fmt.Sprintf("TradeOwn %q: %s", o.Name, joinThings(o.Items))
+}
+
+func (o TradeCounter) String() string {
+	return fmt.Sprintf("TradeCounter %q: %s", o.Name, joinThings(o.Items))
+}
+
+func (o TradeClose) String() string { return "TradeClose" }
+
+func (o EffectLight) String() string {
+	return fmt.Sprintf("EffectLight level %d color %d", o.Level, o.Color)
+}
+
+func (o EffectGraphical) String() string {
+	return fmt.Sprintf("EffectGraphical %d @ %v", o.Effect, o.Location)
+}
+
+func (o EffectText) String() string {
+	return fmt.Sprintf("EffectText color %d @ %v: %q", o.Color, o.Location, o.Text)
+}
+
+func (o EffectMissile) String() string {
+	return fmt.Sprintf("EffectMissile %d %v -> %v", o.Effect, o.From, o.To)
+}
+
+func (o CreatureSquare) String() string {
+	return fmt.Sprintf("CreatureSquare creature %d color %d", o.CreatureID, o.Color)
+}
+
+func (o CreatureHealth) String() string {
+	return fmt.Sprintf("CreatureHealth creature %d %d%%", o.CreatureID, o.Health)
+}
+
+func (o CreatureLight) String() string {
+	return fmt.Sprintf("CreatureLight creature %d level %d color %d", o.CreatureID, o.Level, o.Color)
+}
+
+func (o CreatureOutfit) String() string {
+	return fmt.Sprintf("CreatureOutfit creature %d %v", o.CreatureID, o.Outfit)
+}
+
+func (o CreatureSpeed) String() string {
+	return fmt.Sprintf("CreatureSpeed creature %d speed %d", o.CreatureID, o.Speed)
+}
+
+func (o CreatureSkull) String() string {
+	return fmt.Sprintf("CreatureSkull creature %d %v", o.CreatureID, o.Skull)
+}
+
+func (o CreatureParty) String() string {
+	return fmt.Sprintf("CreatureParty creature %d %v", o.CreatureID, o.Shield)
+}
+
+func (o PromptTextUpdate) String() string {
+	return fmt.Sprintf("PromptTextUpdate window %d item %d max %d by %q: %q", o.WindowID, o.ItemID, o.MaxLen, o.Author, o.Text)
+}
+
+func (o PromptHouseList) String() string {
+	return fmt.Sprintf("PromptHouseList %d: %q", o.ID, o.Text)
+}
+
+func (o PlayerStats) String() string {
+	return fmt.Sprintf("PlayerStats hp %d/%d mana %d/%d cap %d exp %d level %d (%d%%) magic %d (%d%%) soul %d",
+		o.HP, o.MaxHP, o.Mana, o.MaxMana, o.Capacity, o.Exp, o.Level, o.LevelPct, o.MagicLvl, o.MagicLvlPct, o.Soul)
+}
+
+func (v SkillValue) String() string { return fmt.Sprintf("%d (%d%%)", v.Level, v.Percent) }
+
+func (o PlayerSkills) String() string {
+	s := make([]string, len(o.Skills))
+	for i, v := range o.Skills {
+		s[i] = Skill(i).String() + " " + v.String()
+	}
+	return "PlayerSkills " + strings.Join(s, ", ")
+}
+
+func (o PlayerIcons) String() string { return "PlayerIcons " + o.Icons.String() }
+
+func (o TargetClear) String() string { return "TargetClear" }
+
+func (o CreatureMessage) String() string {
+	s := fmt.Sprintf("CreatureMessage %v %q", o.Type, o.Name)
+	if o.Location != nil {
+		s += " @ " + o.Location.String()
+	}
+	if o.ChannelID != nil {
+		s += " #" + strconv.Itoa(int(*o.ChannelID))
+	}
+	return s + fmt.Sprintf(": %q", o.Text)
+}
+
+func (e ChannelEntry) String() string { return fmt.Sprintf("%d %q", e.ID, e.Name) }
+
+func (o ChannelList) String() string {
+	s := make([]string, len(o.Channels))
+	for i, c := range o.Channels {
+		s[i] = c.String()
+	}
+	return "ChannelList " + strings.Join(s, ", ")
+}
+
+func (o ChannelOpen) String() string { return fmt.Sprintf("ChannelOpen %d %q", o.ID, o.Name) }
+
+func (o PrivateChannelOpen) String() string { return fmt.Sprintf("PrivateChannelOpen %q", o.Name) }
+
+func (o RuleViolationsChannel) String() string {
+	return fmt.Sprintf("RuleViolationsChannel size %d", o.Size)
+}
+
+func (o RuleViolationsRemove) String() string {
+	return fmt.Sprintf("RuleViolationsRemove %q", o.Name)
+}
+
+func (o RuleViolationCancel) String() string { return fmt.Sprintf("RuleViolationCancel %q", o.Name) }
+
+func (o RuleViolationsLock) String() string { return "RuleViolationsLock" }
+
+func (o PrivateChannelCreate) String() string {
+	return fmt.Sprintf("PrivateChannelCreate %d %q", o.ID, o.Name)
+}
+
+func (o PrivateChannelClose) String() string {
+	return fmt.Sprintf("PrivateChannelClose %d", o.ChannelID)
+}
+
+func (o Message) String() string { return fmt.Sprintf("Message %v: %q", o.Type, o.Text) }
+
+func (o MoveCancel) String() string { return "MoveCancel " + o.Direction.String() }
+
+func (o MoveFloorUp) String() string   { return tiles("MoveFloorUp", o.Tiles) }
+func (o MoveFloorDown) String() string { return tiles("MoveFloorDown", o.Tiles) }
+
+func (o PromptChooseOutfit) String() string {
+	return fmt.Sprintf("PromptChooseOutfit %v choices %d-%d", o.Outfit, o.OutfitStart, o.OutfitEnd)
+}
+
+func (o VIPState) String() string {
+	state := "offline"
+	if o.Online != 0 {
+		state = "online"
+	}
+	return fmt.Sprintf("VIPState %d %q %s", o.ID, o.Name, state)
+}
+
+func (o VIPLogin) String() string  { return fmt.Sprintf("VIPLogin %d", o.ID) }
+func (o VIPLogout) String() string { return fmt.Sprintf("VIPLogout %d", o.ID) }
+
+func (o CamMetadata) String() string {
+	s := fmt.Sprintf("CamMetadata %q on %q for %v", o.PlayerName, o.ServerName, o.Duration)
+	if !o.LastVisit.IsZero() {
+		s += ", last visit " + o.LastVisit.Format(time.DateTime)
+	}
+	return s
+}
+
+// formatOp implements fmt.Formatter for operations.
+func formatOp(f fmt.State, verb rune, op interface {
+	Operation
+	fmt.Stringer
+}) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		writeVerbose(f, op)
+	case verb == 'v' || verb == 's':
+		io.WriteString(f, op.String())
+	case verb == 'q':
+		io.WriteString(f, strconv.Quote(op.String()))
+	default:
+		fmt.Fprintf(f, "%%!%c(%s)", verb, op.String())
+	}
+}
+
+// writeVerbose writes the operation name followed by its fields, one per line.
+// Slices and arrays list their elements on separate, further indented lines.
+func writeVerbose(w io.Writer, op Operation) {
+	v := reflect.ValueOf(op)
+	io.WriteString(w, v.Type().Name())
+	for i := range v.NumField() {
+		fmt.Fprintf(w, "\n  %s:", v.Type().Field(i).Name)
+		f := v.Field(i)
+		if f.Kind() != reflect.Slice && f.Kind() != reflect.Array {
+			io.WriteString(w, " "+verboseValue(f))
+			continue
+		}
+		for j := range f.Len() {
+			io.WriteString(w, "\n    - "+verboseValue(f.Index(j)))
+		}
+	}
+}
+
+func verboseValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "nil"
+		}
+		v = v.Elem()
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func (o LoginPlayerState) Format(f fmt.State, verb rune)      { formatOp(f, verb, o) }
+func (o LoginError) Format(f fmt.State, verb rune)            { formatOp(f, verb, o) }
+func (o LoginWaitList) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o Ping) Format(f fmt.State, verb rune)                  { formatOp(f, verb, o) }
+func (o Map) Format(f fmt.State, verb rune)                   { formatOp(f, verb, o) }
+func (o MoveNorth) Format(f fmt.State, verb rune)             { formatOp(f, verb, o) }
+func (o MoveEast) Format(f fmt.State, verb rune)              { formatOp(f, verb, o) }
+func (o MoveSouth) Format(f fmt.State, verb rune)             { formatOp(f, verb, o) }
+func (o MoveWest) Format(f fmt.State, verb rune)              { formatOp(f, verb, o) }
+func (o TileUpdate) Format(f fmt.State, verb rune)            { formatOp(f, verb, o) }
+func (o TileItemAdd) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o TileItemUpdate) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o TileItemRemove) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o CreatureMove) Format(f fmt.State, verb rune)          { formatOp(f, verb, o) }
+func (o ContainerOpen) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o ContainerClose) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o ContainerItemAdd) Format(f fmt.State, verb rune)      { formatOp(f, verb, o) }
+func (o ContainerItemUpdate) Format(f fmt.State, verb rune)   { formatOp(f, verb, o) }
+func (o ContainerItemRemove) Format(f fmt.State, verb rune)   { formatOp(f, verb, o) }
+func (o InventoryItemSet) Format(f fmt.State, verb rune)      { formatOp(f, verb, o) }
+func (o InventoryItemClear) Format(f fmt.State, verb rune)    { formatOp(f, verb, o) }
+func (o TradeOwn) Format(f fmt.State, verb rune)              { formatOp(f, verb, o) }
+func (o TradeCounter) Format(f fmt.State, verb rune)          { formatOp(f, verb, o) }
+func (o TradeClose) Format(f fmt.State, verb rune)            { formatOp(f, verb, o) }
+func (o EffectLight) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o EffectGraphical) Format(f fmt.State, verb rune)       { formatOp(f, verb, o) }
+func (o EffectText) Format(f fmt.State, verb rune)            { formatOp(f, verb, o) }
+func (o EffectMissile) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o CreatureSquare) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o CreatureHealth) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o CreatureLight) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o CreatureOutfit) Format(f fmt.State, verb rune)        { formatOp(f, verb, o) }
+func (o CreatureSpeed) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o CreatureSkull) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o CreatureParty) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o PromptTextUpdate) Format(f fmt.State, verb rune)      { formatOp(f, verb, o) }
+func (o PromptHouseList) Format(f fmt.State, verb rune)       { formatOp(f, verb, o) }
+func (o PlayerStats) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o PlayerSkills) Format(f fmt.State, verb rune)          { formatOp(f, verb, o) }
+func (o PlayerIcons) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o TargetClear) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o CreatureMessage) Format(f fmt.State, verb rune)       { formatOp(f, verb, o) }
+func (o ChannelList) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o ChannelOpen) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o PrivateChannelOpen) Format(f fmt.State, verb rune)    { formatOp(f, verb, o) }
+func (o RuleViolationsChannel) Format(f fmt.State, verb rune) { formatOp(f, verb, o) }
+func (o RuleViolationsRemove) Format(f fmt.State, verb rune)  { formatOp(f, verb, o) }
+func (o RuleViolationCancel) Format(f fmt.State, verb rune)   { formatOp(f, verb, o) }
+func (o RuleViolationsLock) Format(f fmt.State, verb rune)    { formatOp(f, verb, o) }
+func (o PrivateChannelCreate) Format(f fmt.State, verb rune)  { formatOp(f, verb, o) }
+func (o PrivateChannelClose) Format(f fmt.State, verb rune)   { formatOp(f, verb, o) }
+func (o Message) Format(f fmt.State, verb rune)               { formatOp(f, verb, o) }
+func (o MoveCancel) Format(f fmt.State, verb rune)            { formatOp(f, verb, o) }
+func (o MoveFloorUp) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
+func (o MoveFloorDown) Format(f fmt.State, verb rune)         { formatOp(f, verb, o) }
+func (o PromptChooseOutfit) Format(f fmt.State, verb rune)    { formatOp(f, verb, o) }
+func (o VIPState) Format(f fmt.State, verb rune)              { formatOp(f, verb, o) }
+func (o VIPLogin) Format(f fmt.State, verb rune)              { formatOp(f, verb, o) }
+func (o VIPLogout) Format(f fmt.State, verb rune)             { formatOp(f, verb, o) }
+func (o CamMetadata) Format(f fmt.State, verb rune)           { formatOp(f, verb, o) }
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOperation_Format(t *testing.T) {
+	loc := Location{X: 33175, Y: 32524, Z: 7}
+	for _, tc := range []struct {
+		format string
+		op     Operation
+		want   string
+	}{
+		{
+			format: "%v",
+			op:     CreatureMessage{TimeOffset: time.Minute, PlayerPos: loc, Name: "Muzir", Type: SpeakSay, Location: &loc, Text: "Here you are."},
+			want:   `CreatureMessage say "Muzir" @ (33175,32524,7): "Here you are."`,
+		},
+		{
+			format: "%s",
+			op:     TileItemAdd{Location: loc, Thing: Thing{HasItem: true, Item: Item{ID: 2886, SubType: FluidRed}}},
+			want:   `TileItemAdd @ (33175,32524,7): item 2886 red`,
+		},
+		{
+			format: "%v",
+			op:     TileUpdate{Location: loc, HasTile: true, Tile: Tile{Location: loc, Things: []Thing{{HasCreature: true, Creature: Creature{ID: 5, Name: "Rat", Health: 40}}}}},
+			want:   `TileUpdate @ (33175,32524,7): creature 5 "Rat" 40%`,
+		},
+		{
+			format: "%q",
+			op:     MoveCancel{Direction: West},
+			want:   `"MoveCancel west"`,
+		},
+		{
+			format: "%+v",
+			op: ChannelList{TimeOffset: time.Second, PlayerPos: loc, Channels: []ChannelEntry{
+				{ID: 4, Name: "Game-Chat"},
+				{ID: 5, Name: "Trade"},
+			}},
+			want: `ChannelList
+  TimeOffset: 1s
+  PlayerPos: (33175,32524,7)
+  Channels:
+    - 4 "Game-Chat"
+    - 5 "Trade"`,
+		},
+		{
+			format: "%+v",
+			op:     CreatureMessage{Name: "Vinzenz", Type: SpeakChannel, Text: "SELL"},
+			want: `CreatureMessage
+  TimeOffset: 0s
+  PlayerPos: (0,0,0)
+  StatementID: 0
+  Name: "Vinzenz"
+  Type: channel
+  Location: nil
+  ChannelID: nil
+  Text: "SELL"`,
+		},
+	} {
+		if diff := cmp.Diff(tc.want, fmt.Sprintf(tc.format, tc.op)); diff != "" {
+			t.Errorf("Sprintf(%q, %T) diff; -want +got:\n%v", tc.format, tc.op, diff)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package data
+
+import "time"
+
+// TypeOf returns the OpType of op.
+// It returns false for values that only implement Operation as parts of other operations (SkillValue, ChannelEntry).
+func TypeOf(op Operation) (OpType, bool) {
+	t, _, _, ok := header(op)
+	return t, ok
+}
+
+// TimeOffsetOf returns the TimeOffset of op. For CamMetadata, it returns the recording Duration.
+func TimeOffsetOf(op Operation) time.Duration {
+	_, offset, _, _ := header(op)
+	return offset
+}
+
+// PlayerPosOf returns the PlayerPos of op, or the zero Location if op doesn't carry one.
+func PlayerPosOf(op Operation) Location {
+	_, _, pos, _ := header(op)
+	return pos
+}
+
+// TilesOf returns the tiles carried by op: described map areas, or a single tile for tile updates.
+// A cleared tile carries no tiles; a thing added or updated on a tile is returned as a tile holding just that thing.
+func TilesOf(op Operation) []Tile {
+	switch o := op.(type) {
+	case Map:
+		return o.Tiles
+	case MoveNorth:
+		return o.Tiles
+	case MoveEast:
+		return o.Tiles
+	case MoveSouth:
+		return o.Tiles
+	case MoveWest:
+		return o.Tiles
+	case MoveFloorUp:
+		return o.Tiles
+	case MoveFloorDown:
+		return o.Tiles
+	case TileUpdate:
+		if o.HasTile {
+			return []Tile{o.Tile}
+		}
+	case TileItemAdd:
+		return []Tile{{Location: o.Location, Things: []Thing{o.Thing}}}
+	case TileItemUpdate:
+		return []Tile{{Location: o.Location, Things: []Thing{o.Thing}}}
+	}
+	return nil
+}
+
+func header(op Operation) (OpType, time.Duration, Location, bool) {
+	switch o := op.(type) {
+	case LoginPlayerState:
+		return TLoginPlayerState, o.TimeOffset, o.PlayerPos, true
+	case LoginError:
+		return TLoginError, o.TimeOffset, o.PlayerPos, true
+	case LoginWaitList:
+		return TLoginWaitList, o.TimeOffset, o.PlayerPos, true
+	case Ping:
+		return TPing, o.TimeOffset, o.PlayerPos, true
+	case Map:
+		return TMap, o.TimeOffset, o.PlayerPos, true
+	case MoveNorth:
+		return TMoveNorth, o.TimeOffset, o.PlayerPos, true
+	case MoveEast:
+		return TMoveEast, o.TimeOffset, o.PlayerPos, true
+	case MoveSouth:
+		return TMoveSouth, o.TimeOffset, o.PlayerPos, true
+	case MoveWest:
+		return TMoveWest, o.TimeOffset, o.PlayerPos, true
+	case TileUpdate:
+		return TTileUpdate, o.TimeOffset, o.PlayerPos, true
+	case TileItemAdd:
+		return TTileItemAdd, o.TimeOffset, o.PlayerPos, true
+	case TileItemUpdate:
+		return TTileItemUpdate, o.TimeOffset, o.PlayerPos, true
+	case TileItemRemove:
+		return TTileItemRemove, o.TimeOffset, o.PlayerPos, true
+	case CreatureMove:
+		return TCreatureMove, o.TimeOffset, o.PlayerPos, true
+	case ContainerOpen:
+		return TContainerOpen, o.TimeOffset, o.PlayerPos, true
+	case ContainerClose:
+		return TContainerClose, o.TimeOffset, o.PlayerPos, true
+	case ContainerItemAdd:
+		return TContainerItemAdd, o.TimeOffset, o.PlayerPos, true
+	case ContainerItemUpdate:
+		return TContainerItemUpdate, o.TimeOffset, o.PlayerPos, true
+	case ContainerItemRemove:
+		return TContainerItemRemove, o.TimeOffset, o.PlayerPos, true
+	case InventoryItemSet:
+		return TInventoryItemSet, o.TimeOffset, o.PlayerPos, true
+	case InventoryItemClear:
+		return TInventoryItemClear, o.TimeOffset, o.PlayerPos, true
+	case TradeOwn:
+		return TTradeOwn, o.TimeOffset, o.PlayerPos, true
+	case TradeCounter:
+		return TTradeCounter, o.TimeOffset, o.PlayerPos, true
+	case TradeClose:
+		return TTradeClose, o.TimeOffset, o.PlayerPos, true
+	case EffectLight:
+		return TEffectLight, o.TimeOffset, o.PlayerPos, true
+	case EffectGraphical:
+		return TEffectGraphical, o.TimeOffset, o.PlayerPos, true
+	case EffectText:
+		return TEffectText, o.TimeOffset, o.PlayerPos, true
+	case EffectMissile:
+		return TEffectMissile, o.TimeOffset, o.PlayerPos, true
+	case CreatureSquare:
+		return TCreatureSquare, o.TimeOffset, o.PlayerPos, true
+	case CreatureHealth:
+		return TCreatureHealth, o.TimeOffset, o.PlayerPos, true
+	case CreatureLight:
+		return TCreatureLight, o.TimeOffset, o.PlayerPos, true
+	case CreatureOutfit:
+		return TCreatureOutfit, o.TimeOffset, o.PlayerPos, true
+	case CreatureSpeed:
+		return TCreatureSpeed, o.TimeOffset, o.PlayerPos, true
+	case CreatureSkull:
+		return TCreatureSkull, o.TimeOffset, o.PlayerPos, true
+	case CreatureParty:
+		return TCreatureParty, o.TimeOffset, o.PlayerPos, true
+	case PromptTextUpdate:
+		return TPromptTextUpdate, o.TimeOffset, o.PlayerPos, true
+	case PromptHouseList:
+		return TPromptHouseList, o.TimeOffset, o.PlayerPos, true
+	case PlayerStats:
+		return TPlayerStats, o.TimeOffset, o.PlayerPos, true
+	case PlayerSkills:
+		return TPlayerSkills, o.TimeOffset, o.PlayerPos, true
+	case PlayerIcons:
+		return TPlayerIcons, o.TimeOffset, o.PlayerPos, true
+	case TargetClear:
+		return TTargetClear, o.TimeOffset, o.PlayerPos, true
+	case CreatureMessage:
+		return TCreatureMessage, o.TimeOffset, o.PlayerPos, true
+	case ChannelList:
+		return TChannelList, o.TimeOffset, o.PlayerPos, true
+	case ChannelOpen:
+		return TChannelOpen, o.TimeOffset, o.PlayerPos, true
+	case PrivateChannelOpen:
+		return TPrivateChannelOpen, o.TimeOffset, o.PlayerPos, true
+	case RuleViolationsChannel:
+		return TRuleViolationsChannel, o.TimeOffset, o.PlayerPos, true
+	case RuleViolationsRemove:
+		return TRuleViolationsRemove, o.TimeOffset, o.PlayerPos, true
+	case RuleViolationCancel:
+		return TRuleViolationCancel, o.TimeOffset, o.PlayerPos, true
+	case RuleViolationsLock:
+		return TRuleViolationsLock, o.TimeOffset, o.PlayerPos, true
+	case PrivateChannelCreate:
+		return TPrivateChannelCreate, o.TimeOffset, o.PlayerPos, true
+	case PrivateChannelClose:
+		return TPrivateChannelClose, o.TimeOffset, o.PlayerPos, true
+	case Message:
+		return TMessage, o.TimeOffset, o.PlayerPos, true
+	case MoveCancel:
+		return TMoveCancel, o.TimeOffset, o.PlayerPos, true
+	case MoveFloorUp:
+		return TMoveFloorUp, o.TimeOffset, o.PlayerPos, true
+	case MoveFloorDown:
+		return TMoveFloorDown, o.TimeOffset, o.PlayerPos, true
+	case PromptChooseOutfit:
+		return TPromptChooseOutfit, o.TimeOffset, o.PlayerPos, true
+	case VIPState:
+		return TVIPState, o.TimeOffset, o.PlayerPos, true
+	case VIPLogin:
+		return TVIPLogin, o.TimeOffset, o.PlayerPos, true
+	case VIPLogout:
+		return TVIPLogout, o.TimeOffset, o.PlayerPos, true
+	case CamMetadata:
+		return TCamMetadata, o.Duration, Location{}, true
+	}
+	return 0, 0, Location{}, false
+}
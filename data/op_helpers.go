@@ -39,8 +39,8 @@ type Outfit struct {
 // Item represents an in-game item.
 type Item struct {
 	ID      uint16
-	Count   byte // for stackable items
-	SubType byte // for splash/fluid container items
+	Count   byte       // for stackable items
+	SubType FluidColor // for splash/fluid container items
 }
 
 // Creature represents a creature (player, NPC, or monster).
@@ -54,8 +54,14 @@ type Creature struct {
 	LightLevel byte
 	LightColor byte
 	Speed      uint16
-	Skull      byte
-	Shield     byte
+	Skull      Skull
+	Shield     Shield
+}
+
+// IsPlayer reports whether c is a player. Servers assign monsters and NPCs IDs from 0x40000000 up,
+// and players lower ones.
+func (c Creature) IsPlayer() bool {
+	return c.ID < 0x40000000
 }
 
 // Thing represents either a Creature or an Item on a tile.
@@ -4,5 +4,6 @@ go 1.26.1
 
 require (
 	github.com/google/go-cmp v0.7.0
+	github.com/klauspost/compress v1.18.0
 	golang.org/x/text v0.37.0
 )
@@ -0,0 +1,122 @@
+// Package items resolves item names and attributes from OpenTibia items.otb and items.xml files.
+//
+// Recordings only carry client item IDs. items.otb maps them to server IDs, which items.xml describes.
+// Without an items.otb, server and client IDs are assumed equal, as on servers using the original item IDs.
+package items
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/s5i/tcam/data"
+)
+
+// Item describes a single item type.
+type Item struct {
+	ServerID uint16
+	ClientID uint16 // Zero if not known from an items.otb.
+
+	Name    string
+	Article string // E.g. "a" or "an", empty for names without one.
+	Plural  string
+
+	Weight     int               // In hundredths of an ounce.
+	Attributes map[string]string // Other items.xml attributes by key.
+}
+
+// DB holds item types by server ID.
+type DB struct {
+	items    map[uint16]*Item
+	serverID map[uint16]uint16 // By client ID, from items.otb.
+}
+
+// New initializes an empty DB.
+func New() *DB {
+	return &DB{items: map[uint16]*Item{}, serverID: map[uint16]uint16{}}
+}
+
+// Load reads the items.otb and items.xml files at the given paths. Either path may be empty.
+func Load(otbPath, xmlPath string) (*DB, error) {
+	db := New()
+	if otbPath != "" {
+		f, err := os.Open(otbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := db.ReadOTB(f); err != nil {
+			return nil, err
+		}
+	}
+	if xmlPath != "" {
+		f, err := os.Open(xmlPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := db.ReadXML(f); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// item returns the item with the given server ID, adding it if needed.
+func (db *DB) item(serverID uint16) *Item {
+	it, ok := db.items[serverID]
+	if !ok {
+		it = &Item{ServerID: serverID}
+		db.items[serverID] = it
+	}
+	return it
+}
+
+// ByServerID returns the item with the given server ID.
+func (db *DB) ByServerID(id uint16) (*Item, bool) {
+	it, ok := db.items[id]
+	return it, ok
+}
+
+// ByClientID returns the item with the given client ID, as found in recordings.
+func (db *DB) ByClientID(id uint16) (*Item, bool) {
+	if len(db.serverID) == 0 {
+		return db.ByServerID(id)
+	}
+	sid, ok := db.serverID[id]
+	if !ok {
+		return nil, false
+	}
+	return db.ByServerID(sid)
+}
+
+// Len returns the number of known items.
+func (db *DB) Len() int {
+	return len(db.items)
+}
+
+// Name returns the name of it, or its String form if the name isn't known.
+func (db *DB) Name(it data.Item) string {
+	if i, ok := db.ByClientID(it.ID); ok && i.Name != "" {
+		return i.Name
+	}
+	return it.String()
+}
+
+// Describe names it the way the game does, e.g. "a sword" or "57 gold coins".
+func (db *DB) Describe(it data.Item) string {
+	i, ok := db.ByClientID(it.ID)
+	if !ok || i.Name == "" {
+		return it.String()
+	}
+	if it.Count > 1 {
+		name := i.Plural
+		if name == "" {
+			name = i.Name
+		}
+		return strconv.Itoa(int(it.Count)) + " " + name
+	}
+	if i.Article != "" {
+		return i.Article + " " + i.Name
+	}
+	return i.Name
+}
@@ -0,0 +1,125 @@
+package items_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/items"
+)
+
+// otb builds an items.otb with one node per server ID to client ID mapping.
+func otb(ids [][2]uint16) []byte {
+	var node []byte
+	escaped := func(b ...byte) {
+		for _, c := range b {
+			if c >= 0xFD {
+				node = append(node, 0xFD)
+			}
+			node = append(node, c)
+		}
+	}
+	attr := func(kind byte, v uint16) {
+		escaped(kind)
+		escaped(binary.LittleEndian.AppendUint16(nil, 2)...)
+		escaped(binary.LittleEndian.AppendUint16(nil, v)...)
+	}
+
+	node = append(node, 0, 0, 0, 0, 0xFE, 0)
+	// Root flags and version attribute.
+	escaped(0, 0, 0, 0, 0x01, 12, 0)
+	escaped(make([]byte, 12)...)
+	for _, id := range ids {
+		node = append(node, 0xFE, 0)
+		escaped(0, 0, 0, 0)
+		attr(0x10, id[0])
+		attr(0x11, id[1])
+		node = append(node, 0xFF)
+	}
+	return append(node, 0xFF)
+}
+
+const itemsXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<items>
+	<item id="2148" article="a" name="gold coin" plural="gold coins">
+		<attribute key="weight" value="10"/>
+	</item>
+	<item id="2376" article="a" name="sword">
+		<attribute key="weight" value="3500"/>
+		<attribute key="attack" value="14"/>
+	</item>
+	<item fromid="254" toid="255" name="` + "\xe6ther" + `"/>
+</items>`
+
+func TestDB(t *testing.T) {
+	db := items.New()
+	if err := db.ReadOTB(bytes.NewReader(otb([][2]uint16{
+		{2148, 3031},
+		{2376, 3264},
+		{254, 253},
+		{255, 253},
+	}))); err != nil {
+		t.Fatalf("ReadOTB() err = %v", err)
+	}
+	if err := db.ReadXML(strings.NewReader(itemsXML)); err != nil {
+		t.Fatalf("ReadXML() err = %v", err)
+	}
+
+	got, ok := db.ByClientID(3264)
+	if !ok {
+		t.Fatalf("ByClientID(3264) not found")
+	}
+	want := &items.Item{
+		ServerID:   2376,
+		ClientID:   3264,
+		Name:       "sword",
+		Article:    "a",
+		Weight:     3500,
+		Attributes: map[string]string{"attack": "14"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ByClientID(3264) diff; -want +got:\n%v", diff)
+	}
+
+	for _, tc := range []struct {
+		item           data.Item
+		name, describe string
+	}{
+		{item: data.Item{ID: 3031, Count: 57}, name: "gold coin", describe: "57 gold coins"},
+		{item: data.Item{ID: 3031, Count: 1}, name: "gold coin", describe: "a gold coin"},
+		{item: data.Item{ID: 3264}, name: "sword", describe: "a sword"},
+		// Client IDs shared by several server IDs resolve to the first.
+		{item: data.Item{ID: 253}, name: "æther", describe: "æther"},
+		{item: data.Item{ID: 2148}, name: "item 2148", describe: "item 2148"},
+	} {
+		if got := db.Name(tc.item); got != tc.name {
+			t.Errorf("Name(%v) = %q; want %q", tc.item, got, tc.name)
+		}
+		if got := db.Describe(tc.item); got != tc.describe {
+			t.Errorf("Describe(%v) = %q; want %q", tc.item, got, tc.describe)
+		}
+	}
+}
+
+func TestDB_WithoutOTB(t *testing.T) {
+	db := items.New()
+	if err := db.ReadXML(strings.NewReader(itemsXML)); err != nil {
+		t.Fatalf("ReadXML() err = %v", err)
+	}
+	if got, want := db.Len(), 4; got != want {
+		t.Errorf("Len() = %d; want %d", got, want)
+	}
+	if got, want := db.Describe(data.Item{ID: 2148, Count: 2}), "2 gold coins"; got != want {
+		t.Errorf("Describe() = %q; want %q", got, want)
+	}
+}
+
+func TestDB_ReadOTB_Truncated(t *testing.T) {
+	b := otb([][2]uint16{{2148, 3031}})
+	if err := items.New().ReadOTB(bytes.NewReader(b[:len(b)-1])); err == nil {
+		t.Errorf("ReadOTB() err = nil; want an error")
+	}
+}
@@ -0,0 +1,124 @@
+package items
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	otbEscape    = 0xFD
+	otbNodeStart = 0xFE
+	otbNodeEnd   = 0xFF
+
+	otbAttrServerID = 0x10
+	otbAttrClientID = 0x11
+	otbAttrName     = 0x12 // Only in old files.
+)
+
+var errOTBTruncated = errors.New("items: otb: truncated file")
+
+// otbNode is a node of the OpenTibia binary tree format, with escapes removed from data.
+type otbNode struct {
+	kind     byte
+	data     []byte
+	children []otbNode
+}
+
+// ReadOTB reads the client ID mapping from an items.otb file.
+// If several server IDs share a client ID, the first one is used.
+func (db *DB) ReadOTB(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// The root node follows a 4-byte identifier.
+	if len(b) < 5 || b[4] != otbNodeStart {
+		return errOTBTruncated
+	}
+	root, _, err := readOTBNode(b, 4)
+	if err != nil {
+		return err
+	}
+
+	for i, n := range root.children {
+		sid, cid, name, err := readOTBItem(n.data)
+		if err != nil {
+			return fmt.Errorf("items: otb: item node %d: %w", i, err)
+		}
+		if sid == 0 {
+			continue
+		}
+		it := db.item(sid)
+		it.ClientID = cid
+		if it.Name == "" {
+			it.Name = name
+		}
+		if _, ok := db.serverID[cid]; !ok && cid != 0 {
+			db.serverID[cid] = sid
+		}
+	}
+	return nil
+}
+
+// readOTBNode reads the node starting at b[pos] and returns it with the position following it.
+func readOTBNode(b []byte, pos int) (otbNode, int, error) {
+	pos++
+	if pos >= len(b) {
+		return otbNode{}, 0, errOTBTruncated
+	}
+	n := otbNode{kind: b[pos]}
+	pos++
+	for pos < len(b) {
+		switch b[pos] {
+		case otbEscape:
+			if pos+1 >= len(b) {
+				return otbNode{}, 0, errOTBTruncated
+			}
+			n.data = append(n.data, b[pos+1])
+			pos += 2
+		case otbNodeStart:
+			child, next, err := readOTBNode(b, pos)
+			if err != nil {
+				return otbNode{}, 0, err
+			}
+			n.children = append(n.children, child)
+			pos = next
+		case otbNodeEnd:
+			return n, pos + 1, nil
+		default:
+			n.data = append(n.data, b[pos])
+			pos++
+		}
+	}
+	return otbNode{}, 0, errOTBTruncated
+}
+
+// readOTBItem reads the attributes of an item node, which follow 4 bytes of flags.
+func readOTBItem(d []byte) (serverID, clientID uint16, name string, err error) {
+	if len(d) < 4 {
+		return 0, 0, "", errors.New("missing flags")
+	}
+	d = d[4:]
+	for len(d) > 0 {
+		if len(d) < 3 {
+			return 0, 0, "", errors.New("truncated attribute")
+		}
+		attr, size := d[0], int(binary.LittleEndian.Uint16(d[1:3]))
+		if len(d) < 3+size {
+			return 0, 0, "", fmt.Errorf("attribute 0x%02X: size %d exceeds node", attr, size)
+		}
+		val := d[3 : 3+size]
+		switch {
+		case attr == otbAttrServerID && size == 2:
+			serverID = binary.LittleEndian.Uint16(val)
+		case attr == otbAttrClientID && size == 2:
+			clientID = binary.LittleEndian.Uint16(val)
+		case attr == otbAttrName:
+			name = string(val)
+		}
+		d = d[3+size:]
+	}
+	return serverID, clientID, name, nil
+}
@@ -0,0 +1,104 @@
+package items
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+type xmlItems struct {
+	Items []xmlItem `xml:"item"`
+}
+
+type xmlItem struct {
+	ID         string         `xml:"id,attr"`
+	FromID     string         `xml:"fromid,attr"`
+	ToID       string         `xml:"toid,attr"`
+	Name       string         `xml:"name,attr"`
+	Article    string         `xml:"article,attr"`
+	Plural     string         `xml:"plural,attr"`
+	Attributes []xmlAttribute `xml:"attribute"`
+}
+
+type xmlAttribute struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// ReadXML reads item names and attributes from an items.xml file.
+// Entries may cover a single server ID (id) or a range of them (fromid, toid).
+func (db *DB) ReadXML(r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	// Server distributions commonly use ISO-8859-1.
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := ianaindex.IANA.Encoding(label)
+		if err != nil {
+			return nil, err
+		}
+		if enc == nil {
+			return nil, fmt.Errorf("unsupported charset %q", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	var doc xmlItems
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("items: xml: %w", err)
+	}
+
+	for _, x := range doc.Items {
+		from, to, err := x.ids()
+		if err != nil {
+			return fmt.Errorf("items: xml: item %q: %w", x.Name, err)
+		}
+		for id := from; id <= to; id++ {
+			if err := db.addXML(uint16(id), x); err != nil {
+				return fmt.Errorf("items: xml: item %d: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (x xmlItem) ids() (from, to uint64, err error) {
+	if x.ID != "" {
+		id, err := strconv.ParseUint(x.ID, 10, 16)
+		return id, id, err
+	}
+	if from, err = strconv.ParseUint(x.FromID, 10, 16); err != nil {
+		return 0, 0, err
+	}
+	if to, err = strconv.ParseUint(x.ToID, 10, 16); err != nil {
+		return 0, 0, err
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("toid %d is below fromid %d", to, from)
+	}
+	return from, to, nil
+}
+
+func (db *DB) addXML(id uint16, x xmlItem) error {
+	it := db.item(id)
+	it.Name = x.Name
+	it.Article = x.Article
+	it.Plural = x.Plural
+	for _, a := range x.Attributes {
+		key := strings.ToLower(a.Key)
+		if key == "weight" {
+			w, err := strconv.Atoi(a.Value)
+			if err != nil {
+				return fmt.Errorf("weight: %w", err)
+			}
+			it.Weight = w
+			continue
+		}
+		if it.Attributes == nil {
+			it.Attributes = map[string]string{}
+		}
+		it.Attributes[key] = a.Value
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // Keywords, identifiers, numbers and durations.
+	tokString           // Double-quoted, with Go escapes.
+	tokPunct            // Parentheses, commas and comparison operators.
+)
+
+type token struct {
+	kind tokenKind
+	text string // Unquoted for tokString.
+	pos  int    // Byte offset in the expression.
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// is reports whether t is the given word (case-insensitive) or punctuation.
+func (t token) is(s string) bool {
+	return t.kind != tokString && strings.EqualFold(t.text, s)
+}
+
+var operators = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">", "(", ")", ","}
+
+func lex(expr string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(expr); {
+		c, size := utf8.DecodeRuneInString(expr[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, &Error{Pos: i, Msg: "unterminated string"}
+			}
+			s, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, &Error{Pos: i, Msg: "invalid string: " + err.Error()}
+			}
+			toks = append(toks, token{kind: tokString, text: s, pos: i})
+			i = end + 1
+
+		case isWordChar(c):
+			end := i
+			// Word characters are ASCII, so they never start or continue a multi-byte rune.
+			for end < len(expr) && isWordChar(rune(expr[end])) {
+				end++
+			}
+			toks = append(toks, token{kind: tokWord, text: expr[i:end], pos: i})
+			i = end
+
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			toks = append(toks, token{kind: tokPunct, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(expr)}), nil
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || c == '.' || c == '-' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))
+}
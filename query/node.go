@@ -0,0 +1,217 @@
+package query
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+const (
+	minDuration time.Duration = math.MinInt64
+	maxDuration time.Duration = math.MaxInt64
+)
+
+type node interface {
+	match(op data.Operation) bool
+	// types returns the operation types the node can match, or nil for any.
+	types() map[data.OpType]bool
+}
+
+type orNode [2]node
+
+func (n orNode) match(op data.Operation) bool { return n[0].match(op) || n[1].match(op) }
+
+func (n orNode) types() map[data.OpType]bool {
+	l, r := n[0].types(), n[1].types()
+	if l == nil || r == nil {
+		return nil
+	}
+	u := map[data.OpType]bool{}
+	for t := range l {
+		u[t] = true
+	}
+	for t := range r {
+		u[t] = true
+	}
+	return u
+}
+
+type andNode [2]node
+
+func (n andNode) match(op data.Operation) bool { return n[0].match(op) && n[1].match(op) }
+
+func (n andNode) types() map[data.OpType]bool {
+	l, r := n[0].types(), n[1].types()
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	}
+	i := map[data.OpType]bool{}
+	for t := range l {
+		if r[t] {
+			i[t] = true
+		}
+	}
+	return i
+}
+
+type notNode struct{ n node }
+
+func (n notNode) match(op data.Operation) bool { return !n.n.match(op) }
+func (n notNode) types() map[data.OpType]bool  { return nil }
+
+type typeNode map[data.OpType]bool
+
+func (n typeNode) match(op data.Operation) bool {
+	t, ok := data.TypeOf(op)
+	return ok && n[t]
+}
+
+func (n typeNode) types() map[data.OpType]bool { return n }
+
+type speakNode map[data.SpeakType]bool
+
+func (n speakNode) match(op data.Operation) bool {
+	msg, ok := op.(data.CreatureMessage)
+	return ok && n[msg.Type]
+}
+
+func (n speakNode) types() map[data.OpType]bool {
+	return map[data.OpType]bool{data.TCreatureMessage: true}
+}
+
+type nearNode struct {
+	loc    data.Location
+	radius int
+}
+
+func (n nearNode) match(op data.Operation) bool {
+	pos := data.PlayerPosOf(op)
+	return pos != (data.Location{}) && pos.Z == n.loc.Z &&
+		max(pos.X-n.loc.X, n.loc.X-pos.X) <= n.radius &&
+		max(pos.Y-n.loc.Y, n.loc.Y-pos.Y) <= n.radius
+}
+
+func (n nearNode) types() map[data.OpType]bool { return nil }
+
+// timeNode matches operations between from and to. Bounds are inclusive unless marked as open, as for < and >,
+// which can't be expressed by moving them by 1 at the limits of time.Duration.
+type timeNode struct {
+	from, to         time.Duration
+	openFrom, openTo bool
+}
+
+func (n timeNode) match(op data.Operation) bool {
+	d := data.TimeOffsetOf(op)
+	if n.openFrom && d == n.from || n.openTo && d == n.to {
+		return false
+	}
+	return d >= n.from && d <= n.to
+}
+
+func (n timeNode) types() map[data.OpType]bool { return nil }
+
+// stringNode matches if any of the values an operation holds matches.
+type stringNode struct {
+	values  func(data.Operation) []string
+	typeSet map[data.OpType]bool
+	pred    func(string) bool
+}
+
+func (n stringNode) match(op data.Operation) bool {
+	return slices.ContainsFunc(n.values(op), n.pred)
+}
+
+func (n stringNode) types() map[data.OpType]bool { return n.typeSet }
+
+var textTypes = map[data.OpType]bool{
+	data.TLoginError:       true,
+	data.TLoginWaitList:    true,
+	data.TEffectText:       true,
+	data.TPromptTextUpdate: true,
+	data.TPromptHouseList:  true,
+	data.TCreatureMessage:  true,
+	data.TMessage:          true,
+}
+
+func textOf(op data.Operation) []string {
+	switch op := op.(type) {
+	case data.LoginError:
+		return []string{op.Message}
+	case data.LoginWaitList:
+		return []string{op.Message}
+	case data.EffectText:
+		return []string{op.Text}
+	case data.PromptTextUpdate:
+		return []string{op.Text}
+	case data.PromptHouseList:
+		return []string{op.Text}
+	case data.CreatureMessage:
+		return []string{op.Text}
+	case data.Message:
+		return []string{op.Text}
+	}
+	return nil
+}
+
+var nameTypes = map[data.OpType]bool{
+	data.TTradeOwn:             true,
+	data.TTradeCounter:         true,
+	data.TPromptTextUpdate:     true,
+	data.TCreatureMessage:      true,
+	data.TPrivateChannelOpen:   true,
+	data.TRuleViolationsRemove: true,
+	data.TRuleViolationCancel:  true,
+	data.TVIPState:             true,
+}
+
+func nameOf(op data.Operation) []string {
+	switch op := op.(type) {
+	case data.TradeOwn:
+		return []string{op.Name}
+	case data.TradeCounter:
+		return []string{op.Name}
+	case data.PromptTextUpdate:
+		return []string{op.Author}
+	case data.CreatureMessage:
+		return []string{op.Name}
+	case data.PrivateChannelOpen:
+		return []string{op.Name}
+	case data.RuleViolationsRemove:
+		return []string{op.Name}
+	case data.RuleViolationCancel:
+		return []string{op.Name}
+	case data.VIPState:
+		return []string{op.Name}
+	}
+	return nil
+}
+
+var tileTypes = map[data.OpType]bool{
+	data.TMap:            true,
+	data.TMoveNorth:      true,
+	data.TMoveEast:       true,
+	data.TMoveSouth:      true,
+	data.TMoveWest:       true,
+	data.TTileUpdate:     true,
+	data.TTileItemAdd:    true,
+	data.TTileItemUpdate: true,
+	data.TMoveFloorUp:    true,
+	data.TMoveFloorDown:  true,
+}
+
+func creatureNamesOf(op data.Operation) []string {
+	var names []string
+	for _, tile := range data.TilesOf(op) {
+		for _, t := range tile.Things {
+			if t.HasCreature && t.Creature.Name != "" {
+				names = append(names, t.Creature.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,309 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/tcam/data"
+)
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is s.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if t := p.next(); !t.is(s) {
+		return &Error{Pos: t.pos, Msg: fmt.Sprintf("expected %q, got %v", s, t)}
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		n = orNode{n, r}
+	}
+	return n, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		n = andNode{n, r}
+	}
+	return n, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("not") {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	if p.accept("(") {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (node, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected a predicate, got %v", t)}
+	}
+	switch strings.ToLower(t.text) {
+	case "type":
+		return p.parseType()
+	case "near":
+		return p.parseNear()
+	case "time":
+		return p.parseTime()
+	case "text":
+		return p.parseString(textOf, textTypes)
+	case "name":
+		return p.parseString(nameOf, nameTypes)
+	case "creature":
+		return p.parseString(creatureNamesOf, tileTypes)
+	case "speak":
+		return p.parseSpeak()
+	}
+	return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unknown predicate %v", t)}
+}
+
+// parseNames reads "= name", "!= name" or "in (name, ...)", returning the names and whether they are negated.
+func (p *parser) parseNames() (names []token, negate bool, err error) {
+	switch t := p.next(); {
+	case t.is("="), t.is("!="):
+		n := p.next()
+		if n.kind != tokWord && n.kind != tokString {
+			return nil, false, &Error{Pos: n.pos, Msg: fmt.Sprintf("expected a name, got %v", n)}
+		}
+		return []token{n}, t.is("!="), nil
+	case t.is("in"):
+		if err := p.expect("("); err != nil {
+			return nil, false, err
+		}
+		for {
+			n := p.next()
+			if n.kind != tokWord && n.kind != tokString {
+				return nil, false, &Error{Pos: n.pos, Msg: fmt.Sprintf("expected a name, got %v", n)}
+			}
+			names = append(names, n)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return names, false, p.expect(")")
+	default:
+		return nil, false, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected =, != or in, got %v", t)}
+	}
+}
+
+var opTypes = func() map[string]data.OpType {
+	m := map[string]data.OpType{}
+	for t, name := range data.OpName {
+		m[strings.ToLower(name)] = t
+	}
+	return m
+}()
+
+func (p *parser) parseType() (node, error) {
+	names, negate, err := p.parseNames()
+	if err != nil {
+		return nil, err
+	}
+	n := typeNode{}
+	for _, name := range names {
+		t, ok := opTypes[strings.ToLower(name.text)]
+		if !ok {
+			return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("unknown operation type %v", name)}
+		}
+		n[t] = true
+	}
+	if negate {
+		return notNode{n}, nil
+	}
+	return n, nil
+}
+
+var speakTypes = func() map[string]data.SpeakType {
+	m := map[string]data.SpeakType{}
+	for t := range data.SpeakType(0xFF) {
+		if name := t.String(); !strings.HasPrefix(name, "SpeakType(") {
+			m[name] = t
+		}
+	}
+	return m
+}()
+
+func (p *parser) parseSpeak() (node, error) {
+	names, negate, err := p.parseNames()
+	if err != nil {
+		return nil, err
+	}
+	n := speakNode{}
+	for _, name := range names {
+		t, ok := speakTypes[strings.ToLower(name.text)]
+		if !ok {
+			return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("unknown speak type %v", name)}
+		}
+		n[t] = true
+	}
+	if negate {
+		// Still only matches CreatureMessages.
+		return andNode{typeNode{data.TCreatureMessage: true}, notNode{n}}, nil
+	}
+	return n, nil
+}
+
+func (p *parser) parseInt() (int, error) {
+	t := p.next()
+	n, err := strconv.Atoi(t.text)
+	if t.kind != tokWord || err != nil {
+		return 0, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected an integer, got %v", t)}
+	}
+	return n, nil
+}
+
+func (p *parser) parseNear() (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args [4]int
+	for i := range args {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		args[i] = n
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return nearNode{loc: data.Location{X: args[0], Y: args[1], Z: args[2]}, radius: args[3]}, nil
+}
+
+func (p *parser) parseDuration() (time.Duration, error) {
+	t := p.next()
+	d, err := time.ParseDuration(t.text)
+	if t.kind != tokWord || err != nil {
+		return 0, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected a duration, got %v", t)}
+	}
+	return d, nil
+}
+
+func (p *parser) parseTime() (node, error) {
+	if p.accept("between") {
+		from, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		to, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		return timeNode{from: from, to: to}, nil
+	}
+
+	t := p.next()
+	d, err := p.parseDuration()
+	if err != nil {
+		return nil, err
+	}
+	switch t.text {
+	case "=":
+		return timeNode{from: d, to: d}, nil
+	case "<":
+		return timeNode{from: minDuration, to: d, openTo: true}, nil
+	case "<=":
+		return timeNode{from: minDuration, to: d}, nil
+	case ">":
+		return timeNode{from: d, to: maxDuration, openFrom: true}, nil
+	case ">=":
+		return timeNode{from: d, to: maxDuration}, nil
+	}
+	return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected between or a comparison, got %v", t)}
+}
+
+func (p *parser) parseString(values func(data.Operation) []string, types map[data.OpType]bool) (node, error) {
+	t := p.next()
+	v := p.next()
+	if v.kind != tokString {
+		return nil, &Error{Pos: v.pos, Msg: fmt.Sprintf("expected a string, got %v", v)}
+	}
+
+	n := stringNode{values: values, typeSet: types}
+	switch {
+	case t.is("="), t.is("!="):
+		n.pred = func(s string) bool { return s == v.text }
+	case t.is("~"), t.is("!~"):
+		sub := strings.ToLower(v.text)
+		n.pred = func(s string) bool { return strings.Contains(strings.ToLower(s), sub) }
+	case t.is("matches"):
+		re, err := regexp.Compile(v.text)
+		if err != nil {
+			return nil, &Error{Pos: v.pos, Msg: err.Error()}
+		}
+		n.pred = re.MatchString
+	default:
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected =, !=, ~, !~ or matches, got %v", t)}
+	}
+	if t.is("!=") || t.is("!~") {
+		// Negated comparisons still require a value to compare.
+		return andNode{typeNode(types), notNode{n}}, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,102 @@
+// Package query implements a small expression language for filtering data.Operations.
+//
+// A query combines predicates with and, or, not and parentheses; and binds tighter than or.
+// Keywords and type names are case-insensitive.
+//
+//	type in (CreatureMessage, Message)    operation type, also type = T and type != T
+//	near(x, y, z, r)                      player on floor z within r tiles of (x, y)
+//	time between 5m and 10m               TimeOffset, bounds included; also time < 1h etc.
+//	text ~ "hi"                           chat, server message, effect or prompt text
+//	name = "Muzir"                        speaker, VIP, trade partner or other named player
+//	creature ~ "rat"                      any creature on the tiles an operation describes
+//	speak in (say, "monster say")         CreatureMessage type, also speak = T
+//
+// Strings compare with = and != (exact), ~ and !~ (case-insensitive substring), or
+// matches (regular expression). Durations use time.ParseDuration syntax.
+//
+// For example:
+//
+//	type in (CreatureMessage, Message) and near(33175, 32524, 7, 7) and time between 5m and 10m and text ~ "hi"
+package query
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+
+	"github.com/s5i/tcam/data"
+)
+
+// Query is a compiled expression.
+type Query struct {
+	expr string
+	root node
+}
+
+// Error is a syntax error in a query.
+type Error struct {
+	Pos int // Byte offset in the expression.
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query: at %d: %s", e.Pos, e.Msg)
+}
+
+// Compile parses a query expression.
+func Compile(expr string) (*Query, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %v", t)}
+	}
+	return &Query{expr: expr, root: root}, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string) *Query {
+	q, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Match reports whether op satisfies the query.
+func (q *Query) Match(op data.Operation) bool {
+	return q.root.match(op)
+}
+
+// Types returns the operation types the query can match, for use as cam.ParseOpts.TFilter.
+// It returns nil if the query can match any type.
+func (q *Query) Types() map[data.OpType]bool {
+	types := q.root.types()
+	if types == nil {
+		return nil
+	}
+	return maps.Clone(types)
+}
+
+// Filter returns the operations from ops that satisfy the query. Errors are passed through.
+func (q *Query) Filter(ops iter.Seq2[data.Operation, error]) iter.Seq2[data.Operation, error] {
+	return func(yield func(data.Operation, error) bool) {
+		for op, err := range ops {
+			if err != nil || q.Match(op) {
+				if !yield(op, err) {
+					return
+				}
+			}
+		}
+	}
+}
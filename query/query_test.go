@@ -0,0 +1,140 @@
+package query_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/query"
+)
+
+const testdata = "../cam/testdata"
+
+func TestQuery_Match(t *testing.T) {
+	near := data.Location{X: 33175, Y: 32524, Z: 7}
+	far := data.Location{X: 33200, Y: 32524, Z: 7}
+	ops := map[string]data.Operation{
+		"hi":      data.CreatureMessage{TimeOffset: 6 * time.Minute, PlayerPos: near, Name: "Muzir", Type: data.SpeakSay, Text: "Hi there"},
+		"far":     data.CreatureMessage{TimeOffset: 6 * time.Minute, PlayerPos: far, Name: "Muzir", Type: data.SpeakSay, Text: "hi"},
+		"early":   data.Message{TimeOffset: time.Minute, PlayerPos: near, Type: data.MessageInfo, Text: "hi"},
+		"channel": data.CreatureMessage{TimeOffset: 7 * time.Minute, PlayerPos: near, Name: "Sheila", Type: data.SpeakChannel, Text: "BUY"},
+		"map": data.Map{TimeOffset: 8 * time.Minute, PlayerPos: near, Tiles: []data.Tile{{Things: []data.Thing{
+			{HasCreature: true, Creature: data.Creature{ID: 1, Name: "Rat"}},
+		}}}},
+	}
+
+	for _, tc := range []struct {
+		expr string
+		want []string
+	}{
+		{
+			expr: `type in (CreatureMessage, Message) and near(33175,32524,7, 7) and time between 5m and 10m and text ~ "hi"`,
+			want: []string{"hi"},
+		},
+		{expr: `type = map`, want: []string{"map"}},
+		{expr: `type != Map and text = "hi"`, want: []string{"early", "far"}},
+		{expr: `not near(33175, 32524, 7, 7)`, want: []string{"far"}},
+		{expr: `time < 2m or time >= 8m`, want: []string{"early", "map"}},
+		{expr: `time > 6m`, want: []string{"channel", "map"}},
+		{expr: `time > 2562047h47m16.854775807s or time < -2562047h47m16.854775808s`, want: nil},
+		{expr: `name = "Muzir" and (speak = say or speak in (channel, "monster say"))`, want: []string{"far", "hi"}},
+		{expr: `speak != say`, want: []string{"channel"}},
+		{expr: `name !~ "muz"`, want: []string{"channel"}},
+		{expr: `text matches "^[A-Z]+$"`, want: []string{"channel"}},
+		{expr: `creature ~ "RAT"`, want: []string{"map"}},
+	} {
+		q, err := query.Compile(tc.expr)
+		if err != nil {
+			t.Errorf("Compile(%q) err = %v", tc.expr, err)
+			continue
+		}
+		var got []string
+		for _, name := range []string{"channel", "early", "far", "hi", "map"} {
+			if q.Match(ops[name]) {
+				got = append(got, name)
+			}
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Compile(%q).Match() diff; -want +got:\n%v", tc.expr, diff)
+		}
+	}
+}
+
+func TestQuery_Types(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want map[data.OpType]bool
+	}{
+		{expr: `type in (Map, Message) and time > 1m`, want: map[data.OpType]bool{data.TMap: true, data.TMessage: true}},
+		{expr: `type = Map or speak = say`, want: map[data.OpType]bool{data.TMap: true, data.TCreatureMessage: true}},
+		{expr: `type in (Map, Message) and name = "x"`, want: map[data.OpType]bool{}},
+		{expr: `type = Map or time > 1m`, want: nil},
+		{expr: `not type = Map`, want: nil},
+	} {
+		if diff := cmp.Diff(tc.want, query.MustCompile(tc.expr).Types()); diff != "" {
+			t.Errorf("Compile(%q).Types() diff; -want +got:\n%v", tc.expr, diff)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{expr: `type in (Map`, want: `query: at 12: expected ")", got end of query`},
+		{expr: `type = Bogus`, want: `query: at 7: unknown operation type "Bogus"`},
+		{expr: `near(1, 2, 3)`, want: `query: at 12: expected ",", got ")"`},
+		{expr: `time between 5 and 10m`, want: `query: at 13: expected a duration, got "5"`},
+		{expr: `text ~ hi`, want: `query: at 7: expected a string, got "hi"`},
+		{expr: `text ~ "unterminated`, want: `query: at 7: unterminated string`},
+		{expr: `speak = say say`, want: `query: at 12: unexpected "say"`},
+		{expr: `color = red`, want: `query: at 0: unknown predicate "color"`},
+		{expr: `text ~ "x" à`, want: `query: at 11: unexpected character 'à'`},
+	} {
+		_, err := query.Compile(tc.expr)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("Compile(%q) err = %v; want %s", tc.expr, err, tc.want)
+		}
+	}
+}
+
+func ExampleQuery_Filter() {
+	f, err := os.Open(filepath.Join(testdata, "Tibiantis.dat"))
+	if err != nil {
+		panic(err)
+	}
+	datFile, err := dat.Read(bufio.NewReader(f))
+	f.Close()
+	if err != nil {
+		panic(err)
+	}
+	r, err := os.Open(filepath.Join(testdata, "tibiantis.cam"))
+	if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+
+	q := query.MustCompile(`speak = say and (name = "Muzir" or text ~ "gold")`)
+	for op, err := range q.Filter(cam.Parse(r, &cam.ParseOpts{DATFile: datFile, TFilter: q.Types()})) {
+		if err != nil {
+			panic(err)
+		}
+		msg := op.(data.CreatureMessage)
+		fmt.Printf("%s: %s\n", msg.Name, msg.Text)
+	}
+
+	// Output:
+	// Muzir: Welcome Shy Teddy! Daraman's blessings.
+	// Shy Teddy: change gold
+	// Muzir: How many platinum coins do you want to get?
+	// Muzir: Here you are.
+	// Muzir: Daraman's blessings.
+}
@@ -0,0 +1,387 @@
+// Package search maintains an on-disk full-text index over an archive of recordings.
+//
+// The index holds chat (CreatureMessage), server messages (Message), creature sightings and entries into named
+// areas, keyed by recording path and TimeOffset. Every entry is tagged with the areas the player was in at the
+// time, so that questions like "which recordings have player X saying Y near Thais" are answered without
+// parsing recordings again.
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/s5i/tcam/analysis"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/data"
+)
+
+// indexVersion is bumped whenever the on-disk layout or the indexed content changes.
+const indexVersion = 1
+
+// Kind classifies an Entry.
+type Kind int
+
+const (
+	KindSpeech   Kind = iota // CreatureMessage; Name is the speaker.
+	KindMessage              // Server Message.
+	KindCreature             // A creature coming into view; Name is the creature.
+	KindArea                 // The player entering a named area; Text is the area name.
+)
+
+var kindName = map[Kind]string{
+	KindSpeech:   "speech",
+	KindMessage:  "message",
+	KindCreature: "creature",
+	KindArea:     "area",
+}
+
+func (k Kind) String() string {
+	if n, ok := kindName[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Entry is a single indexed event.
+type Entry struct {
+	TimeOffset time.Duration
+	Kind       Kind
+	Name       string
+	Text       string
+	Location   data.Location // Of the speaker or creature if known, otherwise of the player.
+	Areas      []string      // Areas the player was in.
+}
+
+// Opts controls the behavior of Index.
+type Opts struct {
+	// Resolve picks the .dat file for every recording. Required for Update.
+	Resolve batch.DatResolver
+
+	// Areas to tag entries with. An index saved with different areas is discarded by Open.
+	Areas []analysis.RouteArea
+
+	// Number of recordings Update parses concurrently. Defaults to 1.
+	Workers int
+}
+
+// Index is an inverted index over recordings. It is not safe for concurrent use.
+type Index struct {
+	opts  Opts
+	files map[string]*fileIndex
+}
+
+// fileIndex holds the entries of a single recording with their posting lists.
+type fileIndex struct {
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Entries  []Entry
+	Postings map[string][]int32 // By term; indices into Entries, ascending.
+}
+
+// indexFile is the on-disk layout.
+type indexFile struct {
+	Version int
+	Areas   []analysis.RouteArea
+	Files   []*fileIndex
+}
+
+// New returns an empty Index. opts may be nil.
+func New(opts *Opts) *Index {
+	ix := &Index{files: map[string]*fileIndex{}}
+	if opts != nil {
+		ix.opts = *opts
+	}
+	ix.opts.Workers = max(ix.opts.Workers, 1)
+	return ix
+}
+
+// Open loads the index saved at path. A missing file, or one saved by another version or with other
+// areas, yields an empty Index. opts may be nil.
+func Open(path string, opts *Opts) (*Index, error) {
+	ix := New(opts)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file indexFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&file); err != nil {
+		return nil, fmt.Errorf("search: reading %s: %w", path, err)
+	}
+	if file.Version != indexVersion || !reflect.DeepEqual(file.Areas, nilIfEmpty(ix.opts.Areas)) {
+		return ix, nil
+	}
+	for _, fi := range file.Files {
+		ix.files[fi.Path] = fi
+	}
+	return ix, nil
+}
+
+func nilIfEmpty[T any](s []T) []T {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Save writes the index to path, replacing it atomically.
+func (ix *Index) Save(path string) error {
+	file := indexFile{Version: indexVersion, Areas: nilIfEmpty(ix.opts.Areas)}
+	for _, p := range ix.Files() {
+		file.Files = append(file.Files, ix.files[p])
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := gob.NewEncoder(w).Encode(&file); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Files returns the indexed recording paths, sorted.
+func (ix *Index) Files() []string {
+	var paths []string
+	for p := range ix.files {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// Remove drops a recording from the index. It reports whether it was indexed.
+func (ix *Index) Remove(path string) bool {
+	_, ok := ix.files[path]
+	delete(ix.files, path)
+	return ok
+}
+
+// UpdateResult summarizes an Update run.
+type UpdateResult struct {
+	Indexed   int // New or changed recordings.
+	Unchanged int
+
+	// Errors holds per-file errors, in no particular order. Failed recordings keep their previous entries.
+	Errors []*batch.FileError
+}
+
+// Update indexes the recordings at paths that are new or whose size or modification time changed since they
+// were indexed. Recordings indexed before but missing from paths are kept; see Remove.
+//
+// The returned error is non-nil only if ctx was cancelled before all recordings were processed.
+func (ix *Index) Update(ctx context.Context, paths []string) (*UpdateResult, error) {
+	if ix.opts.Resolve == nil {
+		return nil, errors.New("search: Opts.Resolve is required")
+	}
+
+	res := &UpdateResult{}
+	var stale []*fileIndex
+	for _, path := range paths {
+		st, err := os.Stat(path)
+		if err != nil {
+			res.Errors = append(res.Errors, &batch.FileError{Path: path, Err: err})
+			continue
+		}
+		if old, ok := ix.files[path]; ok && old.Size == st.Size() && old.ModTime.Equal(st.ModTime()) {
+			res.Unchanged++
+			continue
+		}
+		stale = append(stale, &fileIndex{Path: path, Size: st.Size(), ModTime: st.ModTime()})
+	}
+
+	jobs := make(chan *fileIndex)
+	go func() {
+		defer close(jobs)
+		for _, fi := range stale {
+			select {
+			case jobs <- fi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(ix.opts.Workers, max(len(stale), 1)) {
+		wg.Go(func() {
+			for fi := range jobs {
+				err := ix.indexFile(ctx, fi)
+				if err != nil && ctx.Err() != nil {
+					continue
+				}
+
+				mu.Lock()
+				if err != nil {
+					res.Errors = append(res.Errors, &batch.FileError{Path: fi.Path, Err: err})
+				} else {
+					ix.files[fi.Path] = fi
+					res.Indexed++
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	return res, ctx.Err()
+}
+
+// indexTypes are the operations indexFile needs: chat, messages, and everything that introduces creatures.
+// Player positions are tracked by the parser regardless.
+var indexTypes = map[data.OpType]bool{
+	data.TCreatureMessage: true,
+	data.TMessage:         true,
+	data.TMap:             true,
+	data.TMoveNorth:       true,
+	data.TMoveEast:        true,
+	data.TMoveSouth:       true,
+	data.TMoveWest:        true,
+	data.TMoveFloorUp:     true,
+	data.TMoveFloorDown:   true,
+	data.TTileUpdate:      true,
+	data.TTileItemAdd:     true,
+	data.TTileItemUpdate:  true,
+}
+
+func (ix *Index) indexFile(ctx context.Context, fi *fileIndex) error {
+	datFile, err := ix.opts.Resolve(fi.Path)
+	if err != nil {
+		return fmt.Errorf("resolving .dat: %w", err)
+	}
+	f, err := os.Open(fi.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var areas []string
+	add := func(e Entry) {
+		e.Areas = areas
+		fi.Entries = append(fi.Entries, e)
+	}
+	for op, err := range cam.Parse(f, &cam.ParseOpts{DATFile: datFile, TFilter: indexTypes}) {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		offset, pos := data.TimeOffsetOf(op), data.PlayerPosOf(op)
+		var now []string
+		for _, a := range ix.opts.Areas {
+			if a.Contains(pos) {
+				now = append(now, a.Name)
+			}
+		}
+		prev := areas
+		areas = nilIfEmpty(now)
+		for _, name := range areas {
+			if !slices.Contains(prev, name) {
+				add(Entry{TimeOffset: offset, Kind: KindArea, Text: name, Location: pos})
+			}
+		}
+
+		switch op := op.(type) {
+		case data.CreatureMessage:
+			e := Entry{TimeOffset: offset, Kind: KindSpeech, Name: op.Name, Text: op.Text, Location: pos}
+			if op.Location != nil {
+				e.Location = *op.Location
+			}
+			add(e)
+		case data.Message:
+			add(Entry{TimeOffset: offset, Kind: KindMessage, Text: op.Text, Location: pos})
+		default:
+			for _, c := range newCreatures(op) {
+				add(Entry{TimeOffset: offset, Kind: KindCreature, Name: c.name, Location: c.loc})
+			}
+		}
+	}
+
+	fi.Postings = map[string][]int32{}
+	for i, e := range fi.Entries {
+		for _, term := range entryTerms(e) {
+			p := fi.Postings[term]
+			if len(p) == 0 || p[len(p)-1] != int32(i) {
+				fi.Postings[term] = append(p, int32(i))
+			}
+		}
+	}
+	return nil
+}
+
+type sighting struct {
+	name string
+	loc  data.Location
+}
+
+// newCreatures returns creatures the client is told about for the first time (0x0061 things), which carry names.
+func newCreatures(op data.Operation) []sighting {
+	var ret []sighting
+	for _, tile := range data.TilesOf(op) {
+		for _, t := range tile.Things {
+			if t.HasCreature && t.Creature.Name != "" {
+				ret = append(ret, sighting{name: t.Creature.Name, loc: tile.Location})
+			}
+		}
+	}
+	return ret
+}
+
+// Term prefixes for names and areas. Words never contain them.
+const (
+	nameTerm = "\x00name:"
+	areaTerm = "\x00area:"
+)
+
+func entryTerms(e Entry) []string {
+	terms := words(e.Text)
+	if e.Name != "" {
+		terms = append(terms, nameTerm+strings.ToLower(e.Name))
+	}
+	for _, a := range e.Areas {
+		terms = append(terms, areaTerm+strings.ToLower(a))
+	}
+	if e.Kind == KindArea {
+		terms = append(terms, areaTerm+strings.ToLower(e.Text))
+	}
+	return terms
+}
+
+// words splits text into lowercase words of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
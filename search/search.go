@@ -0,0 +1,112 @@
+package search
+
+import (
+	"slices"
+	"strings"
+)
+
+// Query selects entries. All set conditions must hold.
+type Query struct {
+	// Words that must all appear in the entry text, in any order. Case-insensitive.
+	Words string
+
+	// Speaker or creature name. Case-insensitive.
+	Name string
+
+	// Area the player was in.
+	Area string
+
+	// If set, only entries of these kinds match.
+	Kinds []Kind
+
+	// Number of speech and message entries to include before and after each hit.
+	Context int
+}
+
+// Hit is an entry matching a Query.
+type Hit struct {
+	Path   string
+	Entry  Entry
+	Before []Entry // Oldest first.
+	After  []Entry
+}
+
+// Search returns the entries matching q, ordered by path and TimeOffset.
+func (ix *Index) Search(q Query) []Hit {
+	var terms []string
+	terms = append(terms, words(q.Words)...)
+	if q.Name != "" {
+		terms = append(terms, nameTerm+strings.ToLower(q.Name))
+	}
+	if q.Area != "" {
+		terms = append(terms, areaTerm+strings.ToLower(q.Area))
+	}
+
+	var hits []Hit
+	for _, path := range ix.Files() {
+		fi := ix.files[path]
+		for _, i := range fi.match(terms) {
+			e := fi.Entries[i]
+			if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, e.Kind) {
+				continue
+			}
+			hits = append(hits, Hit{
+				Path:   path,
+				Entry:  e,
+				Before: fi.context(i, -1, q.Context),
+				After:  fi.context(i, +1, q.Context),
+			})
+		}
+	}
+	return hits
+}
+
+// match returns the indices of entries holding all terms, ascending. With no terms, every entry matches.
+func (fi *fileIndex) match(terms []string) []int {
+	if len(terms) == 0 {
+		ret := make([]int, len(fi.Entries))
+		for i := range ret {
+			ret[i] = i
+		}
+		return ret
+	}
+
+	// Intersect starting from the shortest list.
+	lists := make([][]int32, len(terms))
+	for i, t := range terms {
+		lists[i] = fi.Postings[t]
+		if len(lists[i]) == 0 {
+			return nil
+		}
+	}
+	slices.SortFunc(lists, func(a, b []int32) int { return len(a) - len(b) })
+
+	var ret []int
+	for _, i := range lists[0] {
+		all := true
+		for _, l := range lists[1:] {
+			if _, ok := slices.BinarySearch(l, i); !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			ret = append(ret, int(i))
+		}
+	}
+	return ret
+}
+
+// context returns up to n speech and message entries next to entry i, in direction dir (-1 or +1).
+func (fi *fileIndex) context(i, dir, n int) []Entry {
+	var ret []Entry
+	for j := i + dir; j >= 0 && j < len(fi.Entries) && len(ret) < n; j += dir {
+		if e := fi.Entries[j]; e.Kind == KindSpeech || e.Kind == KindMessage {
+			ret = append(ret, e)
+		}
+	}
+	if dir < 0 {
+		slices.Reverse(ret)
+	}
+	return ret
+}
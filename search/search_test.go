@@ -0,0 +1,158 @@
+package search_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/analysis"
+	"github.com/s5i/tcam/batch"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/search"
+)
+
+const testdata = "../cam/testdata"
+
+var darashia = analysis.RouteArea{
+	Name: "Darashia",
+	From: data.Location{X: 33200, Y: 32370, Z: 7},
+	To:   data.Location{X: 33250, Y: 32460, Z: 7},
+}
+
+func testOpts(t *testing.T) *search.Opts {
+	t.Helper()
+
+	reg := dat.NewRegistry()
+	for name, path := range map[string]string{"tibiantis": "Tibiantis.dat", "relic": "TibiaRelic.dat"} {
+		if err := reg.AddFile(name, filepath.Join(testdata, path)); err != nil {
+			t.Fatalf("AddFile(%q) error: %v", path, err)
+		}
+	}
+	return &search.Opts{Resolve: batch.RegistryDat(reg), Areas: []analysis.RouteArea{darashia}, Workers: 2}
+}
+
+// copyCams copies the test recordings into a temporary directory, so that they can be touched.
+func copyCams(t *testing.T) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"relic.cam", "tibiantis.cam"} {
+		b, err := os.ReadFile(filepath.Join(testdata, name))
+		if err != nil {
+			t.Fatalf("ReadFile(%q) error: %v", name, err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatalf("WriteFile(%q) error: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	paths := copyCams(t)
+	indexPath := filepath.Join(t.TempDir(), "index")
+	opts := testOpts(t)
+
+	ix, err := search.Open(indexPath, opts)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	res, err := ix.Update(ctx, append(paths, filepath.Join(testdata, "missing.cam")))
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if res.Indexed != 2 || res.Unchanged != 0 || len(res.Errors) != 1 {
+		t.Errorf("Update() = %+v, want 2 indexed and 1 error", res)
+	}
+	if err := ix.Save(indexPath); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	ix, err = search.Open(indexPath, opts)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if diff := cmp.Diff(paths, ix.Files()); diff != "" {
+		t.Errorf("Files() after Open diff; -want +got:\n%v", diff)
+	}
+
+	want := []search.Hit{{
+		Path: paths[1],
+		Entry: search.Entry{
+			TimeOffset: 2*time.Minute + 7*time.Second + 328*time.Millisecond,
+			Kind:       search.KindSpeech,
+			Name:       "Shy Teddy",
+			Text:       "change gold",
+			Location:   data.Location{X: 33220, Y: 32387, Z: 7},
+			Areas:      []string{"Darashia"},
+		},
+	}}
+	got := ix.Search(search.Query{Words: "GOLD change", Name: "shy teddy", Area: "darashia", Context: 1})
+	for i := range got {
+		if len(got[i].Before) != 1 || len(got[i].After) != 1 {
+			t.Errorf("Search() hit %d has context %v, %v; want one entry on each side", i, got[i].Before, got[i].After)
+		}
+		got[i].Before, got[i].After = nil, nil
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Search() diff; -want +got:\n%v", diff)
+	}
+
+	if got := ix.Search(search.Query{Name: "Muzir", Kinds: []search.Kind{search.KindCreature}}); len(got) != 1 || got[0].Entry.Areas[0] != "Darashia" {
+		t.Errorf("Search(creature Muzir) = %v, want one sighting in Darashia", got)
+	}
+	if got := ix.Search(search.Query{Words: "gold", Area: "Thais"}); len(got) != 0 {
+		t.Errorf("Search(gold in Thais) = %v, want no hits", got)
+	}
+
+	// Only touched recordings are indexed again.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(paths[0], later, later); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+	res, err = ix.Update(ctx, paths)
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if res.Indexed != 1 || res.Unchanged != 1 || len(res.Errors) != 0 {
+		t.Errorf("Update() after touching one recording = %+v, want 1 indexed and 1 unchanged", res)
+	}
+
+	if !ix.Remove(paths[0]) || ix.Remove(paths[0]) {
+		t.Errorf("Remove() twice did not report true, then false")
+	}
+	if diff := cmp.Diff(paths[1:], ix.Files()); diff != "" {
+		t.Errorf("Files() after Remove diff; -want +got:\n%v", diff)
+	}
+}
+
+func TestOpen_AreasChanged(t *testing.T) {
+	paths := copyCams(t)
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	ix := search.New(testOpts(t))
+	if _, err := ix.Update(context.Background(), paths); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if err := ix.Save(indexPath); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	opts := testOpts(t)
+	opts.Areas = nil
+	ix, err := search.Open(indexPath, opts)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if got := ix.Files(); len(got) != 0 {
+		t.Errorf("Files() with other areas = %v, want none", got)
+	}
+}
@@ -0,0 +1,480 @@
+// Package world reconstructs the game state visible to the recording player from parsed operations.
+package world
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+)
+
+// Creature is a creature known to the client, with its last known position.
+type Creature struct {
+	data.Creature
+
+	Location data.Location
+	Visible  bool // False once the creature got removed from the map or left the view.
+
+	FirstSeen time.Duration
+	LastSeen  time.Duration
+}
+
+// Container is an open container window.
+type Container struct {
+	ID        byte
+	ItemID    uint16
+	Name      string
+	Volume    byte
+	HasParent bool // Opened from within another container, replacing it in the same window.
+
+	// Items by slot. New items are put in the first slot, shifting others down.
+	Items []data.Item
+}
+
+// State tracks map tiles, creatures, open containers and the player's inventory.
+// Operations must be applied in recording order and must not be filtered by type.
+type State struct {
+	dat *dat.File
+
+	now       time.Duration
+	playerID  uint32
+	playerPos data.Location
+
+	// Creature things in tiles only carry the creature ID; details live in creatures.
+	tiles     map[data.Location][]data.Thing
+	creatures map[uint32]*Creature
+
+	containers map[byte]*Container
+	inventory  map[data.InventorySlot]data.Item
+
+	// Mismatches counts operations that didn't match the tracked state, e.g. a stack index pointing nowhere.
+	Mismatches int
+}
+
+// New initializes an empty State. datFile is used to order items on tiles;
+// without it, items are stacked as common items.
+func New(datFile *dat.File) *State {
+	return &State{
+		dat:        datFile,
+		tiles:      map[data.Location][]data.Thing{},
+		creatures:  map[uint32]*Creature{},
+		containers: map[byte]*Container{},
+		inventory:  map[data.InventorySlot]data.Item{},
+	}
+}
+
+// Now returns the TimeOffset of the last applied operation.
+func (s *State) Now() time.Duration {
+	return s.now
+}
+
+// PlayerID returns the recording player's creature ID.
+func (s *State) PlayerID() uint32 {
+	return s.playerID
+}
+
+// PlayerPos returns the recording player's position.
+func (s *State) PlayerPos() data.Location {
+	return s.playerPos
+}
+
+// Player returns the recording player's creature.
+func (s *State) Player() (Creature, bool) {
+	return s.Creature(s.playerID)
+}
+
+// Creature returns a creature by ID, including creatures that are no longer visible.
+func (s *State) Creature(id uint32) (Creature, bool) {
+	c, ok := s.creatures[id]
+	if !ok {
+		return Creature{}, false
+	}
+	return *c, true
+}
+
+// CreatureAt returns the topmost creature standing at loc.
+func (s *State) CreatureAt(loc data.Location) (Creature, bool) {
+	for _, t := range s.tiles[loc] {
+		if t.HasCreature {
+			return s.Creature(t.Creature.ID)
+		}
+	}
+	return Creature{}, false
+}
+
+// Creatures returns all visible creatures, ordered by ID.
+func (s *State) Creatures() []Creature {
+	var ret []Creature
+	for _, c := range s.creatures {
+		if c.Visible {
+			ret = append(ret, *c)
+		}
+	}
+	slices.SortFunc(ret, func(a, b Creature) int { return cmp.Compare(a.ID, b.ID) })
+	return ret
+}
+
+// Tile returns things on a tile in stack order, with creature details filled in.
+func (s *State) Tile(loc data.Location) []data.Thing {
+	things := slices.Clone(s.tiles[loc])
+	for i, t := range things {
+		if c, ok := s.creatures[t.Creature.ID]; ok && t.HasCreature {
+			things[i].Creature = c.Creature
+		}
+	}
+	return things
+}
+
+// Container returns an open container by ID.
+func (s *State) Container(id byte) (Container, bool) {
+	c, ok := s.containers[id]
+	if !ok {
+		return Container{}, false
+	}
+	ret := *c
+	ret.Items = slices.Clone(c.Items)
+	return ret, true
+}
+
+// Containers returns all open containers, ordered by ID.
+func (s *State) Containers() []Container {
+	var ret []Container
+	for _, id := range slices.Sorted(maps.Keys(s.containers)) {
+		c, _ := s.Container(id)
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// Inventory returns the item in an inventory slot.
+func (s *State) Inventory(slot data.InventorySlot) (data.Item, bool) {
+	it, ok := s.inventory[slot]
+	return it, ok
+}
+
+// Apply updates the state with the next operation.
+func (s *State) Apply(op data.Operation) {
+	if _, ok := op.(data.CamMetadata); !ok {
+		s.now = data.TimeOffsetOf(op)
+	}
+
+	switch op := op.(type) {
+	case data.LoginPlayerState:
+		s.playerID = op.PlayerID
+	case data.Map:
+		s.playerPos = op.PlayerPos
+		for loc := range s.tiles {
+			s.clearTile(loc)
+		}
+		s.setTiles(op.Tiles)
+	case data.MoveNorth:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.MoveEast:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.MoveSouth:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.MoveWest:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.MoveFloorUp:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.MoveFloorDown:
+		s.movePlayer(op.PlayerPos, op.Tiles)
+	case data.TileUpdate:
+		s.clearTile(op.Location)
+		if op.HasTile {
+			s.setTiles([]data.Tile{op.Tile})
+		}
+	case data.TileItemAdd:
+		s.insertThing(op.Location, op.Thing)
+	case data.TileItemUpdate:
+		s.updateThing(op.Location, int(op.StackIndex), op.Thing)
+	case data.TileItemRemove:
+		s.removeThing(op.Location, int(op.StackIndex))
+	case data.CreatureMove:
+		s.moveCreature(op)
+	case data.CreatureHealth:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.Health = op.Health })
+	case data.CreatureLight:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.LightLevel, c.LightColor = op.Level, op.Color })
+	case data.CreatureOutfit:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.Outfit = op.Outfit })
+	case data.CreatureSpeed:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.Speed = op.Speed })
+	case data.CreatureSkull:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.Skull = op.Skull })
+	case data.CreatureParty:
+		s.updateCreature(op.CreatureID, func(c *Creature) { c.Shield = op.Shield })
+	case data.ContainerOpen:
+		s.openContainer(op)
+	case data.ContainerClose:
+		delete(s.containers, op.ContainerID)
+	case data.ContainerItemAdd:
+		s.addContainerItem(op.ContainerID, op.Thing)
+	case data.ContainerItemUpdate:
+		s.updateContainerItem(op.ContainerID, int(op.Slot), op.Thing)
+	case data.ContainerItemRemove:
+		s.removeContainerItem(op.ContainerID, int(op.Slot))
+	case data.InventoryItemSet:
+		if op.Slot < data.SlotHead || op.Slot > data.SlotAmmo {
+			s.Mismatches++
+			return
+		}
+		s.inventory[op.Slot] = op.Item
+	case data.InventoryItemClear:
+		if _, ok := s.inventory[op.Slot]; !ok {
+			s.Mismatches++
+			return
+		}
+		delete(s.inventory, op.Slot)
+	}
+}
+
+func (s *State) openContainer(op data.ContainerOpen) {
+	// Opening a container replaces whatever was open in the same window.
+	c := &Container{ID: op.ContainerID, ItemID: op.ItemID, Name: op.Name, Volume: op.Volume, HasParent: op.HasParent != 0}
+	for _, t := range op.Items {
+		if t.HasItem {
+			c.Items = append(c.Items, t.Item)
+		}
+	}
+	s.containers[op.ContainerID] = c
+}
+
+func (s *State) addContainerItem(id byte, t data.Thing) {
+	c, ok := s.containers[id]
+	if !ok || !t.HasItem {
+		s.Mismatches++
+		return
+	}
+	c.Items = slices.Insert(c.Items, 0, t.Item)
+	// The client drops the last item of a full container; the server moves it elsewhere separately.
+	if c.Volume > 0 && len(c.Items) > int(c.Volume) {
+		c.Items = c.Items[:c.Volume]
+	}
+}
+
+func (s *State) updateContainerItem(id byte, slot int, t data.Thing) {
+	c, ok := s.containers[id]
+	if !ok || slot >= len(c.Items) || !t.HasItem {
+		s.Mismatches++
+		return
+	}
+	c.Items[slot] = t.Item
+}
+
+func (s *State) removeContainerItem(id byte, slot int) {
+	c, ok := s.containers[id]
+	if !ok || slot >= len(c.Items) {
+		s.Mismatches++
+		return
+	}
+	c.Items = slices.Delete(c.Items, slot, slot+1)
+}
+
+func (s *State) movePlayer(pos data.Location, tiles []data.Tile) {
+	s.playerPos = pos
+	for loc := range s.tiles {
+		if !s.inView(loc) {
+			s.clearTile(loc)
+		}
+	}
+	s.setTiles(tiles)
+}
+
+// inView reports whether loc is within the client viewport around the player.
+func (s *State) inView(loc data.Location) bool {
+	p := s.playerPos
+	if p.Z <= 7 {
+		if loc.Z > 7 {
+			return false
+		}
+	} else if loc.Z < p.Z-2 || loc.Z > p.Z+2 {
+		return false
+	}
+	offset := p.Z - loc.Z
+	x, y := loc.X-offset, loc.Y-offset
+	return x >= p.X-8 && x <= p.X+9 && y >= p.Y-6 && y <= p.Y+7
+}
+
+func (s *State) clearTile(loc data.Location) {
+	for _, t := range s.tiles[loc] {
+		if c, ok := s.creatures[t.Creature.ID]; ok && t.HasCreature && c.Location == loc {
+			c.Visible = false
+		}
+	}
+	delete(s.tiles, loc)
+}
+
+func (s *State) setTiles(tiles []data.Tile) {
+	for _, tile := range tiles {
+		s.clearTile(tile.Location)
+		things := make([]data.Thing, 0, len(tile.Things))
+		for _, t := range tile.Things {
+			if t.HasCreature {
+				t = s.placeCreature(t.Creature, tile.Location)
+			}
+			things = append(things, t)
+		}
+		s.tiles[tile.Location] = things
+	}
+}
+
+// placeCreature records a creature appearing at loc and returns the thing to store on the tile.
+func (s *State) placeCreature(cr data.Creature, loc data.Location) data.Thing {
+	// The server may evict a creature that is still on screen; keep it until it disappears.
+	if old, ok := s.creatures[cr.RemovedID]; ok && cr.RemovedID != cr.ID && !old.Visible {
+		delete(s.creatures, cr.RemovedID)
+	}
+
+	c, ok := s.creatures[cr.ID]
+	switch {
+	case !ok:
+		c = &Creature{Creature: cr, FirstSeen: s.now}
+		s.creatures[cr.ID] = c
+	case isTurn(cr):
+		// Creature turn (0x0063).
+		c.Direction = cr.Direction
+	case cr.Name != "":
+		// Full description (0x0061).
+		c.Creature = cr
+	default:
+		// Known creature (0x0062); the name isn't resent.
+		name := c.Name
+		c.Creature = cr
+		c.Name = name
+	}
+
+	if c.Visible && c.Location != loc {
+		s.dropCreature(c.Location, c.ID)
+	}
+	c.Location = loc
+	c.Visible = true
+	c.LastSeen = s.now
+	return data.Thing{HasCreature: true, Creature: data.Creature{ID: cr.ID}}
+}
+
+// dropCreature removes a creature thing from a tile without marking it invisible.
+func (s *State) dropCreature(loc data.Location, id uint32) {
+	things := s.tiles[loc]
+	for i, t := range things {
+		if t.HasCreature && t.Creature.ID == id {
+			s.tiles[loc] = slices.Delete(things, i, i+1)
+			return
+		}
+	}
+}
+
+func (s *State) insertThing(loc data.Location, t data.Thing) {
+	if t.HasCreature {
+		t = s.placeCreature(t.Creature, loc)
+	}
+
+	// Pre-8.54 clients append non-common things after others of the same priority,
+	// and prepend creatures and common items.
+	things := s.tiles[loc]
+	priority := s.priority(t)
+	appendThing := priority <= 3
+	pos := 0
+	for ; pos < len(things); pos++ {
+		other := s.priority(things[pos])
+		if (appendThing && other > priority) || (!appendThing && other >= priority) {
+			break
+		}
+	}
+	s.tiles[loc] = slices.Insert(things, pos, t)
+}
+
+func (s *State) updateThing(loc data.Location, stack int, t data.Thing) {
+	things := s.tiles[loc]
+	if stack >= len(things) {
+		s.Mismatches++
+		return
+	}
+
+	if old := things[stack]; old.HasCreature && !(t.HasCreature && t.Creature.ID == old.Creature.ID) {
+		if c, ok := s.creatures[old.Creature.ID]; ok && c.Location == loc {
+			c.Visible = false
+		}
+	}
+	if t.HasCreature {
+		t = s.placeCreature(t.Creature, loc)
+	}
+	things[stack] = t
+}
+
+// isTurn reports whether a creature carries nothing but an ID and a direction, as sent by 0x0063.
+func isTurn(c data.Creature) bool {
+	return c.Name == "" && c.Health == 0 && c.Outfit == (data.Outfit{}) && c.Speed == 0 && c.LightLevel == 0
+}
+
+func (s *State) removeThing(loc data.Location, stack int) {
+	things := s.tiles[loc]
+	if stack >= len(things) {
+		s.Mismatches++
+		return
+	}
+	if t := things[stack]; t.HasCreature {
+		if c, ok := s.creatures[t.Creature.ID]; ok {
+			c.Visible = false
+			c.LastSeen = s.now
+		}
+	}
+	s.tiles[loc] = slices.Delete(things, stack, stack+1)
+}
+
+func (s *State) moveCreature(op data.CreatureMove) {
+	things := s.tiles[op.OldLocation]
+	stack := int(op.OldStack)
+	if stack >= len(things) || !things[stack].HasCreature {
+		s.Mismatches++
+		return
+	}
+	id := things[stack].Creature.ID
+	s.tiles[op.OldLocation] = slices.Delete(things, stack, stack+1)
+
+	c, ok := s.creatures[id]
+	if !ok {
+		s.Mismatches++
+		return
+	}
+	c.Visible = false
+	s.insertThing(op.NewLocation, data.Thing{HasCreature: true, Creature: c.Creature})
+}
+
+func (s *State) updateCreature(id uint32, f func(c *Creature)) {
+	c, ok := s.creatures[id]
+	if !ok {
+		s.Mismatches++
+		return
+	}
+	f(c)
+	c.LastSeen = s.now
+}
+
+// priority returns the stack priority of a thing: ground, ground border, on-bottom, on-top, creature, common item.
+func (s *State) priority(t data.Thing) int {
+	if t.HasCreature {
+		return 4
+	}
+	if s.dat == nil {
+		return 5
+	}
+	p, ok := s.dat.Properties(int(t.Item.ID))
+	switch {
+	case !ok:
+		return 5
+	case p.Ground:
+		return 0
+	case p.GroundBorder:
+		return 1
+	case p.OnBottom:
+		return 2
+	case p.OnTop:
+		return 3
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,205 @@
+package world_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/tcam/cam"
+	"github.com/s5i/tcam/dat"
+	"github.com/s5i/tcam/data"
+	"github.com/s5i/tcam/world"
+)
+
+const testdata = "../cam/testdata"
+
+// Item IDs from Tibiantis.dat.
+const (
+	ground  = 100
+	border  = 602
+	bottom  = 388
+	top     = 1123
+	common  = 371
+	common2 = 372
+)
+
+func item(id uint16) data.Thing {
+	return data.Thing{HasItem: true, Item: data.Item{ID: id}}
+}
+
+func creature(c data.Creature) data.Thing {
+	return data.Thing{HasCreature: true, Creature: c}
+}
+
+func ids(things []data.Thing) []uint32 {
+	var ret []uint32
+	for _, t := range things {
+		if t.HasCreature {
+			ret = append(ret, t.Creature.ID)
+		} else {
+			ret = append(ret, uint32(t.Item.ID))
+		}
+	}
+	return ret
+}
+
+func TestState(t *testing.T) {
+	s := world.New(readDat(t, filepath.Join(testdata, "Tibiantis.dat")))
+
+	pos := data.Location{X: 100, Y: 100, Z: 7}
+	east := data.Location{X: 101, Y: 100, Z: 7}
+	player := data.Creature{ID: 1, Name: "Player", Health: 100, Speed: 220}
+	rat := data.Creature{ID: 2, Name: "Rat", Health: 100, Speed: 100}
+
+	for _, op := range []data.Operation{
+		data.LoginPlayerState{PlayerID: 1},
+		data.Map{PlayerPos: pos, Tiles: []data.Tile{
+			{Location: pos, Things: []data.Thing{item(ground), creature(player)}},
+			{Location: east, Things: []data.Thing{item(ground), item(common)}},
+		}},
+		data.TileItemAdd{Location: east, Thing: item(top)},
+		data.TileItemAdd{Location: east, Thing: item(border)},
+		data.TileItemAdd{Location: east, Thing: item(bottom)},
+		data.TileItemAdd{Location: east, Thing: item(common2)},
+		data.TileItemAdd{Location: east, Thing: creature(rat)},
+	} {
+		s.Apply(op)
+	}
+
+	if diff := cmp.Diff([]uint32{ground, border, bottom, top, 2, common2, common}, ids(s.Tile(east))); diff != "" {
+		t.Errorf("Tile(%v) diff (-want +got):\n%s", east, diff)
+	}
+	if c, ok := s.CreatureAt(east); !ok || c.Name != "Rat" {
+		t.Errorf("CreatureAt(%v) = %+v, %v; want Rat", east, c, ok)
+	}
+
+	// Known creature (0x0062) updates keep the name; turns (0x0063) only change the direction.
+	s.Apply(data.TileItemUpdate{Location: east, StackIndex: 4, Thing: creature(data.Creature{ID: 2, Health: 50, Speed: 100})})
+	s.Apply(data.TileItemUpdate{Location: east, StackIndex: 4, Thing: creature(data.Creature{ID: 2, Direction: data.Direction(3)})})
+	s.Apply(data.CreatureMove{OldLocation: east, OldStack: 4, NewLocation: pos})
+	if diff := cmp.Diff([]uint32{ground, 2, 1}, ids(s.Tile(pos))); diff != "" {
+		t.Errorf("Tile(%v) diff (-want +got):\n%s", pos, diff)
+	}
+	want := world.Creature{
+		Creature: data.Creature{ID: 2, Name: "Rat", Health: 50, Direction: data.Direction(3), Speed: 100},
+		Location: pos,
+		Visible:  true,
+	}
+	if got, _ := s.Creature(2); !cmp.Equal(want, got) {
+		t.Errorf("Creature(2) diff (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	s.Apply(data.CreatureHealth{CreatureID: 2, Health: 0})
+	s.Apply(data.TileItemRemove{Location: pos, StackIndex: 1})
+	if got := s.Creatures(); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("Creatures() = %+v; want only the player", got)
+	}
+	if c, ok := s.Creature(2); !ok || c.Visible || c.Health != 0 {
+		t.Errorf("Creature(2) = %+v, %v; want invisible with 0 health", c, ok)
+	}
+
+	// Walking away drops tiles that left the viewport.
+	s.Apply(data.MoveWest{PlayerPos: data.Location{X: 91, Y: 100, Z: 7}})
+	if got := s.Tile(east); len(got) != 0 {
+		t.Errorf("Tile(%v) = %v; want empty after leaving the viewport", east, got)
+	}
+	if s.Mismatches != 0 {
+		t.Errorf("Mismatches = %d; want 0", s.Mismatches)
+	}
+}
+
+func TestState_Containers(t *testing.T) {
+	s := world.New(nil)
+	s.Apply(data.ContainerOpen{ContainerID: 0, ItemID: 2854, Name: "backpack", Volume: 3, Items: []data.Thing{item(common), item(common2)}})
+	s.Apply(data.ContainerItemAdd{ContainerID: 0, Thing: item(top)})
+	s.Apply(data.ContainerItemUpdate{ContainerID: 0, Slot: 2, Thing: data.Thing{HasItem: true, Item: data.Item{ID: common2, Count: 5}}})
+	s.Apply(data.ContainerItemRemove{ContainerID: 0, Slot: 0})
+	want := world.Container{ID: 0, ItemID: 2854, Name: "backpack", Volume: 3, Items: []data.Item{{ID: common}, {ID: common2, Count: 5}}}
+	if got, ok := s.Container(0); !ok || !cmp.Equal(want, got) {
+		t.Errorf("Container(0) diff (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// A full container drops its last item.
+	s.Apply(data.ContainerItemAdd{ContainerID: 0, Thing: item(top)})
+	s.Apply(data.ContainerItemAdd{ContainerID: 0, Thing: item(bottom)})
+	want.Items = []data.Item{{ID: bottom}, {ID: top}, {ID: common}}
+	if got, _ := s.Container(0); !cmp.Equal(want, got) {
+		t.Errorf("Container(0) diff (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Opening a container from within another one replaces it.
+	s.Apply(data.ContainerOpen{ContainerID: 0, ItemID: 2853, Name: "bag", Volume: 8, HasParent: 1})
+	s.Apply(data.ContainerOpen{ContainerID: 1, ItemID: 2853, Name: "bag", Volume: 8})
+	s.Apply(data.ContainerClose{ContainerID: 1})
+	if got := s.Containers(); len(got) != 1 || got[0].Name != "bag" || len(got[0].Items) != 0 || !got[0].HasParent {
+		t.Errorf("Containers() = %+v; want only the empty bag", got)
+	}
+
+	s.Apply(data.InventoryItemSet{Slot: data.SlotLeft, Item: data.Item{ID: common}})
+	s.Apply(data.InventoryItemSet{Slot: data.SlotAmmo, Item: data.Item{ID: common2, Count: 10}})
+	s.Apply(data.InventoryItemClear{Slot: data.SlotLeft})
+	if it, ok := s.Inventory(data.SlotAmmo); !ok || it != (data.Item{ID: common2, Count: 10}) {
+		t.Errorf("Inventory(SlotAmmo) = %+v, %v; want %v", it, ok, common2)
+	}
+	if it, ok := s.Inventory(data.SlotLeft); ok {
+		t.Errorf("Inventory(SlotLeft) = %+v; want empty", it)
+	}
+	if s.Mismatches != 0 {
+		t.Errorf("Mismatches = %d; want 0", s.Mismatches)
+	}
+
+	s.Apply(data.ContainerItemRemove{ContainerID: 0, Slot: 0})
+	s.Apply(data.InventoryItemSet{Slot: 11})
+	if s.Mismatches != 2 {
+		t.Errorf("Mismatches = %d; want 2", s.Mismatches)
+	}
+}
+
+func TestState_Recordings(t *testing.T) {
+	for _, tc := range []struct {
+		cam, dat string
+		player   string
+	}{
+		{cam: "tibiantis.cam", dat: "Tibiantis.dat", player: "Shy Teddy"},
+		{cam: "relic.cam", dat: "TibiaRelic.dat", player: "Golden"},
+	} {
+		t.Run(tc.cam, func(t *testing.T) {
+			datFile := readDat(t, filepath.Join(testdata, tc.dat))
+			f, err := os.Open(filepath.Join(testdata, tc.cam))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			s := world.New(datFile)
+			for op, err := range cam.Parse(f, &cam.ParseOpts{DATFile: datFile}) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.Apply(op)
+			}
+
+			if s.Mismatches != 0 {
+				t.Errorf("Mismatches = %d; want 0", s.Mismatches)
+			}
+			if p, ok := s.Player(); !ok || p.Name != tc.player {
+				t.Errorf("Player() = %+v, %v; want %q", p, ok, tc.player)
+			}
+		})
+	}
+}
+
+func readDat(t *testing.T, path string) *dat.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := dat.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}